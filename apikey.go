@@ -0,0 +1,187 @@
+package msgo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/apikey"
+	"github.com/liyuanwu2020/msgo/mserror"
+	"github.com/liyuanwu2020/msgo/ratelimit"
+)
+
+// APIKeyContextKey 认证通过后 *apikey.Key 在 ctx 中的键
+const APIKeyContextKey = "api_key"
+
+// APIKeyConfig API Key 和请求签名认证中间件配置
+type APIKeyConfig struct {
+	//Store key 存储, 必填
+	Store apikey.Store
+	//Header 携带 key 的请求头, 默认 X-Api-Key
+	Header string
+	//Query 携带 key 的查询参数, 默认 api_key, 请求头优先
+	Query string
+	//Scopes 访问需要的全部 scope
+	Scopes []string
+	//RequireSignature 所有请求都要求 HMAC 签名, 没有 Secret 的 key 被拒绝;
+	//未开启时配置了 Secret 的 key 同样必须签名, 因为签名请求中的 key ID 是公开的
+	RequireSignature bool
+	//MaxSkew 签名时间戳允许的偏差, 默认 5 分钟
+	MaxSkew time.Duration
+	//Nonces 签名请求的重放保护, 默认内存缓存
+	Nonces apikey.NonceCache
+	//LimitStore 按 key 限流的计数存储, 默认内存存储
+	LimitStore ratelimit.Store
+	//ErrorHandler 认证失败时的处理, 默认按 HandleWithError 输出
+	ErrorHandler func(ctx *Context, err error)
+	TimeFun      func() time.Time
+}
+
+// APIKey 使用默认配置的 API Key 认证中间件
+func APIKey(store apikey.Store) MiddlewareFunc {
+	return APIKeyWithConfig(APIKeyConfig{Store: store})
+}
+
+func APIKeyWithConfig(conf APIKeyConfig) MiddlewareFunc {
+	if conf.Store == nil {
+		panic("msgo: APIKeyConfig.Store is required")
+	}
+	if conf.Header == "" {
+		conf.Header = apikey.HeaderKey
+	}
+	if conf.Query == "" {
+		conf.Query = "api_key"
+	}
+	if conf.MaxSkew <= 0 {
+		conf.MaxSkew = 5 * time.Minute
+	}
+	if conf.Nonces == nil {
+		conf.Nonces = apikey.NewMemoryNonceCache()
+	}
+	if conf.TimeFun == nil {
+		conf.TimeFun = time.Now
+	}
+	if conf.ErrorHandler == nil {
+		conf.ErrorHandler = func(ctx *Context, err error) {
+			ctx.HandleWithError(http.StatusUnauthorized, nil, err)
+		}
+	}
+	var limitStoreOnce sync.Once
+	limitStore := func() ratelimit.Store {
+		limitStoreOnce.Do(func() {
+			if conf.LimitStore == nil {
+				conf.LimitStore = ratelimit.NewMemoryStore()
+			}
+		})
+		return conf.LimitStore
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			id := ctx.R.Header.Get(conf.Header)
+			if id == "" {
+				id = ctx.R.URL.Query().Get(conf.Query)
+			}
+			if id == "" {
+				conf.ErrorHandler(ctx, apikey.ErrKeyMissing)
+				return
+			}
+			key, err := conf.Store.Lookup(ctx.R.Context(), id)
+			if errors.Is(err, apikey.ErrKeyNotFound) {
+				err = apikey.ErrKeyInvalid
+			}
+			if err == nil && !key.Active(conf.TimeFun()) {
+				err = apikey.ErrKeyInvalid
+			}
+			if err != nil {
+				conf.ErrorHandler(ctx, err)
+				return
+			}
+			if conf.RequireSignature || key.Secret != "" {
+				if !conf.verifySignature(ctx, key) {
+					return
+				}
+			}
+			for _, scope := range conf.Scopes {
+				if !key.HasScope(scope) {
+					conf.ErrorHandler(ctx, apikey.ErrScopeDenied)
+					return
+				}
+			}
+			if key.Rate > 0 {
+				result, err := ratelimit.NewTokenBucket(key.Rate, key.LimitBurst(), limitStore()).Allow("apikey:" + key.ID)
+				if err != nil {
+					//存储不可用时放行, 与 Limiter 中间件一致
+					ctx.Logger.Error(err)
+				} else {
+					result.WriteHeaders(ctx.W.Header())
+					if !result.Allowed {
+						conf.ErrorHandler(ctx, mserror.ErrTooManyRequests)
+						return
+					}
+				}
+			}
+			ctx.Set(APIKeyContextKey, key)
+			if key.Subject != "" {
+				ctx.Set("user", key.Subject)
+			}
+			next(ctx)
+		}
+	}
+}
+
+// verifySignature 校验签名、时间戳和 nonce, 失败时已输出响应
+func (conf *APIKeyConfig) verifySignature(ctx *Context, key *apikey.Key) bool {
+	if key.Secret == "" {
+		conf.ErrorHandler(ctx, apikey.ErrSignatureInvalid.WithMessage("api key can not sign requests"))
+		return false
+	}
+	ts, err := apikey.Timestamp(ctx.R)
+	if err != nil {
+		conf.ErrorHandler(ctx, err)
+		return false
+	}
+	if skew := conf.TimeFun().Sub(ts); skew > conf.MaxSkew || skew < -conf.MaxSkew {
+		conf.ErrorHandler(ctx, apikey.ErrRequestExpired)
+		return false
+	}
+	var body []byte
+	if ctx.R.Body != nil && ctx.R.Body != http.NoBody {
+		body, err = io.ReadAll(ctx.R.Body)
+		if err != nil {
+			ctx.checkBodyError(err)
+			if !ctx.bodyErrWritten {
+				conf.ErrorHandler(ctx, mserror.ErrBadRequest.WithCause(err))
+			}
+			return false
+		}
+		ctx.R.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if err := apikey.Verify(ctx.R, []byte(key.Secret), body); err != nil {
+		conf.ErrorHandler(ctx, err)
+		return false
+	}
+	//签名通过后再记录 nonce, 避免伪造请求占用合法的 nonce
+	ok, err := conf.Nonces.Add(key.ID+":"+ctx.R.Header.Get(apikey.HeaderNonce), 2*conf.MaxSkew)
+	if err != nil {
+		conf.ErrorHandler(ctx, err)
+		return false
+	}
+	if !ok {
+		conf.ErrorHandler(ctx, apikey.ErrNonceReused)
+		return false
+	}
+	return true
+}
+
+// APIKeyFrom 读取 APIKey 中间件认证通过的 key
+func APIKeyFrom(ctx *Context) (*apikey.Key, bool) {
+	v, ok := ctx.Get(APIKeyContextKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := v.(*apikey.Key)
+	return key, ok
+}
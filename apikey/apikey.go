@@ -0,0 +1,121 @@
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/mserror"
+)
+
+// 认证失败的错误码
+var (
+	ErrKeyMissing       = mserror.Register(40110, http.StatusUnauthorized, "api key is missing")
+	ErrKeyInvalid       = mserror.Register(40111, http.StatusUnauthorized, "api key is invalid")
+	ErrSignatureInvalid = mserror.Register(40112, http.StatusUnauthorized, "request signature is invalid")
+	ErrRequestExpired   = mserror.Register(40113, http.StatusUnauthorized, "request timestamp is out of range")
+	ErrNonceReused      = mserror.Register(40114, http.StatusUnauthorized, "request nonce is reused")
+	ErrScopeDenied      = mserror.Register(40301, http.StatusForbidden, "api key scope is insufficient")
+)
+
+// ErrKeyNotFound Store 中不存在该 key
+var ErrKeyNotFound = errors.New("apikey: key not found")
+
+// Key 调用方的 API Key
+type Key struct {
+	//ID 请求中携带的 API Key, 签名请求中作为公开的 key ID
+	ID string
+	//Secret HMAC 签名密钥, 设置后该 key 的请求必须签名, 为空时 key 本身是凭据, 需要保密
+	Secret string
+	//Subject key 所属的调用方, 认证后写入 ctx 的 user
+	Subject string
+	//Scopes key 的权限范围, * 表示全部
+	Scopes []string
+	//Rate 按 key 限流, 每秒 Rate 次, 突发 Burst 次, 0 不限流
+	Rate float64
+	//Burst 为 0 时取 Rate 向上取整, 至少为 1
+	Burst int
+	//ExpiresAt 零值不过期
+	ExpiresAt time.Time
+	Disabled  bool
+}
+
+// LimitBurst 限流的突发次数, 未设置 Burst 时按 Rate 计算, 保证每秒 Rate 次的请求可以通过
+func (k *Key) LimitBurst() int {
+	if k.Burst > 0 {
+		return k.Burst
+	}
+	burst := int(math.Ceil(k.Rate))
+	if burst < 1 {
+		burst = 1
+	}
+	return burst
+}
+
+// HasScope key 是否拥有 scope
+func (k *Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == "*" || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active key 在 now 时是否可用
+func (k *Key) Active(now time.Time) bool {
+	return !k.Disabled && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// Store API Key 存储, 不存在时返回 ErrKeyNotFound
+type Store interface {
+	Lookup(ctx context.Context, id string) (*Key, error)
+}
+
+// StoreFunc 使用函数查找 key, 如查询数据库
+type StoreFunc func(ctx context.Context, id string) (*Key, error)
+
+func (f StoreFunc) Lookup(ctx context.Context, id string) (*Key, error) {
+	return f(ctx, id)
+}
+
+// MemoryStore 内存 key 存储, 按 key 的哈希索引, 查找耗时与 key 内容无关
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[[sha256.Size]byte]*Key
+}
+
+func NewMemoryStore(keys ...*Key) *MemoryStore {
+	s := &MemoryStore{keys: make(map[[sha256.Size]byte]*Key, len(keys))}
+	for _, k := range keys {
+		s.Add(k)
+	}
+	return s
+}
+
+// Add 添加或替换 key
+func (s *MemoryStore) Add(k *Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[sha256.Sum256([]byte(k.ID))] = k
+}
+
+func (s *MemoryStore) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, sha256.Sum256([]byte(id)))
+}
+
+func (s *MemoryStore) Lookup(ctx context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[sha256.Sum256([]byte(id))]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return k, nil
+}
@@ -0,0 +1,86 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	s := NewSigner("partner", "secret")
+	r := httptest.NewRequest("POST", "/orders?b=2&a=1", strings.NewReader(`{"id":1}`))
+	if err := s.SignRequest(r); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != `{"id":1}` {
+		t.Fatalf("request body must be preserved, got %q", body)
+	}
+	if err := Verify(r, []byte("secret"), body); err != nil {
+		t.Fatalf("signature should verify: %v", err)
+	}
+	if err := Verify(r, []byte("other"), body); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("wrong secret must fail, got %v", err)
+	}
+	if err := Verify(r, []byte("secret"), []byte(`{"id":2}`)); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("tampered body must fail, got %v", err)
+	}
+	r.URL.RawQuery = "a=1&b=3"
+	if err := Verify(r, []byte("secret"), body); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("tampered query must fail, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(&Key{ID: "k1", Scopes: []string{"orders:read"}})
+	k, err := s.Lookup(context.Background(), "k1")
+	if err != nil || !k.HasScope("orders:read") || k.HasScope("orders:write") {
+		t.Fatalf("unexpected key %+v, %v", k, err)
+	}
+	if !k.Active(time.Now()) {
+		t.Fatal("key without expiry should be active")
+	}
+	s.Remove("k1")
+	if _, err := s.Lookup(context.Background(), "k1"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestKeyLimitBurst(t *testing.T) {
+	cases := []struct {
+		rate  float64
+		burst int
+		want  int
+	}{
+		{5, 0, 5},
+		{2.5, 0, 3},
+		{0.1, 0, 1},
+		{5, 8, 8},
+	}
+	for _, c := range cases {
+		k := &Key{Rate: c.rate, Burst: c.burst}
+		if got := k.LimitBurst(); got != c.want {
+			t.Errorf("LimitBurst(rate=%v, burst=%d) = %d, want %d", c.rate, c.burst, got, c.want)
+		}
+	}
+}
+
+func TestMemoryNonceCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewMemoryNonceCache()
+	c.TimeFun = func() time.Time { return now }
+	if ok, _ := c.Add("n1", time.Minute); !ok {
+		t.Fatal("first use should be accepted")
+	}
+	if ok, _ := c.Add("n1", time.Minute); ok {
+		t.Fatal("reused nonce should be rejected")
+	}
+	now = now.Add(2 * time.Minute)
+	if ok, _ := c.Add("n1", time.Minute); !ok {
+		t.Fatal("expired nonce should be accepted again")
+	}
+}
@@ -0,0 +1,46 @@
+package apikey
+
+import (
+	"sync"
+	"time"
+)
+
+// NonceCache 记录使用过的 nonce 用于重放保护, 多实例部署时需要使用共享存储实现
+type NonceCache interface {
+	// Add 记录 nonce, ttl 内已存在时返回 false
+	Add(nonce string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceCache 内存 nonce 缓存, 过期记录在写入时定期清理, 适合单实例部署
+type MemoryNonceCache struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	nextSweep time.Time
+	TimeFun   func() time.Time
+}
+
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{
+		nonces:  make(map[string]time.Time),
+		TimeFun: time.Now,
+	}
+}
+
+func (c *MemoryNonceCache) Add(nonce string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.TimeFun()
+	if now.After(c.nextSweep) {
+		for n, expires := range c.nonces {
+			if now.After(expires) {
+				delete(c.nonces, n)
+			}
+		}
+		c.nextSweep = now.Add(time.Minute)
+	}
+	if expires, ok := c.nonces[nonce]; ok && !now.After(expires) {
+		return false, nil
+	}
+	c.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,129 @@
+package apikey
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 签名请求使用的请求头
+const (
+	HeaderKey       = "X-Api-Key"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// StringToSign 待签名字符串, 各部分以换行分隔:
+// 请求方法、路径、按键排序的查询参数、Unix 时间戳(秒)、nonce、请求体 SHA-256 的十六进制
+func StringToSign(r *http.Request, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(r.Method),
+		r.URL.EscapedPath(),
+		r.URL.Query().Encode(),
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// Sign HMAC-SHA256 签名, 输出十六进制
+func Sign(secret []byte, stringToSign string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验请求头中的签名, 不检查时间戳范围和 nonce 是否重复
+func Verify(r *http.Request, secret, body []byte) error {
+	signature := r.Header.Get(HeaderSignature)
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	if signature == "" || timestamp == "" || nonce == "" {
+		return ErrSignatureInvalid.WithMessage("signature, timestamp and nonce headers are required")
+	}
+	expected := Sign(secret, StringToSign(r, timestamp, nonce, body))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// Timestamp 读取请求头中的签名时间
+func Timestamp(r *http.Request) (time.Time, error) {
+	sec, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return time.Time{}, ErrRequestExpired.WithMessage("request timestamp is invalid")
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// Signer 为发出的请求签名, 与服务端的 APIKey 中间件配合使用
+type Signer struct {
+	KeyID   string
+	Secret  []byte
+	TimeFun func() time.Time
+}
+
+func NewSigner(keyID, secret string) *Signer {
+	return &Signer{KeyID: keyID, Secret: []byte(secret), TimeFun: time.Now}
+}
+
+// SignRequest 读取请求体计算签名并写入请求头, 请求体会被替换为可重复读取的副本
+func (s *Signer) SignRequest(r *http.Request) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	now := time.Now
+	if s.TimeFun != nil {
+		now = s.TimeFun
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	nonce := newNonce()
+	r.Header.Set(HeaderKey, s.KeyID)
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, Sign(s.Secret, StringToSign(r, timestamp, nonce, body)))
+	return nil
+}
+
+// readBody 读取请求体, 优先使用 GetBody 避免消耗原请求体
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// newNonce 随机 128 位 nonce
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,103 @@
+package msgo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/apikey"
+)
+
+func newAPIKeyEngine(conf APIKeyConfig) *Engine {
+	e := newTestEngine()
+	g := e.Group("api")
+	g.Post("/orders", func(ctx *Context) {
+		key, _ := APIKeyFrom(ctx)
+		_ = ctx.String(http.StatusOK, key.ID)
+	}, APIKeyWithConfig(conf))
+	return e
+}
+
+func TestAPIKey(t *testing.T) {
+	store := apikey.NewMemoryStore(
+		&apikey.Key{ID: "plain", Scopes: []string{"orders"}, Rate: 1, Burst: 1},
+		&apikey.Key{ID: "readonly", Scopes: []string{"reports"}},
+		&apikey.Key{ID: "expired", Scopes: []string{"*"}, ExpiresAt: time.Unix(1, 0)},
+	)
+	e := newAPIKeyEngine(APIKeyConfig{Store: store, Scopes: []string{"orders"}})
+	cases := []struct {
+		name   string
+		target string
+		header []string
+		want   int
+	}{
+		{"missing", "/api/orders", nil, http.StatusUnauthorized},
+		{"unknown", "/api/orders", []string{"X-Api-Key", "nope"}, http.StatusUnauthorized},
+		{"expired", "/api/orders", []string{"X-Api-Key", "expired"}, http.StatusUnauthorized},
+		{"scope", "/api/orders", []string{"X-Api-Key", "readonly"}, http.StatusForbidden},
+		{"query", "/api/orders?api_key=plain", nil, http.StatusOK},
+		{"rate limited", "/api/orders", []string{"X-Api-Key", "plain"}, http.StatusTooManyRequests},
+	}
+	for _, c := range cases {
+		if w := serve(e, http.MethodPost, c.target, nil, c.header...); w.Code != c.want {
+			t.Errorf("%s: status %d, want %d, body %s", c.name, w.Code, c.want, w.Body.String())
+		}
+	}
+}
+
+func TestAPIKeySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := apikey.NewMemoryStore(&apikey.Key{ID: "partner", Secret: "s3cret", Scopes: []string{"*"}})
+	e := newAPIKeyEngine(APIKeyConfig{Store: store, TimeFun: func() time.Time { return now }})
+	signer := apikey.NewSigner("partner", "s3cret")
+	signer.TimeFun = func() time.Time { return now }
+	signed := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/orders?id=1", strings.NewReader(body))
+		if err := signer.SignRequest(r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	do := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	//签名请求中的 key ID 是公开的, 只携带 key ID 不能通过认证
+	if w := serve(e, http.MethodPost, "/api/orders", nil, "X-Api-Key", "partner"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned request with a signing key: status %d, want 401", w.Code)
+	}
+	r := signed(`{"n":1}`)
+	replay := r.Clone(r.Context())
+	replay.Body, _ = r.GetBody()
+	if code := do(r); code != http.StatusOK {
+		t.Fatalf("signed request: status %d, want 200", code)
+	}
+	if code := do(replay); code != http.StatusUnauthorized {
+		t.Fatalf("replayed request: status %d, want 401", code)
+	}
+	tampered := signed(`{"n":1}`)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"n":2}`))
+	if code := do(tampered); code != http.StatusUnauthorized {
+		t.Fatalf("tampered body: status %d, want 401", code)
+	}
+	old := signed("")
+	now = now.Add(10 * time.Minute)
+	if code := do(old); code != http.StatusUnauthorized {
+		t.Fatalf("expired timestamp: status %d, want 401", code)
+	}
+}
+
+func TestAPIKeyRateWithoutBurst(t *testing.T) {
+	store := apikey.NewMemoryStore(&apikey.Key{ID: "rated", Scopes: []string{"*"}, Rate: 2})
+	e := newAPIKeyEngine(APIKeyConfig{Store: store})
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if w := serve(e, http.MethodPost, "/api/orders", nil, "X-Api-Key", "rated"); w.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
@@ -3,33 +3,46 @@ package msgo
 import (
 	"encoding/base64"
 	"net/http"
+	"strconv"
+
+	"github.com/liyuanwu2020/msgo/credentials"
 )
 
 type Accounts struct {
 	UnAuthHandler func(ctx *Context)
-	Users         map[string]string
+	//Users 明文密码, 使用常量时间比较, 设置 Verifier 时忽略
+	Users map[string]string
+	//Verifier 校验用户名和密码, 如 credentials.Hashed、credentials.LoadHtpasswd 或 credentials.VerifierFunc
+	Verifier credentials.Verifier
+	//Realm WWW-Authenticate 中的 realm, 默认 Authorization Required
+	Realm string
 }
 
 func (a *Accounts) BasicAuth(next HandlerFunc) HandlerFunc {
 	return func(ctx *Context) {
 		username, password, ok := ctx.R.BasicAuth()
-		ctx.Logger.Info([]string{username, password})
-		if !ok {
+		if !ok || !a.verify(username, password) {
 			a.unAuthHandler(ctx)
 			return
 		}
-		pwd, exists := a.Users[username]
-		if !exists || pwd != password {
-			a.unAuthHandler(ctx)
-			return
-		}
-
 		ctx.Set("user", username)
 		next(ctx)
 	}
 }
 
+func (a *Accounts) verify(username, password string) bool {
+	if a.Verifier != nil {
+		return a.Verifier.Verify(username, password)
+	}
+	return credentials.Plain(a.Users).Verify(username, password)
+}
+
 func (a *Accounts) unAuthHandler(ctx *Context) {
+	realm := a.Realm
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	ctx.W.Header().Set("WWW-Authenticate", "Basic realm="+strconv.Quote(realm)+`, charset="UTF-8"`)
 	if a.UnAuthHandler != nil {
 		a.UnAuthHandler(ctx)
 	} else {
@@ -0,0 +1,129 @@
+package msgo
+
+import (
+	"fmt"
+
+	"github.com/liyuanwu2020/msgo/authz"
+	"github.com/liyuanwu2020/msgo/internal/mapclaims"
+	"github.com/liyuanwu2020/msgo/mserror"
+	"github.com/liyuanwu2020/msgo/mslog"
+)
+
+// AuthzConfig 访问控制中间件配置
+type AuthzConfig struct {
+	//Enforcer 授权判断, 必填
+	Enforcer *authz.Enforcer
+	//Subject 读取主体和角色, 默认 SubjectFromContext
+	Subject func(ctx *Context) (string, []string)
+	//Action 默认请求方法
+	Action func(ctx *Context) string
+	//Resource 默认为匹配路由的请求路径(分组名加路由内路径), 分组名之前的前缀不参与判断, 返回空时拒绝
+	Resource func(ctx *Context) string
+	//DenyHandler 拒绝时的处理, 默认没有主体时返回 401, 否则返回 403
+	DenyHandler func(ctx *Context, d authz.Decision)
+	//Audit 记录被拒绝的请求, 默认写入 ctx.Logger
+	Audit func(ctx *Context, req authz.Request, d authz.Decision)
+}
+
+// Authz 使用默认配置的访问控制中间件
+func Authz(e *authz.Enforcer) MiddlewareFunc {
+	return AuthzWithConfig(AuthzConfig{Enforcer: e})
+}
+
+func AuthzWithConfig(conf AuthzConfig) MiddlewareFunc {
+	if conf.Enforcer == nil {
+		panic("msgo: AuthzConfig.Enforcer is required")
+	}
+	if conf.Subject == nil {
+		conf.Subject = SubjectFromContext
+	}
+	if conf.Action == nil {
+		conf.Action = func(ctx *Context) string { return ctx.R.Method }
+	}
+	if conf.Resource == nil {
+		conf.Resource = RoutePath
+	}
+	if conf.Audit == nil {
+		conf.Audit = auditDenied
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			subject, roles := conf.Subject(ctx)
+			req := authz.Request{Subject: subject, Roles: roles, Action: conf.Action(ctx), Resource: conf.Resource(ctx)}
+			d := authz.Decision{Reason: "no route matched"}
+			if req.Resource != "" {
+				d = conf.Enforcer.Enforce(req)
+			}
+			if d.Allowed {
+				next(ctx)
+				return
+			}
+			conf.Audit(ctx, req, d)
+			if conf.DenyHandler != nil {
+				conf.DenyHandler(ctx, d)
+				return
+			}
+			err := mserror.ErrForbidden
+			if subject == "" && len(roles) == 0 {
+				err = mserror.ErrUnauthorized
+			}
+			ctx.Error(err)
+			ctx.renderError(err)
+		}
+	}
+}
+
+// RoutePath 路由实际匹配的请求路径, 路由按分组名在路径中的位置截取, 如 /x/user/admin 按分组 user
+// 匹配为 /user/admin, 授权必须基于该路径, 否则可以通过添加前缀绕过规则, 没有匹配路由时返回空
+func RoutePath(ctx *Context) string {
+	if ctx.NodeRouterName == "" {
+		return ""
+	}
+	return "/" + ctx.groupName + SubStringLast(ctx.R.URL.Path, "/"+ctx.groupName)
+}
+
+// SubjectFromContext 默认的主体, 优先使用 JWT claims 中的 sub 和 roles, 其次使用 BasicAuth 写入的 user
+func SubjectFromContext(ctx *Context) (string, []string) {
+	if claims, ok := ctx.Get("claims"); ok {
+		sub, _ := mapclaims.Get(claims, "sub").(string)
+		return sub, claimStrings(mapclaims.Get(claims, "roles"))
+	}
+	if user, ok := ctx.Get("user"); ok {
+		if s, ok := user.(string); ok {
+			return s, nil
+		}
+	}
+	return "", nil
+}
+
+// claimStrings 把 claim 转换为字符串列表, JSON 解码的数组为 []any
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		s := make([]string, 0, len(v))
+		for _, item := range v {
+			s = append(s, fmt.Sprint(item))
+		}
+		return s
+	}
+	return nil
+}
+
+// auditDenied 记录被拒绝的请求
+func auditDenied(ctx *Context, req authz.Request, d authz.Decision) {
+	if ctx.Logger == nil {
+		return
+	}
+	ctx.Logger.WithFields(mslog.Fields{
+		"subject":  req.Subject,
+		"roles":    req.Roles,
+		"action":   req.Action,
+		"resource": req.Resource,
+		"route":    ctx.RoutePattern(),
+		"reason":   d.Reason,
+	}).Info("authz: access denied")
+}
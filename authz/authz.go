@@ -0,0 +1,249 @@
+package authz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Effect 权限的效果, 同时匹配时拒绝优先于允许
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// Anonymous 所有请求都拥有的角色, 用于配置公开资源
+const Anonymous = "anonymous"
+
+// Permission 允许或拒绝对匹配 Resource 的资源执行 Actions, Effect 为空时为允许
+type Permission struct {
+	Actions  []string `toml:"actions"`
+	Resource string   `toml:"resource"`
+	Effect   Effect   `toml:"effect"`
+}
+
+// Role 角色, 继承 Inherits 中角色的全部权限
+type Role struct {
+	Name        string       `toml:"name"`
+	Inherits    []string     `toml:"inherits"`
+	Permissions []Permission `toml:"permission"`
+}
+
+// Policy 角色定义和主体的角色分配, 主体的角色也可以由请求携带, 如 JWT 的 roles
+type Policy struct {
+	Roles    []Role              `toml:"role"`
+	Subjects map[string][]string `toml:"subjects"`
+}
+
+// Load 实现 Loader, 直接使用代码中定义的策略
+func (p *Policy) Load() (*Policy, error) {
+	return p, nil
+}
+
+// Loader 策略来源, Enforcer.Reload 时重新调用
+type Loader interface {
+	Load() (*Policy, error)
+}
+
+// Request 一次授权判断的输入
+type Request struct {
+	Subject  string
+	Roles    []string
+	Action   string
+	Resource string
+}
+
+// Decision 授权判断的结果, Permission 为命中的权限, 没有命中时为 nil
+type Decision struct {
+	Allowed    bool
+	Role       string
+	Permission *Permission
+	Reason     string
+}
+
+type grant struct {
+	role string
+	perm Permission
+}
+
+// Enforcer 按策略做授权判断, 可并发使用
+type Enforcer struct {
+	loader    Loader
+	cacheSize int
+
+	mu       sync.RWMutex
+	grants   map[string][]grant
+	subjects map[string][]string
+
+	cacheMu sync.Mutex
+	cache   map[string]Decision
+}
+
+// NewEnforcer 加载策略, cacheSize 大于 0 时缓存判断结果, 缓存满时整体清空
+func NewEnforcer(loader Loader, cacheSize int) (*Enforcer, error) {
+	e := &Enforcer{loader: loader, cacheSize: cacheSize}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload 重新加载策略并清空缓存, 加载失败时保留原策略
+func (e *Enforcer) Reload() error {
+	p, err := e.loader.Load()
+	if err != nil {
+		return err
+	}
+	grants, err := compile(p)
+	if err != nil {
+		return err
+	}
+	subjects := make(map[string][]string, len(p.Subjects))
+	for s, roles := range p.Subjects {
+		subjects[s] = append([]string(nil), roles...)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.grants = grants
+	e.subjects = subjects
+	e.cacheMu.Lock()
+	e.cache = nil
+	e.cacheMu.Unlock()
+	return nil
+}
+
+// Enforce 判断请求是否允许, 拒绝优先, 没有匹配的权限时拒绝
+func (e *Enforcer) Enforce(req Request) Decision {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.cacheSize <= 0 {
+		return e.decide(req)
+	}
+	key := cacheKey(req)
+	e.cacheMu.Lock()
+	d, ok := e.cache[key]
+	e.cacheMu.Unlock()
+	if ok {
+		return d
+	}
+	d = e.decide(req)
+	e.cacheMu.Lock()
+	if e.cache == nil || len(e.cache) >= e.cacheSize {
+		e.cache = make(map[string]Decision)
+	}
+	e.cache[key] = d
+	e.cacheMu.Unlock()
+	return d
+}
+
+// Allowed 同 Enforce, 只返回是否允许
+func (e *Enforcer) Allowed(subject, action, resource string, roles ...string) bool {
+	return e.Enforce(Request{Subject: subject, Roles: roles, Action: action, Resource: resource}).Allowed
+}
+
+// Roles 主体拥有的角色, 包括 roles、策略分配的角色和 Anonymous, 不展开继承
+func (e *Enforcer) Roles(subject string, roles ...string) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.subjectRoles(Request{Subject: subject, Roles: roles})
+}
+
+func (e *Enforcer) subjectRoles(req Request) []string {
+	roles := append([]string(nil), req.Roles...)
+	roles = append(roles, e.subjects[req.Subject]...)
+	return append(roles, Anonymous)
+}
+
+func (e *Enforcer) decide(req Request) Decision {
+	var allow *grant
+	for _, role := range e.subjectRoles(req) {
+		for i := range e.grants[role] {
+			g := &e.grants[role][i]
+			if !MatchAction(g.perm.Actions, req.Action) || !MatchResource(g.perm.Resource, req.Resource) {
+				continue
+			}
+			if g.perm.Effect == Deny {
+				return decision(false, g, "denied by role "+g.role)
+			}
+			if allow == nil {
+				allow = g
+			}
+		}
+	}
+	if allow != nil {
+		return decision(true, allow, "allowed by role "+allow.role)
+	}
+	return Decision{Reason: "no permission matched"}
+}
+
+func decision(allowed bool, g *grant, reason string) Decision {
+	perm := g.perm
+	return Decision{Allowed: allowed, Role: g.role, Permission: &perm, Reason: reason}
+}
+
+func cacheKey(req Request) string {
+	roles := append([]string(nil), req.Roles...)
+	sort.Strings(roles)
+	return strings.Join([]string{req.Subject, strings.Join(roles, ","), strings.ToUpper(req.Action), req.Resource}, "\x00")
+}
+
+// compile 校验策略并展开继承, 返回每个角色(含继承)的全部权限
+func compile(p *Policy) (map[string][]grant, error) {
+	roles := make(map[string]*Role, len(p.Roles))
+	for i := range p.Roles {
+		r := &p.Roles[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("authz: role %d has no name", i)
+		}
+		if _, ok := roles[r.Name]; ok {
+			return nil, fmt.Errorf("authz: duplicate role %q", r.Name)
+		}
+		for _, perm := range r.Permissions {
+			if perm.Resource == "" || len(perm.Actions) == 0 {
+				return nil, fmt.Errorf("authz: role %q has a permission without actions or resource", r.Name)
+			}
+			if perm.Effect != "" && perm.Effect != Allow && perm.Effect != Deny {
+				return nil, fmt.Errorf("authz: role %q has unknown effect %q", r.Name, perm.Effect)
+			}
+		}
+		roles[r.Name] = r
+	}
+	grants := make(map[string][]grant, len(roles))
+	var expand func(name string, visiting map[string]bool) ([]grant, error)
+	expand = func(name string, visiting map[string]bool) ([]grant, error) {
+		if gs, ok := grants[name]; ok {
+			return gs, nil
+		}
+		r, ok := roles[name]
+		if !ok {
+			return nil, fmt.Errorf("authz: unknown role %q", name)
+		}
+		if visiting[name] {
+			return nil, fmt.Errorf("authz: role %q inherits itself", name)
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+		var gs []grant
+		for _, perm := range r.Permissions {
+			gs = append(gs, grant{role: name, perm: perm})
+		}
+		for _, parent := range r.Inherits {
+			inherited, err := expand(parent, visiting)
+			if err != nil {
+				return nil, err
+			}
+			gs = append(gs, inherited...)
+		}
+		grants[name] = gs
+		return gs, nil
+	}
+	for name := range roles {
+		if _, err := expand(name, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	return grants, nil
+}
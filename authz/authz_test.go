@@ -0,0 +1,113 @@
+package authz
+
+import "testing"
+
+func TestMatchResource(t *testing.T) {
+	cases := []struct {
+		pattern, resource string
+		want              bool
+	}{
+		{"/admin/**", "/admin", true},
+		{"/admin/**", "/admin/users/1", true},
+		{"/admin/**", "/administrator", false},
+		{"/users/:id", "/users/1", true},
+		{"/users/{id}", "/users/1", true},
+		{"/users/:id", "/users/1/posts", false},
+		{"/users/*/posts", "/users/1/posts", true},
+		{"/**/export", "/reports/2024/export", true},
+		{"/files/*.pdf", "/files/a.pdf", true},
+		{"/files/*.pdf", "/files/a.txt", false},
+		{"/", "/", true},
+	}
+	for _, c := range cases {
+		if got := MatchResource(c.pattern, c.resource); got != c.want {
+			t.Errorf("MatchResource(%q, %q) = %v, want %v", c.pattern, c.resource, got, c.want)
+		}
+	}
+}
+
+const testPolicy = `
+[[role]]
+name = "anonymous"
+[[role.permission]]
+actions = ["GET"]
+resource = "/public/**"
+
+[[role]]
+name = "editor"
+[[role.permission]]
+actions = ["GET", "POST"]
+resource = "/articles/**"
+
+[[role]]
+name = "admin"
+inherits = ["editor"]
+[[role.permission]]
+actions = ["*"]
+resource = "/admin/**"
+[[role.permission]]
+actions = ["DELETE"]
+resource = "/admin/audit/**"
+effect = "deny"
+
+[subjects]
+alice = ["admin"]
+`
+
+func TestEnforcer(t *testing.T) {
+	p, err := ParseToml(testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(p, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		req  Request
+		want bool
+	}{
+		{Request{Subject: "alice", Action: "post", Resource: "/articles/1"}, true},
+		{Request{Subject: "alice", Action: "PUT", Resource: "/admin/users"}, true},
+		{Request{Subject: "alice", Action: "DELETE", Resource: "/admin/audit/1"}, false},
+		{Request{Subject: "bob", Action: "GET", Resource: "/articles/1"}, false},
+		{Request{Subject: "bob", Roles: []string{"editor"}, Action: "GET", Resource: "/articles/1"}, true},
+		{Request{Action: "GET", Resource: "/public/logo.png"}, true},
+		//已登录但没有分配角色的主体同样拥有 anonymous 的权限
+		{Request{Subject: "bob", Action: "GET", Resource: "/public/logo.png"}, true},
+		{Request{Action: "GET", Resource: "/articles/1"}, false},
+	}
+	for i := 0; i < 2; i++ {
+		for _, c := range cases {
+			if d := e.Enforce(c.req); d.Allowed != c.want {
+				t.Errorf("Enforce(%+v) = %+v, want %v", c.req, d, c.want)
+			}
+		}
+	}
+	d := e.Enforce(Request{Subject: "alice", Action: "DELETE", Resource: "/admin/audit/1"})
+	if d.Role != "admin" || d.Permission == nil || d.Permission.Effect != Deny {
+		t.Fatalf("deny decision should report the matched permission, got %+v", d)
+	}
+
+	p.Subjects["bob"] = []string{"editor"}
+	if err := e.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !e.Allowed("bob", "GET", "/articles/1") {
+		t.Fatal("reload must apply new assignments and clear the cache")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	policies := []*Policy{
+		{Roles: []Role{{Name: "a", Inherits: []string{"missing"}}}},
+		{Roles: []Role{{Name: "a", Inherits: []string{"b"}}, {Name: "b", Inherits: []string{"a"}}}},
+		{Roles: []Role{{Name: "a"}, {Name: "a"}}},
+		{Roles: []Role{{Name: "a", Permissions: []Permission{{Actions: []string{"GET"}, Resource: "/", Effect: "maybe"}}}}},
+	}
+	for i, p := range policies {
+		if _, err := NewEnforcer(p, 0); err == nil {
+			t.Errorf("policy %d should be rejected", i)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package authz
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/liyuanwu2020/msgo/orm"
+)
+
+// TomlLoader 从 TOML 文件加载策略, 格式:
+//
+//	[[role]]
+//	name = "editor"
+//	[[role.permission]]
+//	actions = ["GET", "POST"]
+//	resource = "/articles/**"
+//
+//	[[role]]
+//	name = "admin"
+//	inherits = ["editor"]
+//	[[role.permission]]
+//	actions = ["*"]
+//	resource = "/admin/**"
+//	[[role.permission]]
+//	actions = ["DELETE"]
+//	resource = "/admin/audit/**"
+//	effect = "deny"
+//
+//	[subjects]
+//	alice = ["admin"]
+type TomlLoader struct {
+	Path string
+}
+
+func (l TomlLoader) Load() (*Policy, error) {
+	p := &Policy{}
+	if _, err := toml.DecodeFile(l.Path, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParseToml 解析 TOML 格式的策略, 格式同 TomlLoader
+func ParseToml(data string) (*Policy, error) {
+	p := &Policy{}
+	if _, err := toml.Decode(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// OrmLoader 从数据库表加载策略, 表名会加上 db 的前缀, 表结构:
+//
+//	create table ms_authz_permission (
+//		role     varchar(64)  not null,
+//		actions  varchar(255) not null, -- 逗号分隔, 如 GET,POST 或 *
+//		resource varchar(255) not null,
+//		effect   varchar(8)   not null default 'allow'
+//	);
+//	create table ms_authz_role (
+//		role   varchar(64) not null,
+//		parent varchar(64) not null -- role 继承 parent 的权限
+//	);
+//	create table ms_authz_subject (
+//		subject varchar(128) not null,
+//		role    varchar(64)  not null
+//	);
+type OrmLoader struct {
+	db *orm.MsDb
+}
+
+func NewOrmLoader(db *orm.MsDb) *OrmLoader {
+	return &OrmLoader{db: db}
+}
+
+type permissionRow struct {
+	Role     string `msorm:"role"`
+	Actions  string `msorm:"actions"`
+	Resource string `msorm:"resource"`
+	Effect   string `msorm:"effect"`
+}
+
+type roleRow struct {
+	Role   string `msorm:"role"`
+	Parent string `msorm:"parent"`
+}
+
+type subjectRow struct {
+	Subject string `msorm:"subject"`
+	Role    string `msorm:"role"`
+}
+
+func (l *OrmLoader) Load() (*Policy, error) {
+	permissions, err := l.db.New().Table(l.db.Prefix + "ms_authz_permission").Select(&permissionRow{})
+	if err != nil {
+		return nil, err
+	}
+	inherits, err := l.db.New().Table(l.db.Prefix + "ms_authz_role").Select(&roleRow{})
+	if err != nil {
+		return nil, err
+	}
+	subjects, err := l.db.New().Table(l.db.Prefix + "ms_authz_subject").Select(&subjectRow{})
+	if err != nil {
+		return nil, err
+	}
+	roles := map[string]*Role{}
+	role := func(name string) *Role {
+		r, ok := roles[name]
+		if !ok {
+			r = &Role{Name: name}
+			roles[name] = r
+		}
+		return r
+	}
+	for _, row := range permissions {
+		p := row.(*permissionRow)
+		r := role(p.Role)
+		var actions []string
+		for _, a := range strings.Split(p.Actions, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				actions = append(actions, a)
+			}
+		}
+		r.Permissions = append(r.Permissions, Permission{Actions: actions, Resource: p.Resource, Effect: Effect(p.Effect)})
+	}
+	for _, row := range inherits {
+		i := row.(*roleRow)
+		role(i.Parent)
+		r := role(i.Role)
+		r.Inherits = append(r.Inherits, i.Parent)
+	}
+	policy := &Policy{Subjects: map[string][]string{}}
+	for _, row := range subjects {
+		s := row.(*subjectRow)
+		role(s.Role)
+		policy.Subjects[s.Subject] = append(policy.Subjects[s.Subject], s.Role)
+	}
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		policy.Roles = append(policy.Roles, *roles[name])
+	}
+	return policy, nil
+}
@@ -0,0 +1,64 @@
+package authz
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchResource 资源模式是否匹配资源, 按 / 分段比较:
+// ** 匹配任意多段(包括零段), * 和路由参数 :id、{id} 匹配一段, 其余段支持 path.Match 通配符
+func MatchResource(pattern, resource string) bool {
+	return matchSegments(splitPath(pattern), splitPath(resource))
+}
+
+// MatchAction 动作列表是否包含 action, * 匹配全部, 不区分大小写
+func MatchAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == "*" || strings.EqualFold(a, action) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 || !matchSegment(pattern[0], segments[0]) {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+func matchSegment(pattern, segment string) bool {
+	if pattern == "*" || strings.HasPrefix(pattern, ":") ||
+		(strings.HasPrefix(pattern, "{") && strings.HasSuffix(pattern, "}")) {
+		return true
+	}
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		return pattern == segment
+	}
+	ok, err := path.Match(pattern, segment)
+	return err == nil && ok
+}
@@ -0,0 +1,58 @@
+package msgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/authz"
+)
+
+func TestAuthzUsesRoutePath(t *testing.T) {
+	p := &authz.Policy{Roles: []authz.Role{{
+		Name: authz.Anonymous,
+		Permissions: []authz.Permission{
+			{Actions: []string{"*"}, Resource: "/**"},
+			{Actions: []string{"*"}, Resource: "/user/admin/**", Effect: authz.Deny},
+		},
+	}}}
+	enforcer, err := authz.NewEnforcer(p, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEngine()
+	g := e.Group("user")
+	g.Get("/admin/secret", func(ctx *Context) { _ = ctx.String(http.StatusOK, "secret") }, Authz(enforcer))
+	g.Get("/profile", func(ctx *Context) { _ = ctx.String(http.StatusOK, "profile") }, Authz(enforcer))
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/user/profile", http.StatusOK},
+		{"/user/admin/secret", http.StatusUnauthorized},
+		//路由按分组名在路径中的位置匹配, 带前缀的路径必须按同一个路由授权
+		{"/x/user/admin/secret", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		if w := serve(e, http.MethodGet, c.path, nil); w.Code != c.want {
+			t.Errorf("GET %s = %d, want %d", c.path, w.Code, c.want)
+		}
+	}
+}
+
+func TestAuthzDeniesWithoutRoute(t *testing.T) {
+	enforcer, err := authz.NewEnforcer(&authz.Policy{Roles: []authz.Role{{
+		Name:        authz.Anonymous,
+		Permissions: []authz.Permission{{Actions: []string{"*"}, Resource: "/**"}},
+	}}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	h := Authz(enforcer)(func(ctx *Context) { called = true })
+	ctx := &Context{W: httptest.NewRecorder(), R: httptest.NewRequest(http.MethodGet, "/anything", nil), engine: newTestEngine()}
+	h(ctx)
+	if called {
+		t.Fatal("requests without a matched route must be denied")
+	}
+}
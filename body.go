@@ -0,0 +1,198 @@
+package msgo
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/config"
+)
+
+var (
+	ErrBodyTooLarge = errors.New("request body too large")
+	ErrSlowClient   = errors.New("request body read too slow")
+)
+
+// BodyLimitConfig 请求体限制, 可通过 Engine.BodyLimit 全局配置, 或使用 BodyLimit 中间件按路由配置
+type BodyLimitConfig struct {
+	//MaxBytes 请求体大小上限, 超出返回 413, 0 不限制
+	MaxBytes int64
+	//MinReadRate 最低读取速率(字节/秒), 低于该速率返回 408, 0 不限制
+	MinReadRate int64
+	//ReadRateGrace 开始检查读取速率前的宽限时间, 默认 5 秒
+	ReadRateGrace time.Duration
+}
+
+// BodyLimitConfigFromToml 从 app.toml 的 [server] 配置生成请求体限制
+func BodyLimitConfigFromToml(conf config.ServerConf) BodyLimitConfig {
+	return BodyLimitConfig{
+		MaxBytes:      conf.MaxBodySize,
+		MinReadRate:   conf.MinReadRate,
+		ReadRateGrace: time.Duration(conf.ReadRateGrace) * time.Second,
+	}
+}
+
+func (conf *BodyLimitConfig) enabled() bool {
+	return conf.MaxBytes > 0 || conf.MinReadRate > 0
+}
+
+// BodyLimit 路由级别请求体大小限制中间件
+func BodyLimit(maxBytes int64) MiddlewareFunc {
+	return BodyLimitWithConfig(BodyLimitConfig{MaxBytes: maxBytes})
+}
+
+func BodyLimitWithConfig(conf BodyLimitConfig) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if !ctx.limitRequestBody(&conf, true) {
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// limitRequestBody 按配置包装原始请求体, 路由配置覆盖全局配置
+// checkLength 为 true 时 Content-Length 已超出直接返回 413, 全局配置不检查以便路由放宽限制
+func (c *Context) limitRequestBody(conf *BodyLimitConfig, checkLength bool) bool {
+	if !conf.enabled() || c.R.Body == nil || c.R.Body == http.NoBody {
+		return true
+	}
+	if checkLength && conf.MaxBytes > 0 && c.R.ContentLength > conf.MaxBytes {
+		c.bodyError(ErrBodyTooLarge)
+		return false
+	}
+	if c.rawBody == nil {
+		c.rawBody = c.R.Body
+	}
+	body := c.rawBody
+	if conf.MinReadRate > 0 {
+		grace := conf.ReadRateGrace
+		if grace <= 0 {
+			grace = 5 * time.Second
+		}
+		body = &minRateReader{r: body, deadline: findReadDeadliner(c.W), rate: conf.MinReadRate, grace: grace, start: time.Now()}
+	}
+	if conf.MaxBytes > 0 {
+		body = http.MaxBytesReader(c.W, body, conf.MaxBytes)
+	}
+	c.R.Body = body
+	return true
+}
+
+// checkBodyError 读取请求体超限或过慢时输出错误响应, 返回对应的错误
+func (c *Context) checkBodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, ErrBodyTooLarge):
+		c.bodyError(ErrBodyTooLarge)
+		return ErrBodyTooLarge
+	case errors.Is(err, ErrSlowClient):
+		c.bodyError(ErrSlowClient)
+		return ErrSlowClient
+	}
+	return err
+}
+
+// bodyError 使用注册的错误处理器输出错误格式, 未注册时输出文本
+// 读取过慢时响应后关闭连接, 请求体没有读完, 连接不能复用
+func (c *Context) bodyError(err error) {
+	if c.bodyErrWritten {
+		return
+	}
+	c.bodyErrWritten = true
+	if errors.Is(err, ErrSlowClient) {
+		c.W.Header().Set("Connection", "close")
+		c.writeBodyError(http.StatusRequestTimeout, err)
+		return
+	}
+	c.writeBodyError(http.StatusRequestEntityTooLarge, err)
+}
+
+func (c *Context) writeBodyError(status int, err error) {
+	if c.engine.errorHandler != nil {
+		_, data := c.engine.errorHandler(err)
+		_ = c.JSON(status, data)
+		return
+	}
+	c.Fail(status, err.Error())
+}
+
+// readDeadliner net/http 的 ResponseWriter 支持设置连接的读取截止时间
+type readDeadliner interface {
+	SetReadDeadline(deadline time.Time) error
+}
+
+// findReadDeadliner 沿 Unwrap 查找支持读取截止时间的 ResponseWriter, 不支持时返回 nil
+func findReadDeadliner(w http.ResponseWriter) readDeadliner {
+	for {
+		switch t := w.(type) {
+		case readDeadliner:
+			return t
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// minRateReader 读取速率低于下限时返回 ErrSlowClient
+// 宽限期后, 已读字节数需要达到 rate * 已用时间, 每次读取前按此设置连接的读取截止时间,
+// 阻塞的读取到期后立即返回; ResponseWriter 不支持截止时间时只在读取返回后检查速率
+type minRateReader struct {
+	r        io.ReadCloser
+	deadline readDeadliner
+	rate     int64
+	grace    time.Duration
+	start    time.Time
+	n        int64
+	err      error
+}
+
+func (m *minRateReader) Read(p []byte) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	deadline := m.start.Add(m.grace + time.Duration(float64(m.n+1)/float64(m.rate)*float64(time.Second)))
+	if !time.Now().Before(deadline) {
+		m.err = ErrSlowClient
+		return 0, m.err
+	}
+	if m.deadline != nil {
+		if err := m.deadline.SetReadDeadline(deadline); err != nil {
+			m.deadline = nil
+		}
+	}
+	n, err := m.r.Read(p)
+	m.n += int64(n)
+	if isTimeout(err) || (m.deadline == nil && err == nil && time.Now().After(deadline)) {
+		m.err = ErrSlowClient
+		return n, m.err
+	}
+	if err != nil {
+		m.clearDeadline()
+	}
+	return n, err
+}
+
+func (m *minRateReader) Close() error {
+	m.clearDeadline()
+	return m.r.Close()
+}
+
+// clearDeadline 请求体读完后清除截止时间, 否则 net/http 在连接上的后台读取会超时并取消请求的 context
+func (m *minRateReader) clearDeadline() {
+	if m.deadline != nil {
+		_ = m.deadline.SetReadDeadline(time.Time{})
+		m.deadline = nil
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
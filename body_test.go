@@ -0,0 +1,123 @@
+package msgo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newBodyServer(t *testing.T, conf BodyLimitConfig) *httptest.Server {
+	t.Helper()
+	e := newTestEngine()
+	e.Group("api").Post("/echo", func(ctx *Context) {
+		var v map[string]any
+		if err := ctx.BindJson(&v); err != nil {
+			return
+		}
+		_ = ctx.JSON(http.StatusOK, v)
+	}, BodyLimitWithConfig(conf))
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBodyLimit(t *testing.T) {
+	srv := newBodyServer(t, BodyLimitConfig{MaxBytes: 16})
+	cases := []struct {
+		body string
+		want int
+	}{
+		{`{"a":1}`, http.StatusOK},
+		{`{"a":"` + strings.Repeat("x", 32) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, c := range cases {
+		rsp, err := http.Post(srv.URL+"/api/echo", "application/json", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+		if rsp.StatusCode != c.want {
+			t.Errorf("POST %d bytes = %d, want %d", len(c.body), rsp.StatusCode, c.want)
+		}
+	}
+
+	//Content-Length 未知时在读取中超限
+	r, w := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(w, `{"a":"`+strings.Repeat("x", 32)+`"}`)
+		_ = w.Close()
+	}()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/echo", r)
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("chunked oversized body = %d, want 413", rsp.StatusCode)
+	}
+}
+
+func TestMinReadRate(t *testing.T) {
+	srv := newBodyServer(t, BodyLimitConfig{MinReadRate: 10, ReadRateGrace: 100 * time.Millisecond})
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	//声明 100 字节只发送 1 字节后停止, 阻塞的读取应在截止时间到期后返回
+	_, _ = fmt.Fprint(conn, "POST /api/echo HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{")
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	rsp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusRequestTimeout || !rsp.Close {
+		t.Fatalf("slow body = %d close=%v, want 408 and a closed connection", rsp.StatusCode, rsp.Close)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("slow client was detected after %v", elapsed)
+	}
+}
+
+// stallReader 每次读取前等待 delay
+type stallReader struct {
+	delay time.Duration
+	data  string
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	if r.data == "" {
+		return 0, io.EOF
+	}
+	n := copy(p[:1], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *stallReader) Close() error { return nil }
+
+func TestMinRateReaderWithoutDeadline(t *testing.T) {
+	fast := &minRateReader{r: &stallReader{data: "hello"}, rate: 10, grace: time.Second, start: time.Now()}
+	if data, err := io.ReadAll(fast); err != nil || string(data) != "hello" {
+		t.Fatalf("fast body = %q, %v", data, err)
+	}
+	slow := &minRateReader{r: &stallReader{delay: 30 * time.Millisecond, data: "hello"}, rate: 100, grace: 10 * time.Millisecond, start: time.Now()}
+	if _, err := io.ReadAll(slow); !errors.Is(err, ErrSlowClient) {
+		t.Fatalf("slow body error = %v, want ErrSlowClient", err)
+	}
+	if _, err := slow.Read(make([]byte, 1)); !errors.Is(err, ErrSlowClient) {
+		t.Fatalf("reads after a slow client must keep failing, got %v", err)
+	}
+}
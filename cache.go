@@ -0,0 +1,232 @@
+package msgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errUnsatisfiableRange = errors.New("invalid range: failed to overlap")
+
+// CacheControl Cache-Control 响应头策略
+type CacheControl struct {
+	MaxAge         time.Duration
+	SMaxAge        time.Duration
+	Public         bool
+	Private        bool
+	NoCache        bool
+	NoStore        bool
+	MustRevalidate bool
+	Immutable      bool
+}
+
+func (cc CacheControl) String() string {
+	var directives []string
+	if cc.Public {
+		directives = append(directives, "public")
+	}
+	if cc.Private {
+		directives = append(directives, "private")
+	}
+	if cc.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if cc.NoStore {
+		directives = append(directives, "no-store")
+	}
+	if cc.MaxAge > 0 {
+		directives = append(directives, "max-age="+strconv.FormatInt(int64(cc.MaxAge/time.Second), 10))
+	}
+	if cc.SMaxAge > 0 {
+		directives = append(directives, "s-maxage="+strconv.FormatInt(int64(cc.SMaxAge/time.Second), 10))
+	}
+	if cc.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if cc.Immutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// ETag 根据内容计算 ETag, weak 为 true 时生成弱校验值
+func ETag(data []byte, weak bool) string {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// bufferWriter 缓存渲染结果, 用于计算 ETag
+type bufferWriter struct {
+	header http.Header
+	body   []byte
+}
+
+func (w *bufferWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	w.body = append(w.body, b...)
+	return len(b), nil
+}
+
+func (w *bufferWriter) WriteHeader(int) {}
+
+// etagMatch 比较两个 ETag, weak 为 true 时使用弱比较
+func etagMatch(a, b string, weak bool) bool {
+	if weak {
+		return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+	}
+	return !strings.HasPrefix(a, "W/") && !strings.HasPrefix(b, "W/") && a == b
+}
+
+// checkIfNoneMatch 判断 If-None-Match 是否命中当前 ETag
+func checkIfNoneMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = textproto.TrimString(v)
+		if v == "*" || etagMatch(v, etag, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified 根据条件请求头判断是否可以返回 304
+func (c *Context) notModified() bool {
+	if c.R.Method != http.MethodGet && c.R.Method != http.MethodHead {
+		return false
+	}
+	if inm := c.R.Header.Get("If-None-Match"); inm != "" {
+		etag := c.W.Header().Get("ETag")
+		return etag != "" && checkIfNoneMatch(inm, etag)
+	}
+	ims := c.R.Header.Get("If-Modified-Since")
+	lm := c.W.Header().Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+func (c *Context) writeNotModified() {
+	h := c.W.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	delete(h, "Content-Encoding")
+	c.StatusCode = http.StatusNotModified
+	c.W.WriteHeader(http.StatusNotModified)
+}
+
+// checkIfRange If-Range 不满足时需要忽略 Range 返回完整内容
+func (c *Context) checkIfRange() bool {
+	ir := c.R.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+	if strings.HasPrefix(ir, `"`) || strings.HasPrefix(ir, "W/") {
+		return etagMatch(ir, c.W.Header().Get("ETag"), false)
+	}
+	lm := c.W.Header().Get("Last-Modified")
+	if lm == "" {
+		return false
+	}
+	t, err := http.ParseTime(ir)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return modified.Unix() == t.Unix()
+}
+
+type httpRange struct {
+	start, length int64
+}
+
+// parseRange 解析单个字节区间, 多区间或格式错误返回 nil 表示忽略 Range
+func parseRange(s string, size int64) (*httpRange, error) {
+	const b = "bytes="
+	if !strings.HasPrefix(s, b) {
+		return nil, nil
+	}
+	spec := textproto.TrimString(s[len(b):])
+	if strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	startStr, endStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, nil
+	}
+	startStr, endStr = textproto.TrimString(startStr), textproto.TrimString(endStr)
+	var r httpRange
+	if startStr == "" {
+		//后缀区间 bytes=-500
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, errUnsatisfiableRange
+		}
+		if n > size {
+			n = size
+		}
+		r.start = size - n
+		r.length = n
+		return &r, nil
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	if start >= size {
+		return nil, errUnsatisfiableRange
+	}
+	r.start = start
+	if endStr == "" {
+		r.length = size - start
+		return &r, nil
+	}
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil || start > end {
+		return nil, nil
+	}
+	if end >= size {
+		end = size - 1
+	}
+	r.length = end - start + 1
+	return &r, nil
+}
+
+// skipBytes 跳过 reader 前 n 个字节, 可 Seek 的直接定位
+func skipBytes(reader io.Reader, n int64) error {
+	if n == 0 {
+		return nil
+	}
+	if seeker, ok := reader.(io.Seeker); ok {
+		_, err := seeker.Seek(n, io.SeekCurrent)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, reader, n)
+	return err
+}
@@ -0,0 +1,203 @@
+package msgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/render"
+)
+
+func TestCacheControlString(t *testing.T) {
+	cases := []struct {
+		cc   CacheControl
+		want string
+	}{
+		{CacheControl{}, ""},
+		{CacheControl{NoStore: true}, "no-store"},
+		{CacheControl{Public: true, MaxAge: time.Hour, Immutable: true}, "public, max-age=3600, immutable"},
+		{CacheControl{Private: true, NoCache: true, SMaxAge: time.Minute, MustRevalidate: true}, "private, no-cache, s-maxage=60, must-revalidate"},
+	}
+	for _, c := range cases {
+		if got := c.cc.String(); got != c.want {
+			t.Errorf("%+v = %q, want %q", c.cc, got, c.want)
+		}
+	}
+}
+
+func TestCheckIfNoneMatch(t *testing.T) {
+	etag := ETag([]byte("data"), false)
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{etag, true},
+		{"W/" + etag, true},
+		{`"other", ` + etag, true},
+		{"*", true},
+		{`"other"`, false},
+	}
+	for _, c := range cases {
+		if got := checkIfNoneMatch(c.header, etag); got != c.want {
+			t.Errorf("checkIfNoneMatch(%q) = %v, want %v", c.header, got, c.want)
+		}
+	}
+}
+
+func TestRenderWithETag(t *testing.T) {
+	e := newTestEngine()
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	g := e.Group("api")
+	g.Get("/data", func(ctx *Context) {
+		ctx.SetLastModified(modified)
+		_ = ctx.RenderWithETag(&render.String{Format: "hello"}, http.StatusOK, false)
+	})
+	g.Post("/data", func(ctx *Context) {
+		_ = ctx.RenderWithETag(&render.String{Format: "hello"}, http.StatusOK, false)
+	})
+	g.Head("/data", func(ctx *Context) {
+		_ = ctx.RenderWithETag(&render.String{Format: "hello"}, http.StatusOK, false)
+	})
+	g.Get("/missing", func(ctx *Context) {
+		_ = ctx.RenderWithETag(&render.String{Format: "missing"}, http.StatusNotFound, false)
+	})
+	etag := ETag([]byte("hello"), false)
+
+	w := serve(e, http.MethodGet, "/api/data", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "hello" || w.Header().Get("ETag") != etag {
+		t.Fatalf("first request: %d %q %q", w.Code, w.Body, w.Header().Get("ETag"))
+	}
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		header []string
+		want   int
+	}{
+		{"if-none-match", http.MethodGet, "/api/data", []string{"If-None-Match", etag}, http.StatusNotModified},
+		{"weak if-none-match", http.MethodGet, "/api/data", []string{"If-None-Match", "W/" + etag}, http.StatusNotModified},
+		{"stale etag", http.MethodGet, "/api/data", []string{"If-None-Match", `"stale"`}, http.StatusOK},
+		//If-None-Match 存在时忽略 If-Modified-Since
+		{"etag wins", http.MethodGet, "/api/data", []string{"If-None-Match", `"stale"`, "If-Modified-Since", modified.Format(http.TimeFormat)}, http.StatusOK},
+		{"if-modified-since", http.MethodGet, "/api/data", []string{"If-Modified-Since", modified.Add(time.Hour).Format(http.TimeFormat)}, http.StatusNotModified},
+		{"modified since", http.MethodGet, "/api/data", []string{"If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{"head", http.MethodHead, "/api/data", []string{"If-None-Match", etag}, http.StatusNotModified},
+		{"unsafe method", http.MethodPost, "/api/data", []string{"If-None-Match", etag}, http.StatusOK},
+		//非 2xx 响应不计算 ETag
+		{"not found", http.MethodGet, "/api/missing", []string{"If-None-Match", "*"}, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		w := serve(e, c.method, c.path, nil, c.header...)
+		if w.Code != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, w.Code, c.want)
+		}
+		if w.Code == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("Content-Type") != "") {
+			t.Errorf("%s: 304 must not carry a body or Content-Type, got %q %q", c.name, w.Body, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		header        string
+		size          int64
+		start, length int64
+		ignored       bool
+		unsatisfiable bool
+	}{
+		{"bytes=0-4", 10, 0, 5, false, false},
+		{"bytes=5-", 10, 5, 5, false, false},
+		{"bytes=8-20", 10, 8, 2, false, false},
+		{"bytes=-3", 10, 7, 3, false, false},
+		{"bytes=-20", 10, 0, 10, false, false},
+		{"bytes= 2 - 3 ", 10, 2, 2, false, false},
+		{"bytes=10-", 10, 0, 0, false, true},
+		{"bytes=-0", 10, 0, 0, false, true},
+		{"bytes=-5", 0, 0, 0, false, true},
+		{"bytes=0-1,3-4", 10, 0, 0, true, false},
+		{"bytes=5-2", 10, 0, 0, true, false},
+		{"bytes=x-2", 10, 0, 0, true, false},
+		{"bytes=5", 10, 0, 0, true, false},
+		{"items=0-1", 10, 0, 0, true, false},
+	}
+	for _, c := range cases {
+		r, err := parseRange(c.header, c.size)
+		switch {
+		case c.unsatisfiable:
+			if err != errUnsatisfiableRange {
+				t.Errorf("parseRange(%q, %d) error = %v, want unsatisfiable", c.header, c.size, err)
+			}
+		case c.ignored:
+			if r != nil || err != nil {
+				t.Errorf("parseRange(%q, %d) = %+v, %v, want ignored", c.header, c.size, r, err)
+			}
+		default:
+			if err != nil || r == nil || r.start != c.start || r.length != c.length {
+				t.Errorf("parseRange(%q, %d) = %+v, %v, want start %d length %d", c.header, c.size, r, err, c.start, c.length)
+			}
+		}
+	}
+}
+
+func TestCheckIfRange(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	etag := `"v1"`
+	cases := []struct {
+		ifRange string
+		etag    string
+		want    bool
+	}{
+		{"", etag, true},
+		{etag, etag, true},
+		{`"v2"`, etag, false},
+		//If-Range 必须使用强比较
+		{"W/" + etag, "W/" + etag, false},
+		{modified.Format(http.TimeFormat), etag, true},
+		{modified.Add(time.Second).Format(http.TimeFormat), etag, false},
+		{"not a date", etag, false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c.ifRange != "" {
+			r.Header.Set("If-Range", c.ifRange)
+		}
+		w := httptest.NewRecorder()
+		w.Header().Set("ETag", c.etag)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		ctx := &Context{W: w, R: r}
+		if got := ctx.checkIfRange(); got != c.want {
+			t.Errorf("checkIfRange(%q, etag %q) = %v, want %v", c.ifRange, c.etag, got, c.want)
+		}
+	}
+}
+
+func TestDataFromReaderRange(t *testing.T) {
+	e := newTestEngine()
+	e.Group("api").Get("/file", func(ctx *Context) {
+		ctx.W.Header().Set("ETag", `"v1"`)
+		_ = ctx.DataFromReader(http.StatusOK, 10, "text/plain", strings.NewReader("0123456789"))
+	})
+	cases := []struct {
+		name         string
+		header       []string
+		code         int
+		body         string
+		contentRange string
+	}{
+		{"full", nil, http.StatusOK, "0123456789", ""},
+		{"range", []string{"Range", "bytes=2-4"}, http.StatusPartialContent, "234", "bytes 2-4/10"},
+		{"suffix", []string{"Range", "bytes=-2"}, http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"unsatisfiable", []string{"Range", "bytes=20-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"if-range match", []string{"Range", "bytes=0-0", "If-Range", `"v1"`}, http.StatusPartialContent, "0", "bytes 0-0/10"},
+		{"if-range stale", []string{"Range", "bytes=0-0", "If-Range", `"v0"`}, http.StatusOK, "0123456789", ""},
+		{"multi range", []string{"Range", "bytes=0-1,4-5"}, http.StatusOK, "0123456789", ""},
+	}
+	for _, c := range cases {
+		w := serve(e, http.MethodGet, "/api/file", nil, c.header...)
+		if w.Code != c.code || w.Body.String() != c.body || w.Header().Get("Content-Range") != c.contentRange {
+			t.Errorf("%s: got %d %q %q", c.name, w.Code, w.Body, w.Header().Get("Content-Range"))
+		}
+	}
+}
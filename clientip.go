@@ -0,0 +1,64 @@
+package msgo
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/liyuanwu2020/msgo/internal/clientip"
+)
+
+// SetTrustedProxies 配置可信代理的 CIDR 或 IP, 只有来自可信代理的请求才解析转发头
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs, err := clientip.ParseCIDRs(proxies)
+	if err != nil {
+		return err
+	}
+	e.trustedProxies = cidrs
+	return nil
+}
+
+func (c *Context) trustedProxies() clientip.TrustedProxies {
+	if c.engine == nil {
+		return nil
+	}
+	return c.engine.trustedProxies
+}
+
+// ClientIP 获取客户端 IP, 请求来自可信代理时依次解析 Forwarded, X-Forwarded-For, X-Real-IP
+// 转发链从右向左跳过可信代理, 第一个不可信的地址即客户端地址
+func (c *Context) ClientIP() net.IP {
+	return c.trustedProxies().ClientIP(c.R)
+}
+
+// Scheme 获取请求协议, 可信代理转发时使用 Forwarded proto 或 X-Forwarded-Proto
+func (c *Context) Scheme() string {
+	return c.trustedProxies().Scheme(c.R)
+}
+
+// Host 获取请求主机名, 可信代理转发时使用 Forwarded host 或 X-Forwarded-Host
+func (c *Context) Host() string {
+	return c.trustedProxies().Host(c.R)
+}
+
+// IPFilter 根据客户端 IP 过滤请求, deny 优先, allow 为空时允许所有未被拒绝的地址
+func IPFilter(allow, deny []string) MiddlewareFunc {
+	allowCIDRs, err := clientip.ParseCIDRs(allow)
+	if err != nil {
+		panic(err)
+	}
+	denyCIDRs, err := clientip.ParseCIDRs(deny)
+	if err != nil {
+		panic(err)
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			ip := ctx.ClientIP()
+			if denyCIDRs.Contains(ip) || (len(allowCIDRs) > 0 && !allowCIDRs.Contains(ip)) {
+				ctx.StatusCode = http.StatusForbidden
+				ctx.W.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next(ctx)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package msgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newProxyContext(t *testing.T, remote string, header ...string) *Context {
+	t.Helper()
+	e := newTestEngine()
+	if err := e.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://origin.example/", nil)
+	r.RemoteAddr = remote
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Add(header[i], header[i+1])
+	}
+	return &Context{R: r, engine: e}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		remote string
+		header []string
+		want   string
+	}{
+		{"1.2.3.4:80", []string{"X-Forwarded-For", "9.9.9.9"}, "1.2.3.4"},
+		{"10.0.0.1:80", []string{"X-Forwarded-For", "9.9.9.9, 5.5.5.5, 10.0.0.2"}, "5.5.5.5"},
+		{"10.0.0.1:80", []string{"X-Forwarded-For", "9.9.9.9", "X-Forwarded-For", "10.0.0.2"}, "9.9.9.9"},
+		{"10.0.0.1:80", []string{"Forwarded", `for=9.9.9.9, for="[2001:db8::1]:443", for=10.0.0.2`}, "2001:db8::1"},
+		{"10.0.0.1:80", []string{"X-Real-IP", "7.7.7.7"}, "7.7.7.7"},
+		{"10.0.0.1:80", nil, "10.0.0.1"},
+	}
+	for _, c := range cases {
+		if got := newProxyContext(t, c.remote, c.header...).ClientIP().String(); got != c.want {
+			t.Errorf("ClientIP(%s, %q) = %s, want %s", c.remote, c.header, got, c.want)
+		}
+	}
+}
+
+func TestSchemeAndHost(t *testing.T) {
+	cases := []struct {
+		remote       string
+		header       []string
+		scheme, host string
+	}{
+		//不可信来源的转发头被忽略
+		{"1.2.3.4:80", []string{"X-Forwarded-Proto", "https", "X-Forwarded-Host", "evil.example"}, "http", "origin.example"},
+		{"10.0.0.1:80", []string{"X-Forwarded-Proto", "https", "X-Forwarded-Host", "api.example"}, "https", "api.example"},
+		//客户端伪造的最左侧值被跳过, 使用离客户端最近的可信代理记录的值
+		{"10.0.0.1:80", []string{
+			"X-Forwarded-For", "9.9.9.9, 10.0.0.2",
+			"X-Forwarded-Proto", "https, http, https",
+			"X-Forwarded-Host", "evil.example, api.example, internal",
+		}, "http", "api.example"},
+		{"10.0.0.1:80", []string{
+			"X-Forwarded-For", "9.9.9.9",
+			"X-Forwarded-Proto", "HTTPS",
+			"X-Forwarded-Proto", "http",
+			"X-Forwarded-Host", "evil.example",
+			"X-Forwarded-Host", "api.example",
+		}, "http", "api.example"},
+		{"10.0.0.1:80", []string{"Forwarded", `for=9.9.9.9;proto=http;host=evil.example, for=5.5.5.5;proto=https;host=api.example`}, "https", "api.example"},
+		{"10.0.0.1:80", []string{"Forwarded", `for=5.5.5.5;proto=https;host=api.example, for=10.0.0.2;proto=http;host=internal`}, "https", "api.example"},
+		//离客户端最近的代理没有记录时使用更靠后的可信代理的值
+		{"10.0.0.1:80", []string{"Forwarded", `for=5.5.5.5, for=10.0.0.2;proto=https;host=lb.example`}, "https", "lb.example"},
+	}
+	for _, c := range cases {
+		ctx := newProxyContext(t, c.remote, c.header...)
+		if got := ctx.Scheme(); got != c.scheme {
+			t.Errorf("Scheme(%s, %q) = %s, want %s", c.remote, c.header, got, c.scheme)
+		}
+		if got := ctx.Host(); got != c.host {
+			t.Errorf("Host(%s, %q) = %s, want %s", c.remote, c.header, got, c.host)
+		}
+	}
+}
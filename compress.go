@@ -0,0 +1,304 @@
+package msgo
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var defaultExcludedTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/pdf", "application/octet-stream",
+}
+
+// CompressConfig 响应压缩配置
+type CompressConfig struct {
+	//Level 压缩级别, 默认 gzip.DefaultCompression
+	Level int
+	//MinLength 小于该长度的响应不压缩, 默认 1024 字节
+	MinLength int
+	//ExcludedTypes 不压缩的 Content-Type 前缀, 默认跳过图片、音视频和压缩包
+	ExcludedTypes []string
+	//DisableRequestDecompress 关闭请求体 gzip/deflate 自动解压
+	DisableRequestDecompress bool
+	//MaxDecompressedBytes 解压后请求体大小上限, 超出时读取返回 413, 防止解压炸弹;
+	//默认使用 Engine.BodyLimit.MaxBytes, 未配置时为 32MB
+	MaxDecompressedBytes int64
+}
+
+const defaultMaxDecompressedBytes = 32 << 20
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compress 使用默认配置的压缩中间件
+func Compress(next HandlerFunc) HandlerFunc {
+	return defaultCompress(next)
+}
+
+var defaultCompress = CompressWithConfig(CompressConfig{})
+
+func CompressWithConfig(conf CompressConfig) MiddlewareFunc {
+	if conf.Level == 0 {
+		conf.Level = gzip.DefaultCompression
+	}
+	if conf.MinLength <= 0 {
+		conf.MinLength = 1024
+	}
+	if conf.ExcludedTypes == nil {
+		conf.ExcludedTypes = defaultExcludedTypes
+	}
+	//压缩 writer 池化复用
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() any {
+			w, _ := gzip.NewWriterLevel(io.Discard, conf.Level)
+			return w
+		}},
+		"deflate": {New: func() any {
+			w, _ := zlib.NewWriterLevel(io.Discard, conf.Level)
+			return w
+		}},
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if !conf.DisableRequestDecompress {
+				limit := conf.MaxDecompressedBytes
+				if limit <= 0 && ctx.engine != nil {
+					limit = ctx.engine.BodyLimit.MaxBytes
+				}
+				if limit <= 0 {
+					limit = defaultMaxDecompressedBytes
+				}
+				if err := decompressRequest(ctx.W, ctx.R, limit); err != nil {
+					ctx.Fail(http.StatusBadRequest, err.Error())
+					return
+				}
+			}
+			encoding := negotiateEncoding(ctx.R.Header.Get("Accept-Encoding"))
+			if encoding == "" || ctx.R.Method == http.MethodHead {
+				next(ctx)
+				return
+			}
+			ctx.W.Header().Add("Vary", "Accept-Encoding")
+			cw := &compressWriter{
+				ResponseWriter: ctx.W,
+				conf:           &conf,
+				encoding:       encoding,
+				pool:           pools[encoding],
+			}
+			ctx.W = cw
+			defer func() {
+				cw.close()
+				ctx.W = cw.ResponseWriter
+			}()
+			next(ctx)
+		}
+	}
+}
+
+// negotiateEncoding 根据 Accept-Encoding 选择 gzip 或 deflate
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		if name == "*" {
+			name = "gzip"
+		}
+		if (name == "gzip" || name == "deflate") && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// decompressRequest 解压 gzip/deflate 编码的请求体, 解压后最多读取 limit 字节
+func decompressRequest(w http.ResponseWriter, r *http.Request, limit int64) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(r.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(r.Body)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.Body = &decompressBody{ReadCloser: http.MaxBytesReader(w, reader, limit), body: r.Body}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+type decompressBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (b *decompressBody) Close() error {
+	err := b.ReadCloser.Close()
+	if bodyErr := b.body.Close(); err == nil {
+		err = bodyErr
+	}
+	return err
+}
+
+// compressWriter 先缓冲响应, 达到 MinLength 或 Flush 时决定是否压缩
+type compressWriter struct {
+	http.ResponseWriter
+	conf     *CompressConfig
+	encoding string
+	pool     *sync.Pool
+	writer   compressor
+	buf      []byte
+	status   int
+	decided  bool
+}
+
+// Unwrap 供 http.ResponseController 等访问原始 ResponseWriter
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.writer != nil {
+			return w.writer.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.conf.MinLength {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide 根据状态码、内容类型和长度决定是否压缩, 并写出缓冲数据
+func (w *compressWriter) decide(bigEnough bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if bigEnough && w.shouldCompress() {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.writer = w.pool.Get().(compressor)
+		w.writer.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	var err error
+	if w.writer != nil {
+		_, err = w.writer.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	h := w.Header()
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified || w.status == http.StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf)
+		h.Set("Content-Type", contentType)
+	}
+	contentType = strings.ToLower(contentType)
+	for _, t := range w.conf.ExcludedTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush 流式响应, 已缓冲的数据立即压缩输出
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.writer != nil {
+		_ = w.writer.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.decided = true
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijack")
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			//处理器未写入任何内容
+			w.decided = true
+			return
+		}
+		_ = w.decide(len(w.buf) >= w.conf.MinLength)
+	}
+	if w.writer != nil {
+		_ = w.writer.Close()
+		w.writer.Reset(io.Discard)
+		w.pool.Put(w.writer)
+		w.writer = nil
+	}
+}
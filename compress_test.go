@@ -0,0 +1,120 @@
+package msgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct{ header, want string }{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip;q=0.5", "deflate"},
+		{"gzip;q=0, deflate;q=0.1", "deflate"},
+		{"br", ""},
+		{"*", "gzip"},
+		{"identity", ""},
+	}
+	for _, c := range cases {
+		if got := negotiateEncoding(c.header); got != c.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", c.header, got, c.want)
+		}
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	large := strings.Repeat("hello msgo ", 200)
+	e := newTestEngine()
+	g := e.Group("c")
+	g.Get("/large", func(ctx *Context) { _ = ctx.String(http.StatusOK, large) }, Compress)
+	g.Get("/small", func(ctx *Context) { _ = ctx.String(http.StatusOK, "hi") }, Compress)
+	g.Get("/png", func(ctx *Context) {
+		ctx.W.Header().Set("Content-Type", "image/png")
+		_, _ = ctx.W.Write([]byte(large))
+	}, Compress)
+
+	w := serve(e, http.MethodGet, "/c/large", nil, "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("large response headers %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != large {
+		t.Fatalf("gzip body does not round trip, got %d bytes", len(data))
+	}
+
+	w = serve(e, http.MethodGet, "/c/large", nil, "Accept-Encoding", "deflate")
+	zlr, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("deflate response: %v, headers %v", err, w.Header())
+	}
+	if data, _ := io.ReadAll(zlr); string(data) != large {
+		t.Fatalf("deflate body does not round trip, got %d bytes", len(data))
+	}
+
+	for _, c := range []struct{ path, encoding string }{
+		{"/c/small", "gzip"},
+		{"/c/png", "gzip"},
+		{"/c/large", ""},
+	} {
+		w := serve(e, http.MethodGet, c.path, nil, "Accept-Encoding", c.encoding)
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("GET %s with %q must not be compressed", c.path, c.encoding)
+		}
+	}
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressRequest(t *testing.T) {
+	e := newTestEngine()
+	handler := func(ctx *Context) {
+		var v map[string]any
+		if err := ctx.BindJson(&v); err != nil {
+			return
+		}
+		_ = ctx.JSON(http.StatusOK, v)
+	}
+	g := e.Group("d")
+	g.Post("/json", handler, CompressWithConfig(CompressConfig{MaxDecompressedBytes: 1024}))
+	g.Post("/raw", handler, CompressWithConfig(CompressConfig{DisableRequestDecompress: true}))
+
+	body := gzipBytes(t, []byte(`{"name":"msgo"}`))
+	w := serve(e, http.MethodPost, "/d/json", bytes.NewReader(body), "Content-Encoding", "gzip", "Content-Type", "application/json")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"msgo"`) {
+		t.Fatalf("gzip request = %d %s", w.Code, w.Body)
+	}
+
+	w = serve(e, http.MethodPost, "/d/json", strings.NewReader("not gzip"), "Content-Encoding", "gzip")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid gzip request = %d, want 400", w.Code)
+	}
+
+	//压缩后很小的请求体解压后超出上限
+	bomb := gzipBytes(t, []byte(`{"name":"`+strings.Repeat("a", 1<<20)+`"}`))
+	w = serve(e, http.MethodPost, "/d/json", bytes.NewReader(bomb), "Content-Encoding", "gzip", "Content-Type", "application/json")
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("decompression bomb of %d bytes = %d, want 413", len(bomb), w.Code)
+	}
+
+	w = serve(e, http.MethodPost, "/d/raw", bytes.NewReader(body), "Content-Encoding", "gzip", "Content-Type", "application/json")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("disabled decompression must pass the raw body through, got %d", w.Code)
+	}
+}
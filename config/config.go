@@ -5,6 +5,7 @@ import (
 	"github.com/BurntSushi/toml"
 	"github.com/liyuanwu2020/msgo/mslog"
 	"os"
+	"strings"
 )
 
 var Conf = &MsConfig{
@@ -15,22 +16,71 @@ var Conf = &MsConfig{
 type MsConfig struct {
 	Log    map[string]any
 	Logger *mslog.Logger
+	Cors   CorsConf
+	Server ServerConf
 }
 
+// ServerConf app.toml 中的 [server] 配置
+type ServerConf struct {
+	//MaxBodySize 请求体大小上限(字节), 0 不限制
+	MaxBodySize int64 `toml:"max_body_size"`
+	//MinReadRate 请求体最低读取速率(字节/秒), 0 不限制
+	MinReadRate int64 `toml:"min_read_rate"`
+	//ReadRateGrace 开始检查读取速率前的宽限时间(秒)
+	ReadRateGrace int `toml:"read_rate_grace"`
+}
+
+// CorsConf app.toml 中的 [cors] 配置
+type CorsConf struct {
+	Enable           bool     `toml:"enable"`
+	AllowOrigins     []string `toml:"allow_origins"`
+	AllowMethods     []string `toml:"allow_methods"`
+	AllowHeaders     []string `toml:"allow_headers"`
+	ExposeHeaders    []string `toml:"expose_headers"`
+	AllowCredentials bool     `toml:"allow_credentials"`
+	MaxAge           int      `toml:"max_age"`
+}
+
+// confFile 注册到默认 FlagSet, 应用自行调用 flag.Parse 时可以识别 -conf
+var confFile = flag.String("conf", "config/app.toml", "app config file")
+
 func init() {
 	LoadToml()
 }
 
 func LoadToml() {
-	confFile := flag.String("conf", "config/app.toml", "app config file")
-	flag.Parse()
-	if _, err := os.Stat(*confFile); err != nil {
+	path := confPath(os.Args[1:])
+	if _, err := os.Stat(path); err != nil {
 		Conf.Logger.Info("config/app.toml file not exist")
 		return
 	}
-	_, err := toml.DecodeFile(*confFile, Conf)
+	_, err := toml.DecodeFile(path, Conf)
 	if err != nil {
 		Conf.Logger.Info("config/app.toml decode fail check format")
 		return
 	}
 }
+
+// confPath 从命令行参数中读取 -conf, 不调用 flag.Parse, 避免 init 时拒绝应用和 go test 的其他参数
+func confPath(args []string) string {
+	for i, arg := range args {
+		//-- 之后都是位置参数, 与 flag 包的处理一致
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "conf" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return *confFile
+}
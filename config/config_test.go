@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestConfPath(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "config/app.toml"},
+		{[]string{"-conf", "a.toml"}, "a.toml"},
+		{[]string{"--conf=b.toml"}, "b.toml"},
+		{[]string{"-test.v", "-port", "80", "-conf=c.toml"}, "c.toml"},
+		{[]string{"-config", "x.toml"}, "config/app.toml"},
+		{[]string{"--", "-conf", "x.toml"}, "config/app.toml"},
+	}
+	for _, c := range cases {
+		if got := confPath(c.args); got != c.want {
+			t.Errorf("confPath(%v) = %q, want %q", c.args, got, c.want)
+		}
+	}
+}
@@ -2,9 +2,13 @@ package msgo
 
 import (
 	"errors"
+	"fmt"
 	"github.com/liyuanwu2020/msgo/binding"
+	"github.com/liyuanwu2020/msgo/mserror"
 	msLog "github.com/liyuanwu2020/msgo/mslog"
 	"github.com/liyuanwu2020/msgo/render"
+	"github.com/liyuanwu2020/msgo/securecookie"
+	"github.com/liyuanwu2020/msgo/sessions"
 	"github.com/liyuanwu2020/msgo/validator"
 	"html/template"
 	"io"
@@ -12,9 +16,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 const defaultMultipartMemory = 32 << 20
@@ -34,6 +39,39 @@ type Context struct {
 	Keys                  map[string]any
 	mu                    sync.RWMutex
 	sameSite              http.SameSite
+	session               *sessions.Session
+	uploadConfig          *UploadConfig
+	requestID             string
+	cspNonce              string
+	csrfSecret            []byte
+	csrfToken             string
+	csrfField             string
+	rawBody               io.ReadCloser
+	bodyErrWritten        bool
+	groupName             string
+	errors                []error
+}
+
+// reset 重置池化 Context 的请求级数据
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.W = w
+	c.R = r
+	c.NodeRouterName = ""
+	c.RequestMethod = ""
+	c.queryCache = nil
+	c.StatusCode = 0
+	c.Keys = nil
+	c.session = nil
+	c.uploadConfig = nil
+	c.requestID = ""
+	c.cspNonce = ""
+	c.csrfSecret = nil
+	c.csrfToken = ""
+	c.csrfField = ""
+	c.rawBody = nil
+	c.bodyErrWritten = false
+	c.groupName = ""
+	c.errors = nil
 }
 
 func (c *Context) SetSameSite(s http.SameSite) {
@@ -72,7 +110,10 @@ func (c *Context) BindJson(obj any) error {
 func (c *Context) MustBindWith(obj any, b binding.Binding) error {
 	//如果发生错误，返回400状态码 参数错误
 	if err := c.ShouldBindWith(obj, b); err != nil {
-		c.W.WriteHeader(http.StatusBadRequest)
+		err = c.checkBodyError(err)
+		if !c.bodyErrWritten {
+			c.W.WriteHeader(http.StatusBadRequest)
+		}
 		return err
 	}
 	return nil
@@ -82,7 +123,12 @@ func (c *Context) ShouldBindWith(obj any, b binding.Binding) error {
 	return b.Bind(c.R, obj)
 }
 
+// SaveUploadedFile 保存上传文件, 按上传配置校验大小和类型, 配置 Root 后只能保存在上传根目录下, 未配置时只能使用相对路径
 func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dstName string) error {
+	conf := c.uploadConf()
+	if conf.MaxFileSize > 0 && file.Size > conf.MaxFileSize {
+		return ErrUploadTooLarge
+	}
 	src, err := file.Open()
 	if err != nil {
 		return err
@@ -93,31 +139,21 @@ func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dstName string) e
 
 		}
 	}(src)
-	dst, err := os.Create(dstName)
-	if err != nil {
-		return err
-	}
-	defer func(dst *os.File) {
-		err := dst.Close()
-		if err != nil {
-
-		}
-	}(dst)
-
-	_, err = io.Copy(dst, src)
+	_, err = conf.saveStream(src, SanitizeFilename(file.Filename), dstName)
 	return err
 }
 
 // FormFile 获取文件
 func (c *Context) FormFile(key string) (*multipart.FileHeader, error) {
+	if _, err := c.MultipartForm(); err != nil {
+		return nil, err
+	}
 	file, header, err := c.R.FormFile(key)
-	defer func(file multipart.File) {
-		err := file.Close()
-		if err != nil {
-
-		}
-	}(file)
-	return header, err
+	if err != nil {
+		return nil, err
+	}
+	_ = file.Close()
+	return header, nil
 }
 
 func (c *Context) FormFiles(key string) []*multipart.FileHeader {
@@ -129,12 +165,28 @@ func (c *Context) FormFiles(key string) []*multipart.FileHeader {
 }
 
 func (c *Context) MultipartForm() (*multipart.Form, error) {
-	err := c.R.ParseMultipartForm(defaultMultipartMemory)
+	err := c.parseMultipartForm()
 	return c.R.MultipartForm, err
 }
 
+// parseMultipartForm 按上传配置限制请求体大小和内存
+func (c *Context) parseMultipartForm() error {
+	if c.R.MultipartForm != nil {
+		return nil
+	}
+	conf := c.uploadConf()
+	c.limitBody(conf)
+	//ParseMultipartForm 对非 multipart 请求会忽略 ParseForm 的错误, 先单独解析
+	if c.R.Form == nil {
+		if err := c.R.ParseForm(); err != nil {
+			return c.checkBodyError(err)
+		}
+	}
+	return c.checkBodyError(c.R.ParseMultipartForm(conf.maxMemory()))
+}
+
 func (c *Context) GetPost(key string) (string, error) {
-	if err := c.R.ParseMultipartForm(defaultMultipartMemory); err != nil {
+	if err := c.parseMultipartForm(); err != nil {
 		if !errors.Is(err, http.ErrNotMultipart) {
 			return "", err
 		}
@@ -143,7 +195,7 @@ func (c *Context) GetPost(key string) (string, error) {
 }
 
 func (c *Context) GetAllPost() (url.Values, error) {
-	if err := c.R.ParseMultipartForm(defaultMultipartMemory); err != nil {
+	if err := c.parseMultipartForm(); err != nil {
 		if !errors.Is(err, http.ErrNotMultipart) {
 			return nil, err
 		}
@@ -208,24 +260,91 @@ func (c *Context) initQueryCache() {
 }
 
 func (c *Context) HTMLTemplate(name string, data any, files ...string) error {
-	c.W.Header().Set("Content-Type", "text/html;charset=utf-8")
-	t := template.New(name)
-	parseFiles, err := t.ParseFiles(files...)
+	t, err := c.cachedTemplate("files:"+name+":"+strings.Join(files, ","), func() (*template.Template, error) {
+		return template.New(name).Funcs(c.engine.templateFuncMap()).ParseFiles(files...)
+	})
 	if err != nil {
-		return err
+		return c.templateError(name, err)
 	}
-	err = parseFiles.Execute(c.W, data)
-	return err
+	return c.executeTemplate("files:"+name+":"+strings.Join(files, ","), t, name, data)
 }
 
 func (c *Context) HTMLTemplateGlob(name string, data any, pattern string) error {
-	c.W.Header().Set("Content-Type", "text/html;charset=utf-8")
-	t := template.New(name)
-	parseFiles, err := t.ParseGlob(pattern)
+	t, err := c.cachedTemplate("glob:"+name+":"+pattern, func() (*template.Template, error) {
+		return template.New(name).Funcs(c.engine.templateFuncMap()).ParseGlob(pattern)
+	})
 	if err != nil {
-		return err
+		return c.templateError(name, err)
+	}
+	return c.executeTemplate("glob:"+name+":"+pattern, t, name, data)
+}
+
+// cachedTemplate 缓存解析后的模板, 开发模式下每次重新解析
+func (c *Context) cachedTemplate(key string, parse func() (*template.Template, error)) (*template.Template, error) {
+	if !c.engine.DevMode {
+		if t, ok := c.engine.templateCache.Load(key); ok {
+			return t.(*template.Template), nil
+		}
+	}
+	t, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	c.engine.templateCache.Store(key, t)
+	return t, nil
+}
+
+// TemplateSet 使用命名模板集合渲染页面
+func (c *Context) TemplateSet(setName, page string, data any) error {
+	set, ok := c.engine.templateSets[setName]
+	if !ok {
+		return c.templateError(page, fmt.Errorf("template set %s not found", setName))
+	}
+	t, name, err := set.Lookup(page)
+	if err != nil {
+		return c.templateError(page, err)
+	}
+	return c.executeTemplate("set:"+setName+":"+page, t, name, data)
+}
+
+// executeTemplate 开发模式下先渲染到缓冲区, 出错时输出调试页面, key 标识模板来源
+func (c *Context) executeTemplate(key string, t *template.Template, name string, data any) error {
+	if t == nil {
+		return c.templateError(name, errors.New("template not loaded"))
+	}
+	rts, err := c.engine.requestTemplates(key, t)
+	if err != nil {
+		return c.templateError(name, err)
+	}
+	//启用 CSRF 或 CSP nonce 时使用注入了当前请求模板函数的副本
+	if c.csrfSecret != nil || c.cspNonce != "" {
+		rt, err := rts.get(c)
+		if err != nil {
+			return c.templateError(name, err)
+		}
+		defer rts.put(rt)
+		t = rt.t
+	}
+	r := &render.HTML{
+		Name:       name,
+		Data:       data,
+		Template:   t,
+		IsTemplate: true,
+	}
+	if !c.engine.DevMode {
+		return c.Render(r, http.StatusOK)
+	}
+	bw := &bufferWriter{header: make(http.Header)}
+	if err := r.Render(bw); err != nil {
+		return c.templateError(name, err)
+	}
+	return c.Data(http.StatusOK, "text/html;charset=utf-8", bw.body)
+}
+
+func (c *Context) templateError(name string, err error) error {
+	if c.engine.DevMode {
+		_ = c.Render(&render.DebugError{Name: name, Err: err}, http.StatusInternalServerError)
 	}
-	err = parseFiles.Execute(c.W, data)
 	return err
 }
 
@@ -234,14 +353,16 @@ func (c *Context) HTML(status int, html string) error {
 	return c.Render(&render.HTML{Data: html}, status)
 }
 
-// Template 带模板的 html
+// Template 带模板的 html, 开发模式下重新解析 LoadTemplate 加载的模板
 func (c *Context) Template(name string, data any) error {
-	return c.Render(&render.HTML{
-		Name:       name,
-		Data:       data,
-		Template:   c.engine.HTMLRender.Template,
-		IsTemplate: true,
-	}, http.StatusOK)
+	t := c.engine.HTMLRender.Template
+	if c.engine.DevMode && c.engine.htmlLoader != nil {
+		var err error
+		if t, err = c.cachedTemplate("html_render", c.engine.htmlLoader); err != nil {
+			return c.templateError(name, err)
+		}
+	}
+	return c.executeTemplate("html_render", t, name, data)
 }
 
 func (c *Context) JSON(status int, data any) error {
@@ -254,7 +375,6 @@ func (c *Context) XML(status int, data any) error {
 
 // String 字符串
 func (c *Context) String(status int, format string, values ...any) error {
-	c.W.WriteHeader(status)
 	err := c.Render(&render.String{
 		Format: format,
 		Values: values,
@@ -304,17 +424,132 @@ func (c *Context) Render(r render.Render, statusCode int) error {
 	return r.Render(c.W)
 }
 
+// Data 输出字节数据
+func (c *Context) Data(status int, contentType string, data []byte) error {
+	return c.Render(&render.Data{ContentType: contentType, Data: data}, status)
+}
+
+// RenderWithETag 渲染结果计算 ETag, 命中 If-None-Match 或 If-Modified-Since 时返回 304
+func (c *Context) RenderWithETag(r render.Render, statusCode int, weak bool) error {
+	bw := &bufferWriter{header: c.W.Header()}
+	r.WriteContentType(bw)
+	if err := r.Render(bw); err != nil {
+		return err
+	}
+	if statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices {
+		c.W.Header().Set("ETag", ETag(bw.body, weak))
+		if c.notModified() {
+			c.writeNotModified()
+			return nil
+		}
+	}
+	c.StatusCode = statusCode
+	c.W.WriteHeader(statusCode)
+	if c.R.Method == http.MethodHead {
+		return nil
+	}
+	_, err := c.W.Write(bw.body)
+	return err
+}
+
+// SetLastModified 设置 Last-Modified, 配合 RenderWithETag 处理 If-Modified-Since
+func (c *Context) SetLastModified(t time.Time) {
+	if !t.IsZero() {
+		c.W.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// SetCacheControl 设置 Cache-Control 缓存策略
+func (c *Context) SetCacheControl(cc CacheControl) {
+	if v := cc.String(); v != "" {
+		c.W.Header().Set("Cache-Control", v)
+	}
+}
+
+// DataFromReader 从 reader 输出数据, size 已知时支持单个 Range 区间
+func (c *Context) DataFromReader(status int, size int64, contentType string, reader io.Reader) error {
+	h := c.W.Header()
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	if size < 0 {
+		c.StatusCode = status
+		c.W.WriteHeader(status)
+		if c.R.Method == http.MethodHead {
+			return nil
+		}
+		_, err := io.Copy(c.W, reader)
+		return err
+	}
+	h.Set("Accept-Ranges", "bytes")
+	if rangeHeader := c.R.Header.Get("Range"); status == http.StatusOK && rangeHeader != "" && c.checkIfRange() {
+		ra, err := parseRange(rangeHeader, size)
+		if err != nil {
+			h.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			c.StatusCode = http.StatusRequestedRangeNotSatisfiable
+			c.W.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return nil
+		}
+		if ra != nil {
+			if err := skipBytes(reader, ra.start); err != nil {
+				return err
+			}
+			h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", ra.start, ra.start+ra.length-1, size))
+			h.Set("Content-Length", strconv.FormatInt(ra.length, 10))
+			c.StatusCode = http.StatusPartialContent
+			c.W.WriteHeader(http.StatusPartialContent)
+			if c.R.Method == http.MethodHead {
+				return nil
+			}
+			_, err := io.CopyN(c.W, reader, ra.length)
+			return err
+		}
+	}
+	h.Set("Content-Length", strconv.FormatInt(size, 10))
+	c.StatusCode = status
+	c.W.WriteHeader(status)
+	if c.R.Method == http.MethodHead {
+		return nil
+	}
+	_, err := io.CopyN(c.W, reader, size)
+	return err
+}
+
+// Fail 输出错误信息, msg 为字符串或 error 时输出文本, 其他类型输出 JSON
 func (c *Context) Fail(code int, msg any) {
-	_ = c.String(code, msg.(string))
+	switch m := msg.(type) {
+	case string:
+		_ = c.String(code, "%s", m)
+	case error:
+		_ = c.String(code, "%s", m.Error())
+	case fmt.Stringer:
+		_ = c.String(code, "%s", m.String())
+	default:
+		_ = c.JSON(code, msg)
+	}
 }
 
+// HandleWithError 出错时记录错误, 交给注册的错误处理器, 未注册时按 Engine.ErrorFormat 输出业务错误
 func (c *Context) HandleWithError(statusCode int, obj any, err error) {
 	if err != nil {
-		code, data := c.engine.errorHandler(err)
-		err = c.JSON(code, data)
-	} else {
-		err = c.JSON(statusCode, obj)
+		c.Error(err)
+		c.logError(err)
+		c.renderError(err)
+		return
 	}
+	_ = c.JSON(statusCode, obj)
+}
+
+// RenderError 输出业务错误, 非 mserror.Error 按 500 输出且不暴露原始错误
+func (c *Context) RenderError(err error) error {
+	e := mserror.Wrap(err)
+	if c.engine.ErrorFormat == ErrorFormatEnvelope {
+		return c.JSON(e.Status, mserror.NewEnvelope(e))
+	}
+	return c.Render(&render.JSON{
+		Data:        mserror.NewProblem(e, c.R.URL.Path),
+		ContentType: mserror.ProblemContentType,
+	}, e.Status)
 }
 
 func (c *Context) SetBasicAuth(username, password string) {
@@ -322,12 +557,16 @@ func (c *Context) SetBasicAuth(username, password string) {
 }
 
 func (c *Context) SetCookie(name, value string, maxAge int, path string, domain string, secure, httpOnly bool) {
+	c.setCookie(name, url.QueryEscape(value), maxAge, path, domain, secure, httpOnly)
+}
+
+func (c *Context) setCookie(name, value string, maxAge int, path string, domain string, secure, httpOnly bool) {
 	if path == "" {
 		path = "/"
 	}
 	http.SetCookie(c.W, &http.Cookie{
 		Name:     name,
-		Value:    url.QueryEscape(value),
+		Value:    value,
 		MaxAge:   maxAge,
 		Path:     path,
 		Domain:   domain,
@@ -342,5 +581,92 @@ func (c *Context) GetCookie(name string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return cookie.String(), nil
+	return url.QueryUnescape(cookie.Value)
+}
+
+// SetSignedCookie HMAC 签名的 cookie, 密钥通过 Engine.SetCookieKeys 配置
+func (c *Context) SetSignedCookie(name, value string, maxAge int, path string, domain string, secure, httpOnly bool) error {
+	return c.setSecureCookie(c.engine.signedCookie, name, value, maxAge, path, domain, secure, httpOnly)
+}
+
+// GetSignedCookie 读取并校验签名 cookie
+func (c *Context) GetSignedCookie(name string) (string, error) {
+	return c.getSecureCookie(c.engine.signedCookie, name)
+}
+
+// SetEncryptedCookie AES-GCM 加密的 cookie, 密钥通过 Engine.SetCookieKeys 配置
+func (c *Context) SetEncryptedCookie(name, value string, maxAge int, path string, domain string, secure, httpOnly bool) error {
+	return c.setSecureCookie(c.engine.encryptedCookie, name, value, maxAge, path, domain, secure, httpOnly)
+}
+
+// GetEncryptedCookie 读取并解密 cookie
+func (c *Context) GetEncryptedCookie(name string) (string, error) {
+	return c.getSecureCookie(c.engine.encryptedCookie, name)
+}
+
+func (c *Context) setSecureCookie(codec *securecookie.Codec, name, value string, maxAge int, path string, domain string, secure, httpOnly bool) error {
+	if codec == nil {
+		return securecookie.ErrNoKeys
+	}
+	if maxAge < 0 {
+		c.setCookie(name, "", maxAge, path, domain, secure, httpOnly)
+		return nil
+	}
+	encoded, err := codec.Encode(name, []byte(value))
+	if err != nil {
+		return err
+	}
+	c.setCookie(name, encoded, maxAge, path, domain, secure, httpOnly)
+	return nil
+}
+
+func (c *Context) getSecureCookie(codec *securecookie.Codec, name string) (string, error) {
+	if codec == nil {
+		return "", securecookie.ErrNoKeys
+	}
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := codec.Decode(name, cookie.Value)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+const defaultSessionName = "msgo_session"
+
+// Session 获取当前请求的会话, 未配置 SessionStore 时返回 nil
+func (c *Context) Session() *sessions.Session {
+	if c.session != nil {
+		return c.session
+	}
+	store := c.engine.SessionStore
+	if store == nil {
+		return nil
+	}
+	name := c.engine.SessionName
+	if name == "" {
+		name = defaultSessionName
+	}
+	session, err := store.Load(c.R, name)
+	if err != nil {
+		c.Logger.Error(err)
+		session = sessions.NewSession(name, sessions.Options{HttpOnly: true})
+	}
+	c.session = session
+	return session
+}
+
+// SaveSession 保存会话, 需要在写入响应体之前调用
+func (c *Context) SaveSession() error {
+	if c.session == nil {
+		return nil
+	}
+	return c.engine.SessionStore.Save(c.W, c.session)
+}
+
+func (c *Context) SessionStore() sessions.Store {
+	return c.engine.SessionStore
 }
@@ -0,0 +1,157 @@
+package msgo
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/config"
+)
+
+var defaultCorsMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead,
+}
+
+// CorsConfig 跨域配置
+type CorsConfig struct {
+	//AllowOrigins 允许的来源, 支持精确匹配, * 以及 https://*.example.com 子域名通配
+	AllowOrigins []string
+	//AllowOriginFunc 自定义来源校验, 优先于 AllowOrigins
+	AllowOriginFunc func(origin string) bool
+	AllowMethods    []string
+	//AllowHeaders 允许的请求头, 为空时允许预检请求中声明的全部请求头
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CorsConfigFromToml 从 app.toml 的 [cors] 配置生成跨域配置
+func CorsConfigFromToml(conf config.CorsConf) CorsConfig {
+	return CorsConfig{
+		AllowOrigins:     conf.AllowOrigins,
+		AllowMethods:     conf.AllowMethods,
+		AllowHeaders:     conf.AllowHeaders,
+		ExposeHeaders:    conf.ExposeHeaders,
+		AllowCredentials: conf.AllowCredentials,
+		MaxAge:           time.Duration(conf.MaxAge) * time.Second,
+	}
+}
+
+func (conf *CorsConfig) allowOrigin(origin string) bool {
+	if conf.AllowOriginFunc != nil {
+		return conf.AllowOriginFunc(origin)
+	}
+	for _, o := range conf.AllowOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		if i := strings.Index(o, "*."); i >= 0 {
+			prefix, suffix := o[:i], o[i+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (conf *CorsConfig) allowAll() bool {
+	if conf.AllowOriginFunc != nil {
+		return false
+	}
+	for _, o := range conf.AllowOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (conf *CorsConfig) allowMethod(method string) bool {
+	for _, m := range conf.AllowMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cors 跨域中间件, 预检请求直接响应, 不会执行业务处理器
+// 浏览器不接受携带凭证的 * 响应, 回显任意来源又等于对所有站点开放凭证, 因此 * 与 AllowCredentials 不能同时使用
+func Cors(conf CorsConfig) MiddlewareFunc {
+	allowAll := conf.allowAll()
+	if allowAll && conf.AllowCredentials {
+		panic("msgo: CorsConfig AllowOrigins * can not be used with AllowCredentials")
+	}
+	if len(conf.AllowMethods) == 0 {
+		conf.AllowMethods = defaultCorsMethods
+	}
+	allowMethods := strings.Join(conf.AllowMethods, ", ")
+	allowHeaders := strings.Join(conf.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
+	maxAge := ""
+	if conf.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(conf.MaxAge/time.Second), 10)
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			h := ctx.W.Header()
+			//响应随来源变化, 没有 Origin 的请求也要标记, 避免缓存的响应被其他来源复用
+			if !allowAll {
+				h.Add("Vary", "Origin")
+			}
+			origin := ctx.R.Header.Get("Origin")
+			if origin == "" {
+				next(ctx)
+				return
+			}
+			preflight := ctx.R.Method == http.MethodOptions && ctx.R.Header.Get("Access-Control-Request-Method") != ""
+			if !conf.allowOrigin(origin) {
+				if preflight {
+					ctx.StatusCode = http.StatusForbidden
+					ctx.W.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next(ctx)
+				return
+			}
+			if allowAll {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if conf.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if exposeHeaders != "" {
+					h.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next(ctx)
+				return
+			}
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if !conf.allowMethod(ctx.R.Header.Get("Access-Control-Request-Method")) {
+				ctx.StatusCode = http.StatusForbidden
+				ctx.W.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", allowHeaders)
+			} else if reqHeaders := ctx.R.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			if maxAge != "" {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			ctx.StatusCode = http.StatusNoContent
+			ctx.W.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package msgo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newCorsEngine(conf CorsConfig) *Engine {
+	e := newTestEngine()
+	e.Use(Cors(conf))
+	g := e.Group("api")
+	g.Get("/users", func(ctx *Context) { _ = ctx.String(http.StatusOK, "users") })
+	g.Post("/users", func(ctx *Context) { _ = ctx.String(http.StatusOK, "created") })
+	return e
+}
+
+func TestCorsAllowOrigin(t *testing.T) {
+	conf := CorsConfig{AllowOrigins: []string{"https://app.example.com", "https://*.example.org"}}
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"http://a.example.org", false},
+		{"https://a.example.org.evil.com", false},
+		{"https://evil.com", false},
+	}
+	for _, c := range cases {
+		if got := conf.allowOrigin(c.origin); got != c.want {
+			t.Errorf("allowOrigin(%q) = %v, want %v", c.origin, got, c.want)
+		}
+	}
+	fn := CorsConfig{AllowOrigins: []string{"*"}, AllowOriginFunc: func(origin string) bool { return origin == "https://ok" }}
+	if !fn.allowOrigin("https://ok") || fn.allowOrigin("https://other") || fn.allowAll() {
+		t.Error("AllowOriginFunc must take precedence over AllowOrigins")
+	}
+}
+
+func TestCorsSimpleRequest(t *testing.T) {
+	e := newCorsEngine(CorsConfig{AllowOrigins: []string{"https://app.example.com"}, ExposeHeaders: []string{"X-Total"}})
+	w := serve(e, http.MethodGet, "/api/users", nil, "Origin", "https://app.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "X-Total" || w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("allowed origin: got %d %v", w.Code, w.Header())
+	}
+	//不允许的来源照常处理, 由浏览器拦截响应
+	w = serve(e, http.MethodGet, "/api/users", nil, "Origin", "https://evil.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin: got %d %v", w.Code, w.Header())
+	}
+	w = serve(e, http.MethodGet, "/api/users", nil)
+	if w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("requests without Origin must vary on Origin: %v", w.Header())
+	}
+	all := newCorsEngine(CorsConfig{AllowOrigins: []string{"*"}})
+	for _, origin := range []string{"", "https://app.example.com"} {
+		if w := serve(all, http.MethodGet, "/api/users", nil, "Origin", origin); w.Header().Get("Vary") != "" {
+			t.Fatalf("* policy must not vary, origin %q: %v", origin, w.Header())
+		}
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	e := newCorsEngine(CorsConfig{
+		AllowOrigins: []string{"https://app.example.com"},
+		AllowMethods: []string{http.MethodGet, http.MethodPost},
+		MaxAge:       10 * time.Minute,
+	})
+	preflight := func(origin, method, headers string) map[string]string {
+		w := serve(e, http.MethodOptions, "/api/users", nil,
+			"Origin", origin, "Access-Control-Request-Method", method, "Access-Control-Request-Headers", headers)
+		return map[string]string{
+			"code":    http.StatusText(w.Code),
+			"origin":  w.Header().Get("Access-Control-Allow-Origin"),
+			"methods": w.Header().Get("Access-Control-Allow-Methods"),
+			"headers": w.Header().Get("Access-Control-Allow-Headers"),
+			"maxAge":  w.Header().Get("Access-Control-Max-Age"),
+		}
+	}
+	got := preflight("https://app.example.com", http.MethodPost, "Content-Type, X-Token")
+	want := map[string]string{
+		"code":    http.StatusText(http.StatusNoContent),
+		"origin":  "https://app.example.com",
+		"methods": "GET, POST",
+		"headers": "Content-Type, X-Token",
+		"maxAge":  "600",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("preflight %s = %q, want %q", k, got[k], v)
+		}
+	}
+	if got := preflight("https://app.example.com", http.MethodDelete, ""); got["code"] != http.StatusText(http.StatusForbidden) {
+		t.Errorf("disallowed method: got %s", got["code"])
+	}
+	if got := preflight("https://evil.com", http.MethodGet, ""); got["code"] != http.StatusText(http.StatusForbidden) || got["origin"] != "" {
+		t.Errorf("disallowed origin: got %v", got)
+	}
+}
+
+func TestCorsCredentialsWithWildcard(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("* with AllowCredentials must be rejected")
+		}
+	}()
+	Cors(CorsConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestCorsCredentials(t *testing.T) {
+	e := newCorsEngine(CorsConfig{AllowOrigins: []string{"https://app.example.com"}, AllowCredentials: true})
+	w := serve(e, http.MethodGet, "/api/users", nil, "Origin", "https://app.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q", got)
+	}
+}
+
+func TestOptionsHandler(t *testing.T) {
+	e := newTestEngine()
+	g := e.Group("api")
+	g.Put("/users", func(ctx *Context) {})
+	g.Get("/users", func(ctx *Context) {})
+	g.Post("/users", func(ctx *Context) {})
+	w := serve(e, http.MethodOptions, "/api/users", nil)
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, POST, PUT, OPTIONS" {
+		t.Fatalf("OPTIONS = %d Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+	if w := serve(e, http.MethodDelete, "/api/users", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE = %d, want 405", w.Code)
+	}
+}
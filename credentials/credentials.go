@@ -0,0 +1,114 @@
+package credentials
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnsupportedHash 无法识别的密码哈希格式
+var ErrUnsupportedHash = errors.New("credentials: unsupported password hash")
+
+// Verifier 校验用户名和密码, 用于 Accounts.BasicAuth
+type Verifier interface {
+	Verify(username, password string) bool
+}
+
+// VerifierFunc 使用函数校验, 如查询数据库
+type VerifierFunc func(username, password string) bool
+
+func (f VerifierFunc) Verify(username, password string) bool {
+	return f(username, password)
+}
+
+// Plain 明文密码, 使用常量时间比较
+type Plain map[string]string
+
+func (p Plain) Verify(username, password string) bool {
+	expected, ok := p[username]
+	//用户不存在时同样比较一次, 避免通过耗时判断用户是否存在
+	equal := ConstantTimeEqual(expected, password)
+	return ok && equal
+}
+
+// Hashed 用户名到密码哈希, 支持 bcrypt($2a$、$2b$、$2y$)、argon2($argon2id$、$argon2i$) 和 sha-crypt($5$、$6$)
+type Hashed map[string]string
+
+func (h Hashed) Verify(username, password string) bool {
+	hash, ok := h[username]
+	if !ok {
+		//用户不存在时计算一次哈希, 避免通过耗时判断用户是否存在
+		_, _ = Compare(dummyHash(), password)
+		return false
+	}
+	ok, err := Compare(hash, password)
+	return err == nil && ok
+}
+
+type hashScheme int
+
+const (
+	unsupportedScheme hashScheme = iota
+	bcryptScheme
+	argon2Scheme
+	shaCryptScheme
+)
+
+// schemeOf 根据前缀识别哈希格式, apr1($apr1$)、{SHA} 和 crypt 等不支持的格式返回 unsupportedScheme
+func schemeOf(hash string) hashScheme {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return bcryptScheme
+	case strings.HasPrefix(hash, "$argon2"):
+		return argon2Scheme
+	case strings.HasPrefix(hash, "$5$"), strings.HasPrefix(hash, "$6$"):
+		return shaCryptScheme
+	}
+	return unsupportedScheme
+}
+
+// Compare 按哈希格式校验密码
+func Compare(hash, password string) (bool, error) {
+	switch schemeOf(hash) {
+	case bcryptScheme:
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	case argon2Scheme:
+		return compareArgon2(hash, password)
+	case shaCryptScheme:
+		return compareShaCrypt(hash, password)
+	}
+	return false, ErrUnsupportedHash
+}
+
+// HashPassword 使用 bcrypt 默认强度生成密码哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// ConstantTimeEqual 常量时间比较字符串, 耗时与内容和长度无关
+func ConstantTimeEqual(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+var (
+	dummy     string
+	dummyOnce sync.Once
+)
+
+func dummyHash() string {
+	dummyOnce.Do(func() {
+		dummy, _ = HashPassword("dummy password")
+	})
+	return dummy
+}
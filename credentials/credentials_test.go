@@ -0,0 +1,76 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestCompare(t *testing.T) {
+	bcryptHash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("secret"), salt, 1, 64*1024, 2, 32)
+	argonHash := fmt.Sprintf("$argon2id$v=%d$m=65536,t=1,p=2$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	cases := []struct {
+		hash, password string
+	}{
+		{bcryptHash, "secret"},
+		{argonHash, "secret"},
+		//openssl passwd -5 / -6 生成
+		{"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5", "Hello world!"},
+		{"$5$saltstringsaltst$a5C8Ofk71MUIoKve2QuP9FMl.dwNgseF5tR1LGAL7iB", "Hello world!"},
+		{"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", "Hello world!"},
+		{"$6$rounds=1400$anotherlongsalts$gcqWy8YP4ck.Vdlo7M1LGDHs2rcLB5yO7t.qtjugLkoxi0ZJFQeEbBVv/meM7XB3ZjNW0qN7aJ6Otq.PcqSRe/", "a very much longer text to encrypt."},
+	}
+	for _, c := range cases {
+		if ok, err := Compare(c.hash, c.password); !ok || err != nil {
+			t.Errorf("Compare(%q) = %v, %v, want match", c.hash, ok, err)
+		}
+		if ok, _ := Compare(c.hash, c.password+"x"); ok {
+			t.Errorf("Compare(%q) matched a wrong password", c.hash)
+		}
+	}
+	if _, err := Compare("5f4dcc3b5aa765d61d8327deb882cf99", "password"); err != ErrUnsupportedHash {
+		t.Fatalf("expected ErrUnsupportedHash, got %v", err)
+	}
+}
+
+func TestVerifiers(t *testing.T) {
+	plain := Plain{"admin": "secret"}
+	if !plain.Verify("admin", "secret") || plain.Verify("admin", "secret2") || plain.Verify("nobody", "") {
+		t.Fatal("plain verifier mismatch")
+	}
+	users, err := ParseHtpasswd(strings.NewReader("# users\n\nalice:$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !users.Verify("alice", "Hello world!") || users.Verify("alice", "hello") || users.Verify("bob", "Hello world!") {
+		t.Fatal("htpasswd verifier mismatch")
+	}
+	if _, err := ParseHtpasswd(strings.NewReader("alice\n")); err == nil {
+		t.Fatal("malformed line should be rejected")
+	}
+}
+
+func TestParseHtpasswdUnsupportedHash(t *testing.T) {
+	cases := []string{
+		"bob:$apr1$salt$hash",
+		"bob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=",
+		"bob:rqXexS6ZhobKA",
+		"bob:$1$salt$hash",
+	}
+	for _, c := range cases {
+		_, err := ParseHtpasswd(strings.NewReader("# users\nalice:$2y$05$abcdefghijklmnopqrstuu5Ck1y9u8Cz3Rzq2h7Xo2dOqK9Vvl0K\n" + c + "\n"))
+		if !errors.Is(err, ErrUnsupportedHash) || !strings.Contains(err.Error(), "line 3") {
+			t.Errorf("%q: got %v, want ErrUnsupportedHash on line 3", c, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package credentials
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// compareArgon2 校验 PHC 格式的 argon2 哈希, 如 $argon2id$v=19$m=65536,t=3,p=4$salt$key
+func compareArgon2(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) == 6 {
+		if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+			return false, ErrUnsupportedHash
+		}
+		parts = append(parts[:2], parts[3:]...)
+	}
+	if len(parts) != 5 {
+		return false, ErrUnsupportedHash
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, ErrUnsupportedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrUnsupportedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnsupportedHash
+	}
+	var derived []byte
+	switch parts[1] {
+	case "argon2id":
+		derived = argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	case "argon2i":
+		derived = argon2.Key([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	default:
+		return false, ErrUnsupportedHash
+	}
+	return subtle.ConstantTimeCompare(derived, key) == 1, nil
+}
+
+const (
+	shaCryptRounds    = 5000
+	shaCryptMinRounds = 1000
+	shaCryptMaxRounds = 999999999
+	shaCryptSaltLen   = 16
+	cryptAlphabet     = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// sha-crypt 结果的字节输出顺序, 每组 3 字节编码为 4 个字符, 最后一组不足 3 字节
+var (
+	sha256CryptOrder = []int{0, 10, 20, 21, 1, 11, 12, 22, 2, 3, 13, 23, 24, 4, 14,
+		15, 25, 5, 6, 16, 26, 27, 7, 17, 18, 28, 8, 9, 19, 29, 31, 30}
+	sha512CryptOrder = []int{0, 21, 42, 22, 43, 1, 44, 2, 23, 3, 24, 45, 25, 46, 4,
+		47, 5, 26, 6, 27, 48, 28, 49, 7, 50, 8, 29, 9, 30, 51, 31, 52, 10,
+		53, 11, 32, 12, 33, 54, 34, 55, 13, 56, 14, 35, 15, 36, 57, 37, 58, 16,
+		59, 17, 38, 18, 39, 60, 40, 61, 19, 62, 20, 41, 63}
+)
+
+// compareShaCrypt 校验 sha256-crypt($5$) 和 sha512-crypt($6$) 哈希
+func compareShaCrypt(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 4 {
+		return false, ErrUnsupportedHash
+	}
+	newHash, order := sha256.New, sha256CryptOrder
+	if parts[1] == "6" {
+		newHash, order = sha512.New, sha512CryptOrder
+	}
+	prefix := "$" + parts[1] + "$"
+	rounds := shaCryptRounds
+	rest := parts[2:]
+	if strings.HasPrefix(rest[0], "rounds=") {
+		n, err := strconv.Atoi(strings.TrimPrefix(rest[0], "rounds="))
+		if err != nil || len(rest) < 3 {
+			return false, ErrUnsupportedHash
+		}
+		rounds = n
+		if rounds < shaCryptMinRounds {
+			rounds = shaCryptMinRounds
+		} else if rounds > shaCryptMaxRounds {
+			rounds = shaCryptMaxRounds
+		}
+		prefix += "rounds=" + strconv.Itoa(rounds) + "$"
+		rest = rest[1:]
+	}
+	salt := rest[0]
+	if len(salt) > shaCryptSaltLen {
+		salt = salt[:shaCryptSaltLen]
+	}
+	sum := shaCrypt(newHash, []byte(password), []byte(salt), rounds)
+	expected := prefix + salt + "$" + cryptEncode(sum, order)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(encoded)) == 1, nil
+}
+
+// shaCrypt 按 Ulrich Drepper 的 SHA-crypt 规范计算摘要
+func shaCrypt(newHash func() hash.Hash, password, salt []byte, rounds int) []byte {
+	h := newHash()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	b := h.Sum(nil)
+
+	h = newHash()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(repeat(b, len(password)))
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(password)
+		}
+	}
+	a := h.Sum(nil)
+
+	h = newHash()
+	for i := 0; i < len(password); i++ {
+		h.Write(password)
+	}
+	p := repeat(h.Sum(nil), len(password))
+
+	h = newHash()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(salt)
+	}
+	s := repeat(h.Sum(nil), len(salt))
+
+	c := a
+	for i := 0; i < rounds; i++ {
+		h = newHash()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(nil)
+	}
+	return c
+}
+
+// repeat 重复 b 直到长度为 n
+func repeat(b []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		out = append(out, b[:min(len(b), n-len(out))]...)
+	}
+	return out
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// cryptEncode 按 order 取字节, 每 3 字节小端编码为 4 个 crypt 字符
+func cryptEncode(sum []byte, order []int) string {
+	var sb strings.Builder
+	for i := 0; i < len(order); i += 3 {
+		var w uint32
+		n := 4
+		switch len(order) - i {
+		case 1:
+			w, n = uint32(sum[order[i]]), 2
+		case 2:
+			w, n = uint32(sum[order[i]])<<8|uint32(sum[order[i+1]]), 3
+		default:
+			w = uint32(sum[order[i]])<<16 | uint32(sum[order[i+1]])<<8 | uint32(sum[order[i+2]])
+		}
+		for ; n > 0; n-- {
+			sb.WriteByte(cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ParseHtpasswd 解析 htpasswd 格式的 "用户名:哈希" 行, 忽略空行和 # 注释,
+// 哈希格式同 Hashed, 可使用 htpasswd -B 生成 bcrypt 哈希, 包含其他格式时返回 ErrUnsupportedHash
+func ParseHtpasswd(r io.Reader) (Hashed, error) {
+	users := Hashed{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(text, ":")
+		if !ok || username == "" || hash == "" {
+			return nil, fmt.Errorf("credentials: htpasswd line %d is malformed", line)
+		}
+		//加载时拒绝无法校验的格式, 避免用户始终无法登录且没有任何提示
+		if schemeOf(hash) == unsupportedScheme {
+			return nil, fmt.Errorf("%w: htpasswd line %d for user %s, use htpasswd -B", ErrUnsupportedHash, line, username)
+		}
+		users[username] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// LoadHtpasswd 从文件加载 htpasswd
+func LoadHtpasswd(path string) (Hashed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseHtpasswd(f)
+}
@@ -0,0 +1,227 @@
+package msgo
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"time"
+)
+
+const csrfTokenLength = 32
+
+var ErrCSRFTokenInvalid = errors.New("csrf token invalid")
+
+type CSRFMode int
+
+const (
+	//CSRFDoubleSubmit 令牌保存在 cookie 中, 提交时与请求头或表单字段比对
+	CSRFDoubleSubmit CSRFMode = iota
+	//CSRFSynchronizer 令牌保存在 session 中, 需要配置 Engine.SessionStore
+	CSRFSynchronizer
+)
+
+// CSRFConfig 跨站请求伪造防护配置
+type CSRFConfig struct {
+	Mode CSRFMode
+	//CookieName 双提交模式的 cookie 名称, 默认 _csrf
+	CookieName     string
+	CookiePath     string
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+	//MaxAge cookie 有效期, 默认 12 小时
+	MaxAge time.Duration
+	//SessionKey 同步令牌模式在 session 中的键, 默认 _csrf
+	SessionKey string
+	//HeaderName 默认 X-CSRF-Token
+	HeaderName string
+	//FormField 表单字段名, 默认 _csrf
+	FormField string
+	//Skipper 返回 true 时跳过校验, 如 webhook 回调
+	Skipper func(ctx *Context) bool
+	//ErrorHandler 校验失败时调用, 默认返回 403
+	ErrorHandler HandlerFunc
+}
+
+// CSRF 使用默认配置(双提交 cookie)的 CSRF 中间件
+func CSRF(next HandlerFunc) HandlerFunc {
+	return defaultCSRF(next)
+}
+
+var defaultCSRF = CSRFWithConfig(CSRFConfig{})
+
+func CSRFWithConfig(conf CSRFConfig) MiddlewareFunc {
+	if conf.CookieName == "" {
+		conf.CookieName = "_csrf"
+	}
+	if conf.CookiePath == "" {
+		conf.CookiePath = "/"
+	}
+	if conf.CookieSameSite == 0 {
+		conf.CookieSameSite = http.SameSiteLaxMode
+	}
+	if conf.MaxAge <= 0 {
+		conf.MaxAge = 12 * time.Hour
+	}
+	if conf.SessionKey == "" {
+		conf.SessionKey = "_csrf"
+	}
+	if conf.HeaderName == "" {
+		conf.HeaderName = "X-CSRF-Token"
+	}
+	if conf.FormField == "" {
+		conf.FormField = "_csrf"
+	}
+	if conf.ErrorHandler == nil {
+		conf.ErrorHandler = func(ctx *Context) {
+			ctx.Fail(http.StatusForbidden, ErrCSRFTokenInvalid.Error())
+		}
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			secret, err := conf.loadSecret(ctx)
+			if err != nil {
+				ctx.Logger.Error(err)
+				ctx.Fail(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			ctx.csrfSecret = secret
+			ctx.csrfField = conf.FormField
+			ctx.W.Header().Add("Vary", "Cookie")
+			if isSafeMethod(ctx.R.Method) || (conf.Skipper != nil && conf.Skipper(ctx)) {
+				next(ctx)
+				return
+			}
+			submitted := ctx.R.Header.Get(conf.HeaderName)
+			if submitted == "" {
+				//按上传和请求体限制解析表单, 超限时输出对应的错误响应
+				if submitted, err = ctx.GetPost(conf.FormField); err != nil {
+					if !ctx.bodyErrWritten {
+						conf.ErrorHandler(ctx)
+					}
+					return
+				}
+			}
+			if !verifyCSRFToken(secret, submitted) {
+				conf.ErrorHandler(ctx)
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// loadSecret 读取或生成令牌密钥, 新生成的密钥写入 cookie 或 session
+func (conf *CSRFConfig) loadSecret(ctx *Context) ([]byte, error) {
+	if conf.Mode == CSRFSynchronizer {
+		session := ctx.Session()
+		if session == nil {
+			return nil, errors.New("csrf: synchronizer mode requires Engine.SessionStore")
+		}
+		if s, ok := session.Get(conf.SessionKey).(string); ok {
+			if secret := decodeCSRFSecret(s); secret != nil {
+				return secret, nil
+			}
+		}
+		secret := newCSRFSecret()
+		session.Set(conf.SessionKey, base64.RawURLEncoding.EncodeToString(secret))
+		return secret, ctx.SaveSession()
+	}
+	if cookie, err := ctx.R.Cookie(conf.CookieName); err == nil {
+		if secret := decodeCSRFSecret(cookie.Value); secret != nil {
+			return secret, nil
+		}
+	}
+	secret := newCSRFSecret()
+	//双提交模式下前端脚本需要读取 cookie, 不设置 HttpOnly
+	http.SetCookie(ctx.W, &http.Cookie{
+		Name:     conf.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(secret),
+		Path:     conf.CookiePath,
+		Domain:   conf.CookieDomain,
+		MaxAge:   int(conf.MaxAge / time.Second),
+		Secure:   conf.CookieSecure,
+		SameSite: conf.CookieSameSite,
+	})
+	return secret, nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func newCSRFSecret() []byte {
+	b := make([]byte, csrfTokenLength)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func decodeCSRFSecret(s string) []byte {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) != csrfTokenLength {
+		return nil
+	}
+	return b
+}
+
+// maskCSRFToken 每次输出使用随机掩码, 避免压缩响应时通过长度推测令牌(BREACH)
+func maskCSRFToken(secret []byte) string {
+	pad := newCSRFSecret()
+	token := make([]byte, 2*csrfTokenLength)
+	copy(token, pad)
+	for i := range secret {
+		token[csrfTokenLength+i] = secret[i] ^ pad[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// verifyCSRFToken 同时接受掩码令牌和 cookie 中的原始令牌
+func verifyCSRFToken(secret []byte, submitted string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(submitted)
+	if err != nil {
+		return false
+	}
+	switch len(b) {
+	case csrfTokenLength:
+	case 2 * csrfTokenLength:
+		pad, masked := b[:csrfTokenLength], b[csrfTokenLength:]
+		b = make([]byte, csrfTokenLength)
+		for i := range b {
+			b[i] = masked[i] ^ pad[i]
+		}
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare(secret, b) == 1
+}
+
+// CSRFToken 当前请求的 CSRF 令牌, 未启用 CSRF 中间件时返回空
+func (c *Context) CSRFToken() string {
+	if c.csrfSecret == nil {
+		return ""
+	}
+	if c.csrfToken == "" {
+		c.csrfToken = maskCSRFToken(c.csrfSecret)
+	}
+	return c.csrfToken
+}
+
+// CSRFField 包含令牌的隐藏表单字段
+func (c *Context) CSRFField() template.HTML {
+	token := c.CSRFToken()
+	if token == "" {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(c.csrfField) +
+		`" value="` + token + `">`)
+}
@@ -0,0 +1,113 @@
+package msgo
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/mslog"
+)
+
+func newCSRFEngine() *Engine {
+	e := newTestEngine()
+	g := e.Group("form")
+	g.Get("/token", func(ctx *Context) { _ = ctx.String(http.StatusOK, "%s", ctx.CSRFToken()) }, CSRF)
+	g.Post("/submit", func(ctx *Context) { _ = ctx.String(http.StatusOK, "ok") }, CSRF)
+	return e
+}
+
+// csrfToken 通过 GET 请求获取 cookie 和掩码令牌
+func csrfToken(t *testing.T, e *Engine) (cookie, token string) {
+	t.Helper()
+	w := serve(e, http.MethodGet, "/form/token", nil)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "_csrf" {
+			cookie = c.Value
+		}
+	}
+	if cookie == "" || w.Body.Len() == 0 {
+		t.Fatalf("GET did not issue a csrf cookie and token: cookie=%q body=%q", cookie, w.Body)
+	}
+	if !strings.Contains(w.Header().Get("Vary"), "Cookie") {
+		t.Fatalf("Vary = %q, want Cookie", w.Header().Get("Vary"))
+	}
+	return cookie, w.Body.String()
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	e := newCSRFEngine()
+	cookie, token := csrfToken(t, e)
+	other, _ := csrfToken(t, e)
+	form := "application/x-www-form-urlencoded"
+	cases := []struct {
+		name   string
+		body   string
+		header []string
+		want   int
+	}{
+		{"header", "", []string{"Cookie", "_csrf=" + cookie, "X-CSRF-Token", token}, http.StatusOK},
+		{"raw cookie value", "", []string{"Cookie", "_csrf=" + cookie, "X-CSRF-Token", cookie}, http.StatusOK},
+		{"form field", url.Values{"_csrf": {token}}.Encode(), []string{"Cookie", "_csrf=" + cookie, "Content-Type", form}, http.StatusOK},
+		{"missing token", "", []string{"Cookie", "_csrf=" + cookie}, http.StatusForbidden},
+		{"missing cookie", "", []string{"X-CSRF-Token", token}, http.StatusForbidden},
+		{"other secret", "", []string{"Cookie", "_csrf=" + other, "X-CSRF-Token", token}, http.StatusForbidden},
+		{"garbage", "", []string{"Cookie", "_csrf=" + cookie, "X-CSRF-Token", "not-a-token"}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		w := serve(e, http.MethodPost, "/form/submit", strings.NewReader(c.body), c.header...)
+		if w.Code != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+}
+
+func TestCSRFTokenMasked(t *testing.T) {
+	secret := newCSRFSecret()
+	a, b := maskCSRFToken(secret), maskCSRFToken(secret)
+	if a == b {
+		t.Fatal("masked tokens must differ per call")
+	}
+	for _, token := range []string{a, b, base64.RawURLEncoding.EncodeToString(secret)} {
+		if !verifyCSRFToken(secret, token) {
+			t.Errorf("token %q rejected", token)
+		}
+	}
+	if verifyCSRFToken(secret, maskCSRFToken(newCSRFSecret())) {
+		t.Error("token of another secret accepted")
+	}
+}
+
+func TestCSRFFormRespectsUploadLimit(t *testing.T) {
+	e := newCSRFEngine()
+	e.Upload = UploadConfig{MaxBodySize: 64}
+	cookie, token := csrfToken(t, e)
+	body := url.Values{"_csrf": {token}, "data": {strings.Repeat("x", 256)}}.Encode()
+	w := serve(e, http.MethodPost, "/form/submit", strings.NewReader(body),
+		"Cookie", "_csrf="+cookie, "Content-Type", "application/x-www-form-urlencoded")
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("got %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestCSRFSafeMethodAndSkipper(t *testing.T) {
+	e := newTestEngine()
+	skip := CSRFWithConfig(CSRFConfig{Skipper: func(ctx *Context) bool { return ctx.R.Header.Get("X-Webhook") != "" }})
+	e.Group("hook").Post("/in", func(ctx *Context) { _ = ctx.String(http.StatusOK, "ok") }, skip)
+	if w := serve(e, http.MethodPost, "/hook/in", nil, "X-Webhook", "1"); w.Code != http.StatusOK {
+		t.Fatalf("skipped request: got %d", w.Code)
+	}
+	if w := serve(e, http.MethodPost, "/hook/in", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("unskipped request: got %d", w.Code)
+	}
+}
+
+func TestCSRFSynchronizerRequiresSessionStore(t *testing.T) {
+	e := newTestEngine()
+	e.Logger = mslog.Default()
+	e.Group("form").Get("/token", func(ctx *Context) {}, CSRFWithConfig(CSRFConfig{Mode: CSRFSynchronizer}))
+	if w := serve(e, http.MethodGet, "/form/token", nil); w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d, want 500", w.Code)
+	}
+}
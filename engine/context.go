@@ -2,8 +2,11 @@ package engine
 
 import (
 	"errors"
+	"fmt"
 	"github.com/liyuanwu2020/msgo/binding"
+	"github.com/liyuanwu2020/msgo/internal/clientip"
 	"github.com/liyuanwu2020/msgo/internal/msstrings"
+	"github.com/liyuanwu2020/msgo/mserror"
 	msLog "github.com/liyuanwu2020/msgo/mslog"
 	"github.com/liyuanwu2020/msgo/render"
 	"github.com/liyuanwu2020/msgo/validator"
@@ -11,6 +14,7 @@ import (
 	"io"
 	"log"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -35,6 +39,16 @@ type Context struct {
 	Keys                  map[string]any
 	mu                    sync.RWMutex
 	sameSite              http.SameSite
+	errors                []error
+}
+
+// ClientIP 获取客户端 IP, 请求来自可信代理时解析 Forwarded, X-Forwarded-For, X-Real-IP
+func (c *Context) ClientIP() net.IP {
+	var proxies clientip.TrustedProxies
+	if c.engine != nil {
+		proxies = c.engine.trustedProxies
+	}
+	return proxies.ClientIP(c.R)
 }
 
 func (c *Context) SetSameSite(s http.SameSite) {
@@ -249,7 +263,6 @@ func (c *Context) XML(status int, data any) error {
 
 // String 字符串
 func (c *Context) String(status int, format string, values ...any) error {
-	c.W.WriteHeader(status)
 	err := c.Render(&render.String{
 		Format: format,
 		Values: values,
@@ -299,17 +312,41 @@ func (c *Context) Render(r render.Render, statusCode int) error {
 	return r.Render(c.W)
 }
 
+// Fail 输出错误信息, msg 为字符串或 error 时输出文本, 其他类型输出 JSON
 func (c *Context) Fail(code int, msg any) {
-	_ = c.String(code, msg.(string))
+	switch m := msg.(type) {
+	case string:
+		_ = c.String(code, "%s", m)
+	case error:
+		_ = c.String(code, "%s", m.Error())
+	case fmt.Stringer:
+		_ = c.String(code, "%s", m.String())
+	default:
+		_ = c.JsonWithStatus(code, msg)
+	}
 }
 
+// HandleWithError 出错时记录错误, 交给注册的错误处理器, 未注册时按 Engine.ErrorFormat 输出业务错误
 func (c *Context) HandleWithError(statusCode int, obj any, err error) {
 	if err != nil {
-		code, data := c.engine.errorHandler(err)
-		err = c.JsonWithStatus(code, data)
-	} else {
-		err = c.JsonWithStatus(statusCode, obj)
+		c.Error(err)
+		c.logError(err)
+		c.renderError(err)
+		return
+	}
+	_ = c.JsonWithStatus(statusCode, obj)
+}
+
+// RenderError 输出业务错误, 非 mserror.Error 按 500 输出且不暴露原始错误
+func (c *Context) RenderError(err error) error {
+	e := mserror.Wrap(err)
+	if c.engine != nil && c.engine.ErrorFormat == ErrorFormatEnvelope {
+		return c.JsonWithStatus(e.Status, mserror.NewEnvelope(e))
 	}
+	return c.Render(&render.JSON{
+		Data:        mserror.NewProblem(e, c.R.URL.Path),
+		ContentType: mserror.ProblemContentType,
+	}, e.Status)
 }
 
 func (c *Context) SetBasicAuth(username, password string) {
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type stringer struct{}
+
+func (stringer) String() string { return "stringer" }
+
+func TestFail(t *testing.T) {
+	cases := []struct {
+		msg         any
+		body        string
+		contentType string
+	}{
+		{"plain %d", "plain %d", "text/plain"},
+		{errors.New("err"), "err", "text/plain"},
+		{stringer{}, "stringer", "text/plain"},
+		{map[string]int{"code": 1}, `{"code":1}`, "application/json"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx := &Context{W: w, R: httptest.NewRequest(http.MethodGet, "/", nil), engine: New()}
+		ctx.Fail(http.StatusBadRequest, c.msg)
+		if w.Code != http.StatusBadRequest || strings.TrimSpace(w.Body.String()) != c.body ||
+			!strings.HasPrefix(w.Header().Get("Content-Type"), c.contentType) {
+			t.Errorf("Fail(%v) = %d %q %q", c.msg, w.Code, w.Body, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestStringWritesHeadersBeforeStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := &Context{W: w, R: httptest.NewRequest(http.MethodGet, "/", nil), engine: New()}
+	if err := ctx.String(http.StatusCreated, "id=%d", 7); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusCreated || w.Body.String() != "id=7" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("got %d %q %q", w.Code, w.Body, w.Header().Get("Content-Type"))
+	}
+}
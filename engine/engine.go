@@ -1,10 +1,15 @@
 package engine
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/liyuanwu2020/msgo/engine/gateway"
+	"github.com/liyuanwu2020/msgo/internal/clientip"
 	"github.com/liyuanwu2020/msgo/mslog"
 	"github.com/liyuanwu2020/msgo/register"
+	"github.com/liyuanwu2020/msgo/requestid"
+	"github.com/liyuanwu2020/msgo/tracing"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -15,21 +20,69 @@ type Engine struct {
 	Logger       *mslog.Logger
 	middlewares  []MiddlewareFunc
 	errorHandler ErrorHandler
-	register     register.MsRegister
+	//ErrorFormat 未注册错误处理器时 HandleWithError 的输出格式
+	ErrorFormat ErrorFormat
+	register    register.MsRegister
+	//trustedProxies 可信代理, 只有来自可信代理的请求才解析转发头
+	trustedProxies clientip.TrustedProxies
 }
 
 type ErrorHandler func(err error) (int, any)
 
+// ErrorFormat 未注册错误处理器时业务错误的输出格式
+type ErrorFormat int
+
+const (
+	//ErrorFormatProblem RFC 7807 application/problem+json
+	ErrorFormatProblem ErrorFormat = iota
+	//ErrorFormatEnvelope {"code":40400,"msg":"not found","details":{}}
+	ErrorFormatEnvelope
+)
+
+// SetTrustedProxies 配置可信代理的 CIDR 或 IP, ClientIP 只信任这些代理添加的转发头
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs, err := clientip.ParseCIDRs(proxies)
+	if err != nil {
+		return err
+	}
+	e.trustedProxies = cidrs
+	return nil
+}
+
+func (e *Engine) RegisterErrorHandler(handler ErrorHandler) {
+	e.errorHandler = handler
+}
+
+// ServeHTTP 为每个请求创建服务端 span, 延续上游 traceparent
 func (e *Engine) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	parent := tracing.Extract(request.Context(), tracing.HeaderCarrier(request.Header))
+	spanCtx, span := tracing.Start(parent, request.Method+" "+request.URL.Path, tracing.SpanKindServer)
+	span.SetAttribute("http.method", request.Method)
+	span.SetAttribute("http.target", request.URL.RequestURI())
+	sw := &statusWriter{ResponseWriter: writer, status: http.StatusOK}
+	defer func() {
+		span.SetAttribute("http.status_code", sw.status)
+		if sw.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%d %s", sw.status, http.StatusText(sw.status)))
+		}
+		span.End()
+	}()
+	e.serveHTTP(sw, request.WithContext(spanCtx), span)
+}
+
+func (e *Engine) serveHTTP(writer http.ResponseWriter, request *http.Request, span *tracing.Span) {
 	ctx := &Context{
 		W:      writer,
 		R:      request,
 		Logger: e.Logger,
+		engine: e,
 	}
 	method := ctx.R.Method
 	requestPath := ctx.R.URL.Path
 
 	if node := e.node.Get(requestPath); node != nil {
+		span.SetName(method + " " + node.routerName)
+		span.SetAttribute("http.route", node.routerName)
 		//网关的处理逻辑
 		if e.gatewayConfigs != nil {
 			gwConfig, ok := e.gatewayConfigMap[node.routerName]
@@ -45,8 +98,17 @@ func (e *Engine) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 					}
 					rawURL = fmt.Sprintf("http://%s", serviceName)
 				}
+				//请求 ID 透传给下游服务并回写到响应
+				requestID := request.Header.Get(requestid.HeaderName)
+				if !requestid.Valid(requestID) {
+					requestID = requestid.New()
+				}
+				writer.Header().Set(requestid.HeaderName, requestID)
+				logger := e.Logger.WithFields(mslog.Fields{"request_id": requestID})
 				target, _ := url.Parse(rawURL)
 				director := func(request *http.Request) {
+					request.Header.Set(requestid.HeaderName, requestID)
+					tracing.Inject(request.Context(), tracing.HeaderCarrier(request.Header))
 					request.Host = target.Host
 					request.URL.Host = target.Host
 					request.URL.Path = target.Path
@@ -56,15 +118,21 @@ func (e *Engine) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 					}
 				}
 				response := func(response *http.Response) error {
-					e.Logger.Info("结果处理")
+					logger.Info("结果处理")
 					return nil
 				}
+				proxyCtx, proxySpan := tracing.Start(request.Context(), "proxy "+gwConfig.Name, tracing.SpanKindClient)
+				proxySpan.SetAttribute("http.url", rawURL)
+				//下游不可用时返回 502, 此前不写状态码, 客户端会收到空的 200 响应
 				handler := func(writer http.ResponseWriter, request *http.Request, err error) {
-					e.Logger.Info("错误处理")
-					e.Logger.Error(err)
+					logger.Info("错误处理")
+					logger.Error(err)
+					proxySpan.SetError(err)
+					writer.WriteHeader(http.StatusBadGateway)
 				}
 				proxy := httputil.ReverseProxy{Director: director, ModifyResponse: response, ErrorHandler: handler}
-				proxy.ServeHTTP(writer, request)
+				proxy.ServeHTTP(writer, request.WithContext(proxyCtx))
+				proxySpan.End()
 				return
 			}
 		}
@@ -155,3 +223,48 @@ func New() *Engine {
 	}
 	return engine
 }
+
+// statusWriter 记录响应状态码, 保留 Flush、Hijack 和 Unwrap 以支持网关流式响应和协议升级(如 WebSocket)
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("engine: %T does not support hijacking", w.ResponseWriter)
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil && !w.wroteHeader {
+		//接管连接后由调用方直接写响应, 协议升级时为 101
+		w.status = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap 供 http.ResponseController 访问原始 ResponseWriter
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
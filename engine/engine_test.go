@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/engine/gateway"
+)
+
+func newGateway(t *testing.T, backend string) *httptest.Server {
+	t.Helper()
+	u, _ := url.Parse(backend)
+	host, portStr, _ := net.SplitHostPort(u.Host)
+	port, _ := strconv.Atoi(portStr)
+	e := New()
+	e.Logger = Default().Logger
+	e.SetGateConfigs([]gateway.GWConfig{{Name: "echo", Path: "/echo/**", Host: host, Port: port}})
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGatewayUpgrade(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_ = rw.Flush()
+		line, _ := rw.ReadString('\n')
+		_, _ = rw.WriteString("echo: " + line)
+		_ = rw.Flush()
+	}))
+	defer backend.Close()
+	gw := newGateway(t, backend.URL)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(gw.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = io.WriteString(conn, "GET /echo/ws HTTP/1.1\r\nHost: gw\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	br := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade through the gateway: status %d, want 101", rsp.StatusCode)
+	}
+	_, _ = io.WriteString(conn, "hello\n")
+	line, err := br.ReadString('\n')
+	if err != nil || line != "echo: hello\n" {
+		t.Fatalf("unexpected upgraded stream %q, %v", line, err)
+	}
+}
+
+func TestGatewayBadGateway(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	backendURL := backend.URL
+	backend.Close()
+	gw := newGateway(t, backendURL)
+	rsp, err := http.Get(gw.URL + "/echo/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("unreachable upstream: status %d, want 502", rsp.StatusCode)
+	}
+}
+
+func TestStatusWriterUnwrap(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := &statusWriter{ResponseWriter: rec, status: http.StatusOK}
+	if sw.Unwrap() != rec {
+		t.Fatal("Unwrap must return the wrapped writer")
+	}
+	if _, _, err := sw.Hijack(); err == nil {
+		t.Fatal("hijacking a writer without Hijacker must fail")
+	}
+}
+
+func TestKeyByIPTrustedProxy(t *testing.T) {
+	e := New()
+	if err := e.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		remote string
+		xff    string
+		want   string
+	}{
+		{"10.0.0.1:80", "9.9.9.9, 10.0.0.2", "ip:9.9.9.9"},
+		{"1.2.3.4:80", "9.9.9.9", "ip:1.2.3.4"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remote
+		r.Header.Set("X-Forwarded-For", c.xff)
+		if got := KeyByIP(&Context{R: r, engine: e}); got != c.want {
+			t.Errorf("KeyByIP(%s, %s) = %s, want %s", c.remote, c.xff, got, c.want)
+		}
+	}
+}
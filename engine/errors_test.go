@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/mserror"
+	"github.com/liyuanwu2020/msgo/mslog"
+)
+
+func TestWrapE(t *testing.T) {
+	dir := t.TempDir()
+	logger := mslog.New()
+	logger.Formatter = mslog.TextFormat
+	logger.SetLogPath(dir)
+	call := func(h HandlerFuncE) (*httptest.ResponseRecorder, []error) {
+		w := httptest.NewRecorder()
+		ctx := &Context{W: w, R: httptest.NewRequest(http.MethodGet, "/api", nil), Logger: logger, engine: New()}
+		WrapE(h)(ctx)
+		return w, ctx.Errors()
+	}
+
+	if w, errs := call(func(ctx *Context) error { return ctx.String(http.StatusOK, "ok") }); w.Code != http.StatusOK || len(errs) != 0 {
+		t.Fatalf("ok: got %d, errors %v", w.Code, errs)
+	}
+
+	w, errs := call(func(ctx *Context) error {
+		ctx.Error(errors.New("first"))
+		return mserror.ErrNotFound
+	})
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != mserror.ProblemContentType {
+		t.Fatalf("missing: got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if len(errs) != 2 || errs[0].Error() != "first" || !errors.Is(errs[1], mserror.ErrNotFound) {
+		t.Fatalf("missing: errors = %v", errs)
+	}
+
+	w, _ = call(func(ctx *Context) error { return errors.New("db down") })
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "db down") {
+		t.Fatalf("boom: got %d %q, internal errors must not be exposed", w.Code, w.Body)
+	}
+
+	w, errs = call(func(ctx *Context) error {
+		_ = ctx.String(http.StatusAccepted, "partial")
+		return errors.New("after write")
+	})
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Fatalf("written: got %d %q", w.Code, w.Body)
+	}
+	if len(errs) != 1 || errs[0].Error() != "after write" {
+		t.Fatalf("written: errors = %v", errs)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "all.mslog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"db down", "after write", "not found"} {
+		if n := strings.Count(string(b), msg); n != 1 {
+			t.Errorf("%q logged %d times, want once:\n%s", msg, n, b)
+		}
+	}
+}
+
+func TestLastError(t *testing.T) {
+	ctx := &Context{}
+	if ctx.LastError() != nil {
+		t.Fatal("LastError without errors must be nil")
+	}
+	if ctx.Error(nil) != nil || len(ctx.Errors()) != 0 {
+		t.Fatal("nil errors must not be recorded")
+	}
+	first, last := errors.New("first"), errors.New("last")
+	ctx.Error(first)
+	ctx.Error(last)
+	if ctx.LastError() != last || len(ctx.Errors()) != 2 {
+		t.Fatalf("LastError = %v, Errors = %v", ctx.LastError(), ctx.Errors())
+	}
+}
@@ -1,14 +1,13 @@
 package engine
 
 import (
-	"errors"
 	"fmt"
-	"github.com/liyuanwu2020/msgo/mserror"
 	"github.com/liyuanwu2020/msgo/mslog"
+	"github.com/liyuanwu2020/msgo/ratelimit"
 	"net"
 	"net/http"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -52,46 +51,75 @@ func Logging(next HandlerFunc) HandlerFunc {
 	}
 }
 
+// LimiterConfig 限流中间件配置
+type LimiterConfig = ratelimit.Config[*Context]
+
+// Limiter 使用默认配置的限流中间件, 默认限流器在首次使用时创建, 未使用时不启动内存存储的清理协程
 func Limiter(next HandlerFunc) HandlerFunc {
-	return func(ctx *Context) {
-		//执行业务
-		next(ctx)
-	}
+	defaultLimiterOnce.Do(func() {
+		defaultLimiter = LimiterWithConfig(LimiterConfig{})
+	})
+	return defaultLimiter(next)
 }
 
-func Recovery(next HandlerFunc) HandlerFunc {
-	return func(ctx *Context) {
-		ctx.Logger.Info("load recovery")
-		defer func() {
-			if err := recover(); err != nil {
+var (
+	defaultLimiterOnce sync.Once
+	defaultLimiter     MiddlewareFunc
+)
+
+func LimiterWithConfig(conf LimiterConfig) MiddlewareFunc {
+	conf.SetDefaults(KeyByIP)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			result, err := conf.Allow(ctx, ctx.NodeRouterName)
+			if err != nil {
+				//存储不可用时放行, 避免限流组件故障导致服务不可用
 				ctx.Logger.Error(err)
-				ctx.Logger.Error(err.(error))
+				next(ctx)
 				return
-				if e := err.(error); e != nil {
-					var msError *mserror.MsError
-					if errors.As(e, &msError) {
-						msError.ExecResult()
-						return
-					}
+			}
+			result.WriteHeaders(ctx.W.Header())
+			if !result.Allowed {
+				if conf.LimitHandler != nil {
+					conf.LimitHandler(ctx, result)
+				} else {
+					ctx.Fail(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
 				}
-				ctx.Logger.Error(strings.Split(traceMsg(err), "\n"))
-				ctx.Fail(http.StatusInternalServerError, "Internal Server Error")
+				return
 			}
-		}()
-		next(ctx)
+			next(ctx)
+		}
 	}
 }
 
-func traceMsg(err any) string {
-	var sb strings.Builder
-	var pcs = make([]uintptr, 32)
-	n := runtime.Callers(3, pcs)
-	sb.WriteString(fmt.Sprintf("%v", err))
-	for _, pc := range pcs[:n] {
-		//函数
-		fn := runtime.FuncForPC(pc)
-		file, line := fn.FileLine(pc)
-		sb.WriteString(fmt.Sprintf("\n%s:%d", file, line))
+// KeyByIP 按客户端 IP 限流, 经过可信代理时使用转发头中的客户端地址
+func KeyByIP(ctx *Context) string {
+	return ratelimit.KeyIP(ctx.ClientIP().String())
+}
+
+// KeyByRoute 按路由模板限流
+func KeyByRoute(ctx *Context) string {
+	return ratelimit.KeyRoute(ctx.RequestMethod, ctx.NodeRouterName)
+}
+
+// KeyByHeader 按请求头限流, 如 API Key, 请求头不存在时按客户端 IP
+func KeyByHeader(name string) func(ctx *Context) string {
+	return func(ctx *Context) string {
+		if v := ctx.R.Header.Get(name); v != "" {
+			return ratelimit.KeyHeader(v)
+		}
+		return KeyByIP(ctx)
+	}
+}
+
+// KeyByClaim 按 JWT claims 中的字段限流, 如 sub, 未认证时按客户端 IP
+func KeyByClaim(name string) func(ctx *Context) string {
+	return func(ctx *Context) string {
+		if claims, ok := ctx.Get("claims"); ok {
+			if key, ok := ratelimit.KeyClaim(claims, name); ok {
+				return key
+			}
+		}
+		return KeyByIP(ctx)
 	}
-	return sb.String()
 }
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/liyuanwu2020/msgo/internal/recovery"
+	"github.com/liyuanwu2020/msgo/mserror"
+)
+
+// RecoveryConfig panic 恢复配置
+type RecoveryConfig = recovery.Config[*Context]
+
+// Recovery 恢复 panic, 记录堆栈并输出 500
+func Recovery(next HandlerFunc) HandlerFunc {
+	return defaultRecovery(next)
+}
+
+var defaultRecovery = RecoveryWithConfig(RecoveryConfig{})
+
+func RecoveryWithConfig(conf RecoveryConfig) MiddlewareFunc {
+	conf.SetDefaults()
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			//不依赖 ctx.W 的具体类型, 外层中间件包装 ResponseWriter 后仍能判断响应是否已发出
+			w := ctx.W
+			rw := &recovery.TrackWriter{ResponseWriter: w}
+			ctx.W = rw
+			defer func() {
+				ctx.W = w
+			}()
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				//交给 net/http 静默中断连接
+				if value == http.ErrAbortHandler {
+					panic(value)
+				}
+				err, _ := value.(error)
+				var msError *mserror.MsError
+				if errors.As(err, &msError) && msError.HasResult() {
+					msError.ExecResult()
+					return
+				}
+				stack := recovery.CaptureStack(3, conf.StackDepth, conf.StackFilter)
+				brokenPipe := recovery.IsBrokenPipe(err)
+				ctx.Logger.Error(conf.Message(ctx.R, value, stack, brokenPipe))
+				if conf.OnPanic != nil {
+					conf.OnPanic(ctx, value, stack)
+				}
+				//响应头已发出或连接已断开时不再输出
+				if rw.Written || brokenPipe {
+					return
+				}
+				if err == nil {
+					err = fmt.Errorf("panic: %v", value)
+				}
+				//已记录日志, 只记录错误并输出响应
+				ctx.Error(err)
+				ctx.renderError(err)
+			}()
+			next(ctx)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/mslog"
+)
+
+func TestRecovery(t *testing.T) {
+	cases := []struct {
+		name     string
+		handler  HandlerFunc
+		wantCode int
+		wantBody string
+	}{
+		{"panic", func(ctx *Context) { panic("boom") }, http.StatusInternalServerError, "*"},
+		//ctx.W 不是 statusWriter 时也要识别已输出的响应
+		{"written", func(ctx *Context) {
+			_ = ctx.String(http.StatusAccepted, "partial")
+			panic("boom")
+		}, http.StatusAccepted, "partial"},
+		{"broken pipe", func(ctx *Context) {
+			panic(fmt.Errorf("write tcp: %w", syscall.ECONNRESET))
+		}, http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		var stack []string
+		h := RecoveryWithConfig(RecoveryConfig{OnPanic: func(ctx *Context, value any, s []string) { stack = s }})(c.handler)
+		w := httptest.NewRecorder()
+		ctx := &Context{W: w, R: httptest.NewRequest(http.MethodGet, "/panic", nil), Logger: mslog.New(), engine: New()}
+		h(ctx)
+		if w.Code != c.wantCode {
+			t.Errorf("%s: code = %d, want %d", c.name, w.Code, c.wantCode)
+		}
+		//* 表示只检查状态码
+		if c.wantBody != "*" && w.Body.String() != c.wantBody {
+			t.Errorf("%s: body = %q, want %q", c.name, w.Body, c.wantBody)
+		}
+		if len(stack) == 0 {
+			t.Errorf("%s: no stack captured", c.name)
+		}
+		if ctx.W != w {
+			t.Errorf("%s: ctx.W not restored", c.name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package msgo
+
+import (
+	"net/http"
+
+	"github.com/liyuanwu2020/msgo/internal/recovery"
+	"github.com/liyuanwu2020/msgo/mserror"
+	"github.com/liyuanwu2020/msgo/mslog"
+)
+
+// HandlerFuncE 返回错误的处理函数, 通过 WrapE 注册到路由
+type HandlerFuncE func(ctx *Context) error
+
+// WrapE 把 HandlerFuncE 转换为 HandlerFunc, 返回的错误记录到 ctx.Errors 并交给 HandleWithError 输出,
+// 处理函数已输出响应时只记录日志
+func WrapE(h HandlerFuncE) HandlerFunc {
+	return func(ctx *Context) {
+		w := ctx.W
+		tw := &recovery.TrackWriter{ResponseWriter: w}
+		ctx.W = tw
+		err := h(ctx)
+		ctx.W = w
+		if err == nil {
+			return
+		}
+		if tw.Written {
+			ctx.Error(err)
+			ctx.logError(err)
+			return
+		}
+		ctx.HandleWithError(http.StatusOK, nil, err)
+	}
+}
+
+// Error 记录处理过程中的错误, 中间件可在 next 返回后通过 Errors 读取
+func (c *Context) Error(err error) error {
+	if err != nil {
+		c.errors = append(c.errors, err)
+	}
+	return err
+}
+
+// Errors 按发生顺序返回本次请求记录的错误
+func (c *Context) Errors() []error {
+	return c.errors
+}
+
+// LastError 最后一个记录的错误, 没有时为 nil
+func (c *Context) LastError() error {
+	if len(c.errors) == 0 {
+		return nil
+	}
+	return c.errors[len(c.errors)-1]
+}
+
+// logError 带请求信息记录错误, 5xx 记为 Error, 其余记为 Info
+func (c *Context) logError(err error) {
+	if c.Logger == nil {
+		return
+	}
+	e := mserror.Wrap(err)
+	logger := c.Logger.WithFields(mslog.Fields{
+		"method": c.R.Method,
+		"path":   c.R.URL.Path,
+		"route":  c.RoutePattern(),
+		"status": e.Status,
+		"code":   e.Code,
+	})
+	if e.Status >= http.StatusInternalServerError {
+		logger.Error(err.Error())
+		return
+	}
+	logger.Info(err.Error())
+}
+
+// renderError 有注册的错误处理器时交给处理器, 否则按 Engine.ErrorFormat 输出
+func (c *Context) renderError(err error) {
+	if c.engine.errorHandler != nil {
+		code, data := c.engine.errorHandler(err)
+		_ = c.JSON(code, data)
+		return
+	}
+	_ = c.RenderError(err)
+}
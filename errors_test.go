@@ -0,0 +1,130 @@
+package msgo
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/mserror"
+	"github.com/liyuanwu2020/msgo/mslog"
+)
+
+// newLogEngine 日志写入临时目录, 返回读取全部日志的函数
+func newLogEngine(t *testing.T) (*Engine, func() string) {
+	t.Helper()
+	dir := t.TempDir()
+	e := newTestEngine()
+	e.Logger = mslog.New()
+	e.Logger.Formatter = mslog.TextFormat
+	e.Logger.SetLogPath(dir)
+	return e, func() string {
+		b, err := os.ReadFile(filepath.Join(dir, "all.mslog"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+}
+
+func TestWrapE(t *testing.T) {
+	e, logs := newLogEngine(t)
+	var errs []error
+	collect := func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			errs = ctx.Errors()
+		}
+	}
+	g := e.Group("api")
+	g.Get("/ok", WrapE(func(ctx *Context) error { return ctx.String(http.StatusOK, "ok") }), collect)
+	g.Get("/missing", WrapE(func(ctx *Context) error {
+		ctx.Error(errors.New("first"))
+		return mserror.ErrNotFound
+	}), collect)
+	g.Get("/boom", WrapE(func(ctx *Context) error { return errors.New("db down") }), collect)
+	g.Get("/written", WrapE(func(ctx *Context) error {
+		_ = ctx.String(http.StatusAccepted, "partial")
+		return errors.New("after write")
+	}), collect)
+
+	if w := serve(e, http.MethodGet, "/api/ok", nil); w.Code != http.StatusOK || len(errs) != 0 {
+		t.Fatalf("ok: got %d, errors %v", w.Code, errs)
+	}
+
+	w := serve(e, http.MethodGet, "/api/missing", nil)
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != mserror.ProblemContentType {
+		t.Fatalf("missing: got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if len(errs) != 2 || errs[0].Error() != "first" || !errors.Is(errs[1], mserror.ErrNotFound) {
+		t.Fatalf("missing: errors = %v", errs)
+	}
+
+	w = serve(e, http.MethodGet, "/api/boom", nil)
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "db down") {
+		t.Fatalf("boom: got %d %q, internal errors must not be exposed", w.Code, w.Body)
+	}
+
+	w = serve(e, http.MethodGet, "/api/written", nil)
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Fatalf("written: got %d %q", w.Code, w.Body)
+	}
+	if len(errs) != 1 || errs[0].Error() != "after write" {
+		t.Fatalf("written: errors = %v", errs)
+	}
+
+	log := logs()
+	for _, msg := range []string{"db down", "after write", "not found"} {
+		if n := strings.Count(log, msg); n != 1 {
+			t.Errorf("%q logged %d times, want once:\n%s", msg, n, log)
+		}
+	}
+}
+
+func TestLastError(t *testing.T) {
+	ctx := &Context{}
+	if ctx.LastError() != nil {
+		t.Fatal("LastError without errors must be nil")
+	}
+	if ctx.Error(nil) != nil || len(ctx.Errors()) != 0 {
+		t.Fatal("nil errors must not be recorded")
+	}
+	first, last := errors.New("first"), errors.New("last")
+	ctx.Error(first)
+	ctx.Error(last)
+	if ctx.LastError() != last || len(ctx.Errors()) != 2 {
+		t.Fatalf("LastError = %v, Errors = %v", ctx.LastError(), ctx.Errors())
+	}
+	ctx.reset(nil, nil)
+	if len(ctx.Errors()) != 0 {
+		t.Fatal("reset must clear errors")
+	}
+}
+
+type stringer struct{}
+
+func (stringer) String() string { return "stringer" }
+
+func TestFail(t *testing.T) {
+	cases := []struct {
+		msg         any
+		body        string
+		contentType string
+	}{
+		{"plain %d", "plain %d", "text/plain"},
+		{errors.New("err"), "err", "text/plain"},
+		{stringer{}, "stringer", "text/plain"},
+		{map[string]int{"code": 1}, `{"code":1}`, "application/json"},
+	}
+	for _, c := range cases {
+		e := newTestEngine()
+		e.Group("api").Get("/fail", func(ctx *Context) { ctx.Fail(http.StatusBadRequest, c.msg) })
+		w := serve(e, http.MethodGet, "/api/fail", nil)
+		if w.Code != http.StatusBadRequest || strings.TrimSpace(w.Body.String()) != c.body ||
+			!strings.HasPrefix(w.Header().Get("Content-Type"), c.contentType) {
+			t.Errorf("Fail(%v) = %d %q %q", c.msg, w.Code, w.Body, w.Header().Get("Content-Type"))
+		}
+	}
+}
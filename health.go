@@ -0,0 +1,65 @@
+package msgo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/health"
+)
+
+// HealthCheck 注册存活和就绪检查地址, 返回检查注册表用于添加检查
+// 检查地址在路由分组之前处理, 不经过中间件
+func (e *Engine) HealthCheck(livePath, readyPath string) *health.Registry {
+	if e.health == nil {
+		e.health = health.NewRegistry()
+	}
+	if e.healthHandlers == nil {
+		e.healthHandlers = make(map[string]http.Handler)
+	}
+	e.healthHandlers[livePath] = e.health.Handler(false)
+	e.healthHandlers[readyPath] = e.health.Handler(true)
+	return e.health
+}
+
+// serveHealth 处理健康检查请求
+func (e *Engine) serveHealth(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	h, ok := e.healthHandlers[r.URL.Path]
+	if !ok {
+		return false
+	}
+	h.ServeHTTP(w, r)
+	return true
+}
+
+// Shutdown 优雅关闭: 先将就绪检查置为失败, 等待 ShutdownDelay 让负载均衡摘除流量, 再关闭 http 服务
+func (e *Engine) Shutdown(ctx context.Context) error {
+	if e.health != nil {
+		e.health.SetShuttingDown()
+	}
+	if e.ShutdownDelay > 0 {
+		timer := time.NewTimer(e.ShutdownDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	e.serverMu.Lock()
+	srv := e.server
+	e.serverMu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+func (e *Engine) setServer(srv *http.Server) {
+	e.serverMu.Lock()
+	e.server = srv
+	e.serverMu.Unlock()
+}
@@ -0,0 +1,227 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/mspool"
+	"github.com/liyuanwu2020/msgo/register"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DefaultTimeout 单项检查默认超时时间
+const DefaultTimeout = 2 * time.Second
+
+var (
+	ErrTimeout      = errors.New("health: check timed out")
+	ErrShuttingDown = errors.New("health: shutting down")
+)
+
+// CheckFunc 检查函数, 返回 nil 表示健康
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	fn       CheckFunc
+	timeout  time.Duration
+	liveness bool
+}
+
+// Result 单项检查结果
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report 检查报告, 任一检查失败时 Status 为 down
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Registry 检查注册表, 存活检查只运行 AddLiveness 注册的检查, 就绪检查运行全部检查
+type Registry struct {
+	mu           sync.RWMutex
+	checks       []check
+	shuttingDown int32
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Add 注册就绪检查, timeout 为 0 时使用 DefaultTimeout
+func (r *Registry) Add(name string, fn CheckFunc, timeout time.Duration) *Registry {
+	return r.add(check{name: name, fn: fn, timeout: timeout})
+}
+
+// AddLiveness 注册存活检查, 同时参与就绪检查
+func (r *Registry) AddLiveness(name string, fn CheckFunc, timeout time.Duration) *Registry {
+	return r.add(check{name: name, fn: fn, timeout: timeout, liveness: true})
+}
+
+func (r *Registry) add(c check) *Registry {
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	r.mu.Lock()
+	r.checks = append(r.checks, c)
+	r.mu.Unlock()
+	return r
+}
+
+// SetShuttingDown 标记进入优雅关闭, 之后就绪检查始终失败
+func (r *Registry) SetShuttingDown() {
+	atomic.StoreInt32(&r.shuttingDown, 1)
+}
+
+func (r *Registry) ShuttingDown() bool {
+	return atomic.LoadInt32(&r.shuttingDown) == 1
+}
+
+// Liveness 运行存活检查
+func (r *Registry) Liveness(ctx context.Context) Report {
+	return r.run(ctx, true)
+}
+
+// Readiness 运行全部检查, 优雅关闭期间直接返回失败
+func (r *Registry) Readiness(ctx context.Context) Report {
+	if r.ShuttingDown() {
+		return Report{Status: StatusDown, Checks: map[string]Result{
+			"shutdown": {Status: StatusDown, Error: ErrShuttingDown.Error(), Duration: "0s"},
+		}}
+	}
+	return r.run(ctx, false)
+}
+
+// run 并发运行检查, 每项检查有独立的超时时间
+func (r *Registry) run(ctx context.Context, livenessOnly bool) Report {
+	r.mu.RLock()
+	checks := make([]check, 0, len(r.checks))
+	for _, c := range r.checks {
+		if !livenessOnly || c.liveness {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks))}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, checks[i])
+		}(i)
+	}
+	wg.Wait()
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- fmt.Errorf("health: check panic: %v", err)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrTimeout
+	}
+	result := Result{Status: StatusUp, Duration: time.Since(start).Round(time.Microsecond).String()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Handler 输出 JSON 报告, 失败时返回 503
+func (r *Registry) Handler(readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var report Report
+		if readiness {
+			report = r.Readiness(req.Context())
+		} else {
+			report = r.Liveness(req.Context())
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		status := http.StatusOK
+		if report.Status != StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		if req.Method != http.MethodHead {
+			_ = json.NewEncoder(w).Encode(report)
+		}
+	})
+}
+
+// Pinger 支持连通性检查的对象, 如 orm.MsDb
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// PingCheck 数据库等连接的连通性检查
+func PingCheck(p Pinger) CheckFunc {
+	return p.Ping
+}
+
+// RegisterCheck 注册中心连通性检查, 注册中心需要实现 Pinger
+func RegisterCheck(r register.MsRegister) CheckFunc {
+	return func(ctx context.Context) error {
+		p, ok := r.(Pinger)
+		if !ok {
+			return fmt.Errorf("health: %T does not support ping", r)
+		}
+		return p.Ping(ctx)
+	}
+}
+
+// PoolCheck 协程池饱和度检查, 运行中的 worker 占比达到 threshold 时失败, threshold 默认 0.9
+func PoolCheck(p *mspool.Pool, threshold float64) CheckFunc {
+	if threshold <= 0 || threshold > 1 {
+		threshold = 0.9
+	}
+	return func(ctx context.Context) error {
+		if p.IsClosed() {
+			return errors.New("health: pool is closed")
+		}
+		running, free := p.Running(), p.Free()
+		total := running + free
+		if total <= 0 {
+			return nil
+		}
+		if usage := float64(running) / float64(total); usage >= threshold {
+			return fmt.Errorf("health: pool saturated, %d/%d workers running", running, total)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/mspool"
+)
+
+func TestLivenessAndReadiness(t *testing.T) {
+	r := NewRegistry()
+	r.AddLiveness("self", func(ctx context.Context) error { return nil }, 0)
+	r.Add("db", func(ctx context.Context) error { return errors.New("connection refused") }, 0)
+
+	if report := r.Liveness(context.Background()); report.Status != StatusUp || len(report.Checks) != 1 {
+		t.Fatalf("liveness must only run liveness checks, got %+v", report)
+	}
+	report := r.Readiness(context.Background())
+	if report.Status != StatusDown || report.Checks["db"].Error != "connection refused" || report.Checks["self"].Status != StatusUp {
+		t.Fatalf("unexpected readiness report %+v", report)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	r := NewRegistry()
+	r.Add("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}, 20*time.Millisecond)
+	start := time.Now()
+	report := r.Readiness(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("check must not wait past its timeout")
+	}
+	if report.Checks["slow"].Error != ErrTimeout.Error() {
+		t.Fatalf("expected timeout, got %+v", report)
+	}
+}
+
+func TestShuttingDown(t *testing.T) {
+	r := NewRegistry()
+	h := r.Handler(true)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	r.SetShuttingDown()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Fatalf("readiness must fail during shutdown, got %d %+v", w.Code, report)
+	}
+	w = httptest.NewRecorder()
+	r.Handler(false).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("liveness must stay up during shutdown, got %d", w.Code)
+	}
+}
+
+func TestPoolCheck(t *testing.T) {
+	p, err := mspool.NewPool(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+	check := PoolCheck(p, 0.5)
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("idle pool must be healthy: %v", err)
+	}
+	block := make(chan struct{})
+	defer close(block)
+	_ = p.Submit(func() { <-block })
+	time.Sleep(20 * time.Millisecond)
+	if err := check(context.Background()); err == nil {
+		t.Fatal("pool at threshold must be unhealthy")
+	}
+}
@@ -0,0 +1,217 @@
+package clientip
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies 可信代理的地址段, 只有来自可信代理的请求才解析转发头
+type TrustedProxies []*net.IPNet
+
+// ParseCIDRs 解析 CIDR 或 IP 列表, 单个 IP 按 /32 或 /128 处理
+func ParseCIDRs(values []string) (TrustedProxies, error) {
+	cidrs := make(TrustedProxies, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: v}
+			}
+			if ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+func (p TrustedProxies) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range p {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHostIP 解析 ip, ip:port, [ipv6]:port 形式的地址
+func ParseHostIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
+
+// forwardedElement RFC 7239 Forwarded 头中的一个代理节点
+type forwardedElement struct {
+	For   string
+	Proto string
+	Host  string
+}
+
+func parseForwarded(header string) []forwardedElement {
+	var elements []forwardedElement
+	for _, element := range strings.Split(header, ",") {
+		var fe forwardedElement
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "for":
+				fe.For = value
+			case "proto":
+				fe.Proto = strings.ToLower(value)
+			case "host":
+				fe.Host = value
+			}
+		}
+		elements = append(elements, fe)
+	}
+	return elements
+}
+
+// FromTrustedProxy 请求是否由可信代理转发
+func (p TrustedProxies) FromTrustedProxy(r *http.Request) bool {
+	return p.Contains(ParseHostIP(r.RemoteAddr))
+}
+
+// ClientIP 获取客户端 IP, 请求来自可信代理时依次解析 Forwarded, X-Forwarded-For, X-Real-IP
+// 转发链从右向左跳过可信代理, 第一个不可信的地址即客户端地址
+func (p TrustedProxies) ClientIP(r *http.Request) net.IP {
+	remote := ParseHostIP(r.RemoteAddr)
+	if !p.Contains(remote) {
+		return remote
+	}
+	if header := r.Header.Get("Forwarded"); header != "" {
+		elements := parseForwarded(header)
+		chain := make([]string, len(elements))
+		for i, fe := range elements {
+			chain[i] = fe.For
+		}
+		if ip := p.walkChain(chain); ip != nil {
+			return ip
+		}
+	}
+	if header := r.Header.Values("X-Forwarded-For"); len(header) > 0 {
+		if ip := p.walkChain(strings.Split(strings.Join(header, ","), ",")); ip != nil {
+			return ip
+		}
+	}
+	if ip := ParseHostIP(r.Header.Get("X-Real-IP")); ip != nil {
+		return ip
+	}
+	return remote
+}
+
+func (p TrustedProxies) walkChain(chain []string) net.IP {
+	var ip net.IP
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := ParseHostIP(chain[i])
+		if hop == nil {
+			//unknown 或混淆标识, 无法继续向前追溯
+			return ip
+		}
+		ip = hop
+		if !p.Contains(hop) {
+			return hop
+		}
+	}
+	return ip
+}
+
+// Scheme 获取请求协议, 可信代理转发时使用 Forwarded proto 或 X-Forwarded-Proto
+// 与 ClientIP 相同, 从右向左跳过可信代理, 使用离客户端最近的可信代理记录的值
+func (p TrustedProxies) Scheme(r *http.Request) string {
+	if p.FromTrustedProxy(r) {
+		if header := r.Header.Get("Forwarded"); header != "" {
+			elements := parseForwarded(header)
+			chain := make([]string, len(elements))
+			protos := make([]string, len(elements))
+			for i, fe := range elements {
+				chain[i], protos[i] = fe.For, fe.Proto
+			}
+			if proto := p.forwardedValue(chain, protos); proto != "" {
+				return proto
+			}
+		}
+		if proto := p.forwardedValue(headerList(r.Header, "X-Forwarded-For"), headerList(r.Header, "X-Forwarded-Proto")); proto != "" {
+			return strings.ToLower(proto)
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host 获取请求主机名, 可信代理转发时使用 Forwarded host 或 X-Forwarded-Host, 规则同 Scheme
+func (p TrustedProxies) Host(r *http.Request) string {
+	if p.FromTrustedProxy(r) {
+		if header := r.Header.Get("Forwarded"); header != "" {
+			elements := parseForwarded(header)
+			chain := make([]string, len(elements))
+			hosts := make([]string, len(elements))
+			for i, fe := range elements {
+				chain[i], hosts[i] = fe.For, fe.Host
+			}
+			if host := p.forwardedValue(chain, hosts); host != "" {
+				return host
+			}
+		}
+		if host := p.forwardedValue(headerList(r.Header, "X-Forwarded-For"), headerList(r.Header, "X-Forwarded-Host")); host != "" {
+			return host
+		}
+	}
+	return r.Host
+}
+
+// headerList 合并多个同名头并按逗号拆分
+func headerList(header http.Header, name string) []string {
+	values := header.Values(name)
+	if len(values) == 0 {
+		return nil
+	}
+	list := strings.Split(strings.Join(values, ","), ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list
+}
+
+// forwardedValue 每个代理在 chain 和 values 末尾各追加一项, 从右向左跳过 chain 中的可信代理,
+// 返回第一个不可信地址对应的值, 该值为空时向右取可信代理记录的值
+func (p TrustedProxies) forwardedValue(chain, values []string) string {
+	hops := 0
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !p.Contains(ParseHostIP(chain[i])) {
+			break
+		}
+		hops++
+	}
+	i := len(values) - 1 - hops
+	if i < 0 {
+		i = 0
+	}
+	for ; i < len(values); i++ {
+		if values[i] != "" {
+			return values[i]
+		}
+	}
+	return ""
+}
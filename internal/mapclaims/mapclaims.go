@@ -0,0 +1,16 @@
+package mapclaims
+
+import "reflect"
+
+// Get 读取 map 类型 claims 中的字段, 兼容 jwt.MapClaims, 不存在时返回 nil
+func Get(claims any, name string) any {
+	v := reflect.ValueOf(claims)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	value := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+	if !value.IsValid() {
+		return nil
+	}
+	return value.Interface()
+}
@@ -0,0 +1,150 @@
+package recovery
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Config panic 恢复配置, C 为框架的请求上下文类型
+type Config[C any] struct {
+	//StackDepth 最多捕获的堆栈帧数, 默认 32
+	StackDepth int
+	//StackFilter 返回 false 的帧不记录, 默认过滤 Go 运行时的帧
+	StackFilter func(frame runtime.Frame) bool
+	//DumpRequest 日志中输出请求行和请求头, SensitiveHeaders 中的请求头脱敏输出
+	DumpRequest bool
+	//SensitiveHeaders 脱敏的请求头, 默认 Authorization、Proxy-Authorization、Cookie、X-Api-Key、X-CSRF-Token
+	SensitiveHeaders []string
+	//OnPanic panic 回调, 可用于告警上报或输出自定义响应, 回调未输出响应时按 HandleWithError 输出 500
+	OnPanic func(ctx C, value any, stack []string)
+}
+
+var defaultSensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key", "X-CSRF-Token"}
+
+// SetDefaults 填充默认配置, 创建中间件时调用一次
+func (conf *Config[C]) SetDefaults() {
+	if conf.StackDepth <= 0 {
+		conf.StackDepth = 32
+	}
+	if conf.StackFilter == nil {
+		conf.StackFilter = skipRuntimeFrame
+	}
+	if conf.SensitiveHeaders == nil {
+		conf.SensitiveHeaders = defaultSensitiveHeaders
+	}
+}
+
+// Message 日志内容, 包含 panic 值、可选的请求头和堆栈
+func (conf *Config[C]) Message(r *http.Request, value any, stack []string, brokenPipe bool) string {
+	var sb strings.Builder
+	if brokenPipe {
+		sb.WriteString("connection broken: ")
+	} else {
+		sb.WriteString("panic recovered: ")
+	}
+	sb.WriteString(fmt.Sprintf("%v", value))
+	if conf.DumpRequest {
+		sb.WriteString("\n")
+		sb.WriteString(DumpRequestHeaders(r, conf.SensitiveHeaders))
+	}
+	for _, line := range stack {
+		sb.WriteString("\n\t")
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// CaptureStack 返回 file:line function 格式的堆栈, skip 为跳过的调用层数, 需要在 recover 的函数中直接调用
+func CaptureStack(skip, depth int, filter func(frame runtime.Frame) bool) []string {
+	pcs := make([]uintptr, depth)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack []string
+	for {
+		frame, more := frames.Next()
+		if filter(frame) {
+			stack = append(stack, fmt.Sprintf("%s:%d %s", frame.File, frame.Line, frame.Function))
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+func skipRuntimeFrame(frame runtime.Frame) bool {
+	return !strings.HasPrefix(frame.Function, "runtime.")
+}
+
+// DumpRequestHeaders 输出请求行和按名称排序的请求头, 敏感请求头只保留名称
+func DumpRequestHeaders(r *http.Request, sensitive []string) string {
+	var sb strings.Builder
+	sb.WriteString(r.Method + " " + r.URL.RequestURI() + " " + r.Proto)
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(r.Header[name], ", ")
+		for _, s := range sensitive {
+			if strings.EqualFold(name, s) {
+				value = "******"
+				break
+			}
+		}
+		sb.WriteString("\n" + name + ": " + value)
+	}
+	return sb.String()
+}
+
+// IsBrokenPipe 客户端已断开连接, 无法再输出响应
+func IsBrokenPipe(err error) bool {
+	return err != nil && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET))
+}
+
+// TrackWriter 记录响应头是否已发出, 用于避免重复输出响应
+type TrackWriter struct {
+	http.ResponseWriter
+	Written bool
+}
+
+// Unwrap 供 http.ResponseController 等访问原始 ResponseWriter
+func (w *TrackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *TrackWriter) WriteHeader(code int) {
+	//1xx 信息响应之后仍可输出最终响应
+	if code >= http.StatusOK {
+		w.Written = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *TrackWriter) Write(b []byte) (int, error) {
+	w.Written = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *TrackWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.Written = true
+		f.Flush()
+	}
+}
+
+func (w *TrackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.Written = true
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijack")
+}
@@ -0,0 +1,67 @@
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestDumpRequestHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/login?next=/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("X-Request-Id", "abc")
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	want := "POST /login?next=/ HTTP/1.1\nAccept: text/html, application/json\nAuthorization: ******\nX-Request-Id: abc"
+	if got := DumpRequestHeaders(r, defaultSensitiveHeaders); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestIsBrokenPipe(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("write: %w", syscall.EPIPE), true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{errors.New("boom"), false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := IsBrokenPipe(c.err); got != c.want {
+			t.Errorf("IsBrokenPipe(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	conf := Config[any]{DumpRequest: true}
+	conf.SetDefaults()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Cookie", "session=1")
+	msg := conf.Message(r, "boom", []string{"a.go:1 main.f"}, false)
+	if !strings.HasPrefix(msg, "panic recovered: boom\nGET / HTTP/1.1") || strings.Contains(msg, "session=1") ||
+		!strings.HasSuffix(msg, "\n\ta.go:1 main.f") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+	if msg := conf.Message(r, "eof", nil, true); !strings.HasPrefix(msg, "connection broken: eof") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+func TestTrackWriter(t *testing.T) {
+	w := &TrackWriter{ResponseWriter: httptest.NewRecorder()}
+	w.WriteHeader(http.StatusContinue)
+	if w.Written {
+		t.Fatal("1xx responses must not mark the response as written")
+	}
+	w.WriteHeader(http.StatusOK)
+	if !w.Written {
+		t.Fatal("final status must mark the response as written")
+	}
+}
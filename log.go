@@ -6,7 +6,6 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -90,8 +89,7 @@ func LoggerWithConfig(cnf LoggerConfig, next HandlerFunc) HandlerFunc {
 		// stop timer
 		stop := time.Now()
 		latency := stop.Sub(start)
-		ip, _, _ := net.SplitHostPort(strings.TrimSpace(ctx.R.RemoteAddr))
-		clientIP := net.ParseIP(ip)
+		clientIP := ctx.ClientIP()
 		if raw != "" {
 			path = path + "?" + raw
 		}
@@ -0,0 +1,102 @@
+package msgo
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/metrics"
+)
+
+// MetricsConfig 请求指标配置
+type MetricsConfig struct {
+	//Registry 指标注册表, 默认 metrics.DefaultRegistry
+	Registry *metrics.Registry
+	//Buckets 耗时直方图桶, 默认 metrics.DefBuckets, 同一注册表以首次配置为准
+	Buckets []float64
+}
+
+// Metrics 使用默认注册表记录请求数、耗时和处理中的请求数
+func Metrics(next HandlerFunc) HandlerFunc {
+	//首次使用时注册, 未使用该中间件时不输出 http 指标
+	return MetricsWithConfig(MetricsConfig{})(next)
+}
+
+// httpMetrics 一个注册表上的 http 指标
+type httpMetrics struct {
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+	inFlight *metrics.GaugeVec
+}
+
+var (
+	httpMetricsMu sync.Mutex
+	httpMetricsOf = make(map[*metrics.Registry]*httpMetrics)
+)
+
+// registerHTTPMetrics 每个注册表只注册一次, 之后复用已注册的指标, Buckets 以首次注册为准
+func registerHTTPMetrics(registry *metrics.Registry, buckets []float64) *httpMetrics {
+	httpMetricsMu.Lock()
+	defer httpMetricsMu.Unlock()
+	if m, ok := httpMetricsOf[registry]; ok {
+		return m
+	}
+	m := &httpMetrics{
+		requests: metrics.NewCounterVec("msgo_http_requests_total",
+			"Total number of HTTP requests.", "method", "route", "status"),
+		duration: metrics.NewHistogramVec("msgo_http_request_duration_seconds",
+			"HTTP request latency in seconds.", buckets, "method", "route"),
+		inFlight: metrics.NewGaugeVec("msgo_http_requests_in_flight",
+			"Number of HTTP requests currently being served.", "method", "route"),
+	}
+	registry.MustRegister(m.requests, m.duration, m.inFlight)
+	httpMetricsOf[registry] = m
+	return m
+}
+
+// MetricsWithConfig 可以多次调用, 如按路由分组使用, 同一注册表上的中间件共享指标
+func MetricsWithConfig(conf MetricsConfig) MiddlewareFunc {
+	if conf.Registry == nil {
+		conf.Registry = metrics.DefaultRegistry
+	}
+	m := registerHTTPMetrics(conf.Registry, conf.Buckets)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			start := time.Now()
+			method, route := ctx.R.Method, ctx.RoutePattern()
+			m.inFlight.Inc(method, route)
+			defer func() {
+				m.inFlight.Dec(method, route)
+				status := ctx.StatusCode
+				if status == 0 {
+					status = 200
+				}
+				m.requests.Inc(method, route, strconv.Itoa(status))
+				m.duration.Observe(time.Since(start).Seconds(), method, route)
+			}()
+			next(ctx)
+		}
+	}
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出指标, registry 为空时使用默认注册表
+func MetricsHandler(registry *metrics.Registry) HandlerFunc {
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	handler := registry.Handler()
+	return func(ctx *Context) {
+		handler.ServeHTTP(ctx.W, ctx.R)
+	}
+}
+
+// RoutePattern 匹配到的路由模板, 如 /api/user/:id, 未匹配时为空
+func (c *Context) RoutePattern() string {
+	if c.NodeRouterName == "" {
+		return ""
+	}
+	if c.groupName == "" {
+		return c.NodeRouterName
+	}
+	return "/" + c.groupName + c.NodeRouterName
+}
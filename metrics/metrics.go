@@ -0,0 +1,421 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets 默认直方图桶, 单位秒
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultRegistry 默认注册表, orm 和 rpc/tcp 的指标注册在这里
+var DefaultRegistry = NewRegistry()
+
+var errDuplicate = errors.New("metrics: duplicate metric name")
+
+// Collector 可注册到 Registry 的指标
+type Collector interface {
+	name() string
+	writeText(w *bufio.Writer)
+}
+
+// Registry 指标注册表, 以 Prometheus 文本格式输出
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+	pools      *poolCollectors
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[c.name()]; ok {
+		return fmt.Errorf("%w: %s", errDuplicate, c.name())
+	}
+	r.collectors[c.name()] = c
+	return nil
+}
+
+func (r *Registry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// MustRegister 注册到默认注册表
+func MustRegister(cs ...Collector) {
+	DefaultRegistry.MustRegister(cs...)
+}
+
+// WriteText 按指标名排序输出 Prometheus 文本格式
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]Collector, len(names))
+	for i, name := range names {
+		collectors[i] = r.collectors[name]
+	}
+	r.mu.RUnlock()
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.writeText(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler 可挂载的 http.Handler
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WriteText(w)
+	})
+}
+
+// desc 指标公共描述
+type desc struct {
+	metricName string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, d.typ)
+}
+
+// labels 生成 {a="1",b="2"} 形式的标签, extra 为附加的 name=value 对
+func (d *desc) labels(values []string, extra ...string) string {
+	if len(d.labelNames) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range d.labelNames {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(values[i]))
+		sb.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if sb.Len() > 1 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extra[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(extra[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// seriesMap 按标签值保存序列
+type seriesMap[T any] struct {
+	desc
+	mu     sync.RWMutex
+	series map[string]*T
+	values map[string][]string
+	newFn  func() *T
+}
+
+func (m *seriesMap[T]) get(labelValues []string) *T {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", m.metricName, len(m.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	m.mu.RLock()
+	s, ok := m.series[key]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok = m.series[key]; ok {
+		return s
+	}
+	s = m.newFn()
+	m.series[key] = s
+	m.values[key] = append([]string(nil), labelValues...)
+	return s
+}
+
+// each 按标签排序遍历序列, 保证输出稳定
+func (m *seriesMap[T]) each(fn func(labelValues []string, s *T)) {
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	series := make([]*T, len(keys))
+	values := make([][]string, len(keys))
+	for i, k := range keys {
+		series[i] = m.series[k]
+		values[i] = m.values[k]
+	}
+	m.mu.RUnlock()
+	for i := range keys {
+		fn(values[i], series[i])
+	}
+}
+
+func newSeriesMap[T any](name, help, typ string, labelNames []string, newFn func() *T) seriesMap[T] {
+	return seriesMap[T]{
+		desc:   desc{metricName: name, help: help, typ: typ, labelNames: labelNames},
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+		newFn:  newFn,
+	}
+}
+
+// value 原子浮点数
+type value struct {
+	bits uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		n := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&v.bits, old, n) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64) {
+	atomic.StoreUint64(&v.bits, math.Float64bits(f))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+// CounterVec 只增计数器
+type CounterVec struct {
+	seriesMap[value]
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newSeriesMap(name, help, "counter", labelNames, func() *value { return &value{} })}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.get(labelValues).add(delta)
+}
+
+func (c *CounterVec) writeText(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.each(func(labelValues []string, v *value) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labels(labelValues), formatFloat(v.get()))
+	})
+}
+
+// GaugeVec 可增减的瞬时值
+type GaugeVec struct {
+	seriesMap[value]
+}
+
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newSeriesMap(name, help, "gauge", labelNames, func() *value { return &value{} })}
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.get(labelValues).set(v)
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.get(labelValues).add(delta)
+}
+
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *GaugeVec) writeText(w *bufio.Writer) {
+	g.writeHeader(w)
+	g.each(func(labelValues []string, v *value) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labels(labelValues), formatFloat(v.get()))
+	})
+}
+
+// GaugeFuncVec 输出时调用函数取值, 适合协程池等已有统计的对象
+type GaugeFuncVec struct {
+	seriesMap[gaugeFunc]
+}
+
+type gaugeFunc struct {
+	mu sync.Mutex
+	fn func() float64
+}
+
+func NewGaugeFuncVec(name, help string, labelNames ...string) *GaugeFuncVec {
+	return &GaugeFuncVec{newSeriesMap(name, help, "gauge", labelNames, func() *gaugeFunc { return &gaugeFunc{} })}
+}
+
+// Set 设置标签对应的取值函数
+func (g *GaugeFuncVec) Set(fn func() float64, labelValues ...string) {
+	s := g.get(labelValues)
+	s.mu.Lock()
+	s.fn = fn
+	s.mu.Unlock()
+}
+
+func (g *GaugeFuncVec) writeText(w *bufio.Writer) {
+	g.writeHeader(w)
+	g.each(func(labelValues []string, s *gaugeFunc) {
+		s.mu.Lock()
+		fn := s.fn
+		s.mu.Unlock()
+		if fn != nil {
+			fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labels(labelValues), formatFloat(fn()))
+		}
+	})
+}
+
+// HistogramVec 直方图
+type HistogramVec struct {
+	seriesMap[histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    value
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{buckets: buckets}
+	h.seriesMap = newSeriesMap(name, help, "histogram", labelNames, func() *histogram {
+		return &histogram{counts: make([]uint64, len(buckets))}
+	})
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	s := h.get(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		atomic.AddUint64(&s.counts[i], 1)
+	}
+	atomic.AddUint64(&s.count, 1)
+	s.sum.add(v)
+}
+
+func (h *HistogramVec) writeText(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.each(func(labelValues []string, s *histogram) {
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += atomic.LoadUint64(&s.counts[i])
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels(labelValues, "le", formatFloat(upper)), cumulative)
+		}
+		count := atomic.LoadUint64(&s.count)
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels(labelValues, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labels(labelValues), formatFloat(s.sum.get()))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labels(labelValues), count)
+	})
+}
+
+// PoolStats 协程池统计, mspool.Pool 实现了该接口
+type PoolStats interface {
+	Running() int
+	Free() int
+}
+
+type poolCollectors struct {
+	running *GaugeFuncVec
+	free    *GaugeFuncVec
+}
+
+// RegisterPool 注册协程池的运行中和空闲 worker 数量
+func (r *Registry) RegisterPool(name string, p PoolStats) {
+	r.mu.Lock()
+	pools := r.pools
+	if pools == nil {
+		pools = &poolCollectors{
+			running: NewGaugeFuncVec("msgo_pool_running_workers", "Number of running workers in the pool.", "pool"),
+			free:    NewGaugeFuncVec("msgo_pool_free_workers", "Number of free worker slots in the pool.", "pool"),
+		}
+		r.pools = pools
+		r.collectors[pools.running.name()] = pools.running
+		r.collectors[pools.free.name()] = pools.free
+	}
+	r.mu.Unlock()
+	pools.running.Set(func() float64 { return float64(p.Running()) }, name)
+	pools.free.Set(func() float64 { return float64(p.Free()) }, name)
+}
+
+// RegisterPool 注册协程池到默认注册表
+func RegisterPool(name string, p PoolStats) {
+	DefaultRegistry.RegisterPool(name, p)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
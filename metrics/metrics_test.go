@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func writeText(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounterVec("requests_total", "Total requests.", "route")
+	g := NewGaugeVec("in_flight", "In flight.")
+	r.MustRegister(c, g)
+	c.Inc("/user/:id")
+	c.Add(2, "/user/:id")
+	c.Inc(`/a"b`)
+	g.Inc()
+	g.Inc()
+	g.Dec()
+
+	out := writeText(t, r)
+	for _, want := range []string{
+		"# TYPE in_flight gauge\nin_flight 1\n",
+		"# HELP requests_total Total requests.\n# TYPE requests_total counter\n",
+		`requests_total{route="/a\"b"} 1`,
+		`requests_total{route="/user/:id"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "method")
+	r.MustRegister(h)
+	h.Observe(0.05, "GET")
+	h.Observe(0.1, "GET")
+	h.Observe(0.5, "GET")
+	h.Observe(3, "GET")
+
+	out := writeText(t, r)
+	for _, want := range []string{
+		`latency_seconds_bucket{method="GET",le="0.1"} 2`,
+		`latency_seconds_bucket{method="GET",le="1"} 3`,
+		`latency_seconds_bucket{method="GET",le="+Inf"} 4`,
+		`latency_seconds_sum{method="GET"} 3.65`,
+		`latency_seconds_count{method="GET"} 4`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+type fakePool struct{ running, free int }
+
+func (p *fakePool) Running() int { return p.running }
+func (p *fakePool) Free() int    { return p.free }
+
+func TestRegisterPool(t *testing.T) {
+	r := NewRegistry()
+	p := &fakePool{running: 2, free: 8}
+	r.RegisterPool("default", p)
+	r.RegisterPool("upload", &fakePool{free: 1})
+	p.running = 3
+
+	out := writeText(t, r)
+	for _, want := range []string{
+		`msgo_pool_running_workers{pool="default"} 3`,
+		`msgo_pool_free_workers{pool="default"} 8`,
+		`msgo_pool_free_workers{pool="upload"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestDuplicateRegister(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(NewCounterVec("x_total", "X."))
+	if err := r.Register(NewGaugeVec("x_total", "X.")); err == nil {
+		t.Fatal("expected duplicate error")
+	}
+}
@@ -0,0 +1,35 @@
+package msgo
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/metrics"
+)
+
+func TestMetricsWithConfigSharesRegistry(t *testing.T) {
+	registry := metrics.NewRegistry()
+	e := newTestEngine()
+	g := e.Group("api")
+	//同一注册表多次创建中间件不能因重复注册而 panic
+	g.Get("/users/:id", func(ctx *Context) { _ = ctx.String(http.StatusOK, "user") }, MetricsWithConfig(MetricsConfig{Registry: registry}))
+	g.Get("/orders", func(ctx *Context) { _ = ctx.String(http.StatusNotFound, "none") }, MetricsWithConfig(MetricsConfig{Registry: registry}))
+	g.Get("/metrics", MetricsHandler(registry))
+	g.Get("/ping", func(ctx *Context) { _ = ctx.String(http.StatusOK, "pong") }, Metrics, MetricsWithConfig(MetricsConfig{}))
+
+	serve(e, http.MethodGet, "/api/users/1", nil)
+	serve(e, http.MethodGet, "/api/users/2", nil)
+	serve(e, http.MethodGet, "/api/orders", nil)
+	serve(e, http.MethodGet, "/api/ping", nil)
+	body := serve(e, http.MethodGet, "/api/metrics", nil).Body.String()
+	for _, want := range []string{
+		`msgo_http_requests_total{method="GET",route="/api/users/:id",status="200"} 2`,
+		`msgo_http_requests_total{method="GET",route="/api/orders",status="404"} 1`,
+		`msgo_http_requests_in_flight{method="GET",route="/api/orders"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output is missing %s\n%s", want, body)
+		}
+	}
+}
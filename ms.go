@@ -1,13 +1,22 @@
 package msgo
 
 import (
+	"errors"
 	"github.com/liyuanwu2020/msgo/config"
+	"github.com/liyuanwu2020/msgo/health"
+	"github.com/liyuanwu2020/msgo/internal/clientip"
 	"github.com/liyuanwu2020/msgo/mslog"
 	"github.com/liyuanwu2020/msgo/render"
+	"github.com/liyuanwu2020/msgo/securecookie"
+	"github.com/liyuanwu2020/msgo/sessions"
 	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 const ANY = "ANY"
@@ -113,6 +122,16 @@ func (r *router) Group(name string) *routerGroup {
 
 type ErrorHandler func(err error) (int, any)
 
+// ErrorFormat 未注册错误处理器时业务错误的输出格式
+type ErrorFormat int
+
+const (
+	//ErrorFormatProblem RFC 7807 application/problem+json
+	ErrorFormatProblem ErrorFormat = iota
+	//ErrorFormatEnvelope {"code":40400,"msg":"not found","details":{}}
+	ErrorFormatEnvelope
+)
+
 type Engine struct {
 	router
 	funcMap      template.FuncMap
@@ -121,6 +140,33 @@ type Engine struct {
 	Logger       *mslog.Logger
 	middlewares  []MiddlewareFunc
 	errorHandler ErrorHandler
+	//ErrorFormat 未注册错误处理器时 HandleWithError 的输出格式
+	ErrorFormat ErrorFormat
+	//DevMode 开发模式, 模板热加载并输出错误调试页面
+	DevMode      bool
+	templateSets map[string]*render.TemplateSet
+	//htmlLoader LoadTemplate 的解析函数, 开发模式下用于重新加载
+	htmlLoader    func() (*template.Template, error)
+	templateCache sync.Map
+	//requestTemplatesOf 按模板 key 缓存注入请求级模板函数的副本
+	requestTemplatesOf map[string]*requestTemplates
+	requestTemplatesMu sync.Mutex
+	//SessionStore 会话存储, 为空时 ctx.Session() 返回 nil
+	SessionStore    sessions.Store
+	SessionName     string
+	signedCookie    *securecookie.Codec
+	encryptedCookie *securecookie.Codec
+	//Upload 全局上传限制, 路由可以使用 UploadLimit 覆盖
+	Upload UploadConfig
+	//BodyLimit 全局请求体大小和读取速率限制, 路由可以使用 BodyLimit 中间件覆盖
+	BodyLimit BodyLimitConfig
+	//ShutdownDelay 优雅关闭时就绪检查失败后等待的时间
+	ShutdownDelay  time.Duration
+	health         *health.Registry
+	healthHandlers map[string]http.Handler
+	server         *http.Server
+	serverMu       sync.Mutex
+	trustedProxies clientip.TrustedProxies
 }
 
 func New() *Engine {
@@ -141,6 +187,10 @@ func Default() *Engine {
 		engine.Logger.SetLogPath(logPath.(string))
 	}
 	engine.Use(Logging, Recovery)
+	engine.BodyLimit = BodyLimitConfigFromToml(config.Conf.Server)
+	if config.Conf.Cors.Enable {
+		engine.Use(Cors(CorsConfigFromToml(config.Conf.Cors)))
+	}
 	engine.router.engine = engine
 	return engine
 }
@@ -153,28 +203,162 @@ func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
 	e.funcMap = funcMap
 }
 
+// SetHTMLRender 直接设置的模板在开发模式下不会重新加载
 func (e *Engine) SetHTMLRender(render render.HTMLRender) {
 	e.HTMLRender = render
+	e.htmlLoader = nil
 }
 
+// LoadTemplate 加载模板, 开发模式下每次渲染重新解析
 func (e *Engine) LoadTemplate(pattern string) {
-	t := template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern))
+	e.loadHTMLTemplate(func() (*template.Template, error) {
+		return template.New("").Funcs(e.templateFuncMap()).ParseGlob(pattern)
+	})
+}
+
+func (e *Engine) loadHTMLTemplate(loader func() (*template.Template, error)) {
+	t := template.Must(loader())
 	e.SetHTMLRender(render.HTMLRender{Template: t})
+	e.htmlLoader = loader
+}
+
+// templateFuncMap 内置的请求级模板函数占位, 渲染时替换为当前请求的值
+func (e *Engine) templateFuncMap() template.FuncMap {
+	return e.mergeFuncMap(nil)
+}
+
+func (e *Engine) mergeFuncMap(funcMap template.FuncMap) template.FuncMap {
+	merged := template.FuncMap{
+		"csrfToken": func() string { return "" },
+		"csrfField": func() template.HTML { return "" },
+		"cspNonce":  func() string { return "" },
+	}
+	if funcMap == nil {
+		funcMap = e.funcMap
+	}
+	for name, fn := range funcMap {
+		merged[name] = fn
+	}
+	return merged
+}
+
+// requestTemplates 返回 key 对应模板的请求级副本缓存, 模板重新加载后替换旧的缓存
+func (e *Engine) requestTemplates(key string, t *template.Template) (*requestTemplates, error) {
+	e.requestTemplatesMu.Lock()
+	if e.requestTemplatesOf == nil {
+		e.requestTemplatesOf = make(map[string]*requestTemplates)
+	}
+	rts, ok := e.requestTemplatesOf[key]
+	if !ok || rts.src != t {
+		rts = &requestTemplates{src: t}
+		e.requestTemplatesOf[key] = rts
+	}
+	e.requestTemplatesMu.Unlock()
+	//html/template 执行后不能再 Clone, 必须在模板首次执行前保留未执行的副本
+	rts.once.Do(func() {
+		rts.pristine, rts.err = t.Clone()
+	})
+	return rts, rts.err
+}
+
+// requestTemplates 同一模板注入了请求级函数的副本, 副本复用, 执行时绑定当前请求
+type requestTemplates struct {
+	src      *template.Template
+	once     sync.Once
+	pristine *template.Template
+	err      error
+	pool     sync.Pool
+}
+
+type requestTemplate struct {
+	t   *template.Template
+	ctx *Context
+}
+
+func (rts *requestTemplates) get(c *Context) (*requestTemplate, error) {
+	if v := rts.pool.Get(); v != nil {
+		rt := v.(*requestTemplate)
+		rt.ctx = c
+		return rt, nil
+	}
+	t, err := rts.pristine.Clone()
+	if err != nil {
+		return nil, err
+	}
+	rt := &requestTemplate{ctx: c}
+	rt.t = t.Funcs(template.FuncMap{
+		"csrfToken": func() string { return rt.ctx.CSRFToken() },
+		"csrfField": func() template.HTML { return rt.ctx.CSRFField() },
+		"cspNonce":  func() string { return rt.ctx.CSPNonce() },
+	})
+	return rt, nil
+}
+
+func (rts *requestTemplates) put(rt *requestTemplate) {
+	rt.ctx = nil
+	rts.pool.Put(rt)
+}
+
+// LoadTemplateFS 从文件系统(如 embed.FS)加载模板
+func (e *Engine) LoadTemplateFS(fsys fs.FS, patterns ...string) {
+	e.loadHTMLTemplate(func() (*template.Template, error) {
+		return template.New("").Funcs(e.templateFuncMap()).ParseFS(fsys, patterns...)
+	})
+}
+
+// SetCookieKeys 配置签名与加密 cookie 的密钥, 第一个密钥用于签名和加密, 所有密钥都可用于校验, 便于密钥轮换
+func (e *Engine) SetCookieKeys(hashKeys, blockKeys [][]byte) {
+	if len(hashKeys) > 0 {
+		e.signedCookie = securecookie.New(hashKeys, nil)
+	}
+	if len(blockKeys) > 0 {
+		e.encryptedCookie = securecookie.New(nil, blockKeys)
+	}
+}
+
+func (e *Engine) SignedCookieCodec() *securecookie.Codec {
+	return e.signedCookie
+}
+
+func (e *Engine) EncryptedCookieCodec() *securecookie.Codec {
+	return e.encryptedCookie
+}
+
+// AddTemplateSet 注册命名模板集合, 开发模式下自动开启热加载
+func (e *Engine) AddTemplateSet(set *render.TemplateSet) error {
+	set.FuncMap = e.mergeFuncMap(set.FuncMap)
+	if e.DevMode {
+		set.HotReload = true
+	}
+	if err := set.Load(); err != nil {
+		return err
+	}
+	if e.templateSets == nil {
+		e.templateSets = make(map[string]*render.TemplateSet)
+	}
+	e.templateSets[set.Name] = set
+	return nil
 }
 
 // 实现 http.server 的 Handler 接口
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if e.healthHandlers != nil && e.serveHealth(w, r) {
+		return
+	}
 	ctx := e.pool.Get().(*Context)
-	ctx.W = w
-	ctx.R = r
+	ctx.reset(w, r)
 	ctx.Logger = e.Logger
-	e.httpRequestHandle(ctx)
+	if ctx.limitRequestBody(&e.BodyLimit, false) {
+		e.httpRequestHandle(ctx)
+	}
 	e.pool.Put(ctx)
 }
 
 func (e *Engine) RunTLS(addr, certFile, keyFile string) {
-	err := http.ListenAndServeTLS(addr, certFile, keyFile, e.Handler())
-	if err != nil {
+	srv := &http.Server{Addr: addr, Handler: e.Handler()}
+	e.setServer(srv)
+	err := srv.ListenAndServeTLS(certFile, keyFile)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal("ListenAndServeTLS err", err)
 	}
 }
@@ -182,8 +366,10 @@ func (e *Engine) RunTLS(addr, certFile, keyFile string) {
 func (e *Engine) Run(addr string) {
 
 	http.Handle("/", e)
-	err := http.ListenAndServe(addr, nil)
-	if err != nil {
+	srv := &http.Server{Addr: addr}
+	e.setServer(srv)
+	err := srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal("启动失败", err)
 	}
 }
@@ -200,6 +386,7 @@ func (e *Engine) httpRequestHandle(ctx *Context) {
 			//mslog.Printf("handlerFuncMap [%s] match [%s] %v", routerName, node.routerName, ok)
 			if ok {
 				ctx.NodeRouterName = node.routerName
+				ctx.groupName = group.name
 				if handle, ok := handlerFunc[method]; ok {
 					ctx.RequestMethod = method
 					group.methodHandler(handle, ctx)
@@ -211,6 +398,12 @@ func (e *Engine) httpRequestHandle(ctx *Context) {
 					group.methodHandler(handle, ctx)
 					return
 				}
+				//未注册 OPTIONS 时自动响应, 中间件(如 Cors)可以处理预检请求
+				if method == http.MethodOptions {
+					ctx.RequestMethod = method
+					group.methodHandler(optionsHandler(handlerFunc), ctx)
+					return
+				}
 				ctx.W.WriteHeader(http.StatusMethodNotAllowed)
 				log.Printf("%s %s not allowed", ctx.R.RequestURI, method)
 				return
@@ -221,6 +414,21 @@ func (e *Engine) httpRequestHandle(ctx *Context) {
 	log.Printf("%s %s not found", ctx.R.RequestURI, method)
 }
 
+// optionsHandler 默认的 OPTIONS 处理, 返回路由支持的方法
+func optionsHandler(handlerFunc map[string]HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		//注册了 ANY 的路由由 ANY 处理 OPTIONS, 不会进入这里
+		methods := make([]string, 0, len(handlerFunc)+1)
+		for m := range handlerFunc {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		ctx.W.Header().Set("Allow", strings.Join(append(methods, http.MethodOptions), ", "))
+		ctx.StatusCode = http.StatusNoContent
+		ctx.W.WriteHeader(http.StatusNoContent)
+	}
+}
+
 func (e *Engine) Use(middlewareFunc ...MiddlewareFunc) {
 	e.middlewares = append(e.middlewares, middlewareFunc...)
 }
@@ -0,0 +1,24 @@
+package msgo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+// newTestEngine 不加载配置和默认中间件的引擎
+func newTestEngine() *Engine {
+	e := New()
+	e.router.engine = e
+	return e
+}
+
+func serve(h http.Handler, method, target string, body io.Reader, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
@@ -0,0 +1,148 @@
+package mserror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Error 业务错误, 携带业务码、HTTP 状态码、面向用户的提示、详情和原始错误
+type Error struct {
+	Code    int
+	Status  int
+	Message string
+	Details map[string]any
+	cause   error
+}
+
+// New 创建业务错误, 不加入错误码注册表
+func New(code, status int, message string) *Error {
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%d %s: %v", e.Code, e.Message, e.cause)
+	}
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 业务码相同即视为同一错误, 支持 errors.Is(err, mserror.ErrNotFound)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	if e.Details != nil {
+		c.Details = make(map[string]any, len(e.Details))
+		for k, v := range e.Details {
+			c.Details[k] = v
+		}
+	}
+	return &c
+}
+
+// WithCause 返回携带原始错误的副本, 原始错误不会输出给用户
+func (e *Error) WithCause(err error) *Error {
+	c := e.clone()
+	c.cause = err
+	return c
+}
+
+// WithMessage 返回替换提示信息的副本
+func (e *Error) WithMessage(format string, args ...any) *Error {
+	c := e.clone()
+	c.Message = fmt.Sprintf(format, args...)
+	return c
+}
+
+// WithDetail 返回追加详情的副本
+func (e *Error) WithDetail(key string, value any) *Error {
+	c := e.clone()
+	if c.Details == nil {
+		c.Details = make(map[string]any)
+	}
+	c.Details[key] = value
+	return c
+}
+
+// As 从错误链中取出业务错误
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// Wrap 把任意错误转换为业务错误, 非业务错误使用 ErrInternal 包装
+func Wrap(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := As(err); ok {
+		return e
+	}
+	return ErrInternal.WithCause(err)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[int]*Error)
+)
+
+// Register 注册错误码, 重复注册同一业务码会 panic
+func Register(code, status int, message string) *Error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[code]; ok {
+		panic(fmt.Sprintf("mserror: code %d already registered", code))
+	}
+	e := New(code, status, message)
+	registry[code] = e
+	return e
+}
+
+// Lookup 根据业务码查找已注册的错误
+func Lookup(code int) (*Error, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	e, ok := registry[code]
+	return e, ok
+}
+
+// Codes 按业务码排序返回全部已注册的错误, 可用于生成错误码文档
+func Codes() []*Error {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	codes := make([]*Error, 0, len(registry))
+	for _, e := range registry {
+		codes = append(codes, e)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+// 通用错误码
+var (
+	ErrBadRequest      = Register(40000, http.StatusBadRequest, "bad request")
+	ErrUnauthorized    = Register(40100, http.StatusUnauthorized, "unauthorized")
+	ErrForbidden       = Register(40300, http.StatusForbidden, "forbidden")
+	ErrNotFound        = Register(40400, http.StatusNotFound, "not found")
+	ErrConflict        = Register(40900, http.StatusConflict, "conflict")
+	ErrTooManyRequests = Register(42900, http.StatusTooManyRequests, "too many requests")
+	ErrInternal        = Register(50000, http.StatusInternalServerError, "internal server error")
+)
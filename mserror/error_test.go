@@ -0,0 +1,78 @@
+package mserror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorIsAndAs(t *testing.T) {
+	cause := errors.New("sql: no rows in result set")
+	err := fmt.Errorf("load user: %w", ErrNotFound.WithCause(cause).WithMessage("user %d not found", 7))
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("errors.Is must match by code")
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Fatal("different codes must not match")
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("cause must be reachable through Unwrap")
+	}
+	e, ok := As(err)
+	if !ok || e.Status != http.StatusNotFound || e.Message != "user 7 not found" {
+		t.Fatalf("unexpected error %+v", e)
+	}
+	if ErrNotFound.Message != "not found" || ErrNotFound.Unwrap() != nil {
+		t.Fatal("With* must not modify the registered error")
+	}
+}
+
+func TestWrapInternal(t *testing.T) {
+	e := Wrap(errors.New("dial tcp: connection refused"))
+	if e.Code != ErrInternal.Code || e.Status != http.StatusInternalServerError {
+		t.Fatalf("unexpected error %+v", e)
+	}
+	if Wrap(nil) != nil {
+		t.Fatal("Wrap(nil) must return nil")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	e := Register(42201, http.StatusUnprocessableEntity, "order state invalid")
+	if got, ok := Lookup(42201); !ok || got != e {
+		t.Fatal("registered error not found")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate code must panic")
+		}
+	}()
+	Register(42201, http.StatusBadRequest, "duplicate")
+}
+
+func TestProblemJSON(t *testing.T) {
+	err := ErrBadRequest.WithMessage("name is required").WithDetail("field", "name")
+	data, _ := json.Marshal(NewProblem(err, "/users"))
+	var m map[string]any
+	_ = json.Unmarshal(data, &m)
+	if m["type"] != "about:blank" || m["title"] != "Bad Request" || m["status"] != float64(400) ||
+		m["detail"] != "name is required" || m["instance"] != "/users" || m["code"] != float64(40000) || m["field"] != "name" {
+		t.Fatalf("unexpected problem %s", data)
+	}
+
+	data, _ = json.Marshal(NewProblem(errors.New("secret dsn"), ""))
+	_ = json.Unmarshal(data, &m)
+	if m["detail"] != ErrInternal.Message {
+		t.Fatalf("internal error must not leak cause: %s", data)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	data, _ := json.Marshal(NewEnvelope(ErrForbidden))
+	if string(data) != `{"code":40300,"msg":"forbidden"}` {
+		t.Fatalf("unexpected envelope %s", data)
+	}
+}
@@ -8,6 +8,10 @@ type MsError struct {
 func (e *MsError) Error() string {
 	return e.err.Error()
 }
+
+func (e *MsError) Unwrap() error {
+	return e.err
+}
 func Default() *MsError {
 	return &MsError{}
 }
@@ -29,6 +33,13 @@ func (e *MsError) Result(fuc ErrorFuc) {
 	e.errorFuc = fuc
 }
 
+// HasResult 是否设置了错误处理函数
+func (e *MsError) HasResult() bool {
+	return e.errorFuc != nil
+}
+
 func (e *MsError) ExecResult() {
-	e.errorFuc(e)
+	if e.errorFuc != nil {
+		e.errorFuc(e)
+	}
 }
@@ -0,0 +1,72 @@
+package mserror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// ProblemContentType RFC 7807 响应类型
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase 非空时 type 为 ProblemTypeBase 加业务码, 如 https://errors.example.com/40400, 否则为 about:blank
+var ProblemTypeBase = ""
+
+// Problem RFC 7807 问题详情, 业务码和错误详情作为扩展字段输出
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Code       int
+	Extensions map[string]any
+}
+
+// NewProblem 把错误转换为问题详情, 非业务错误按 ErrInternal 输出, 不暴露原始错误
+func NewProblem(err error, instance string) *Problem {
+	e := Wrap(err)
+	p := &Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(e.Status),
+		Status:     e.Status,
+		Detail:     e.Message,
+		Instance:   instance,
+		Code:       e.Code,
+		Extensions: e.Details,
+	}
+	if ProblemTypeBase != "" {
+		p.Type = ProblemTypeBase + strconv.Itoa(e.Code)
+	}
+	return p
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+6)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	m["code"] = p.Code
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// Envelope 项目统一的错误响应格式
+type Envelope struct {
+	Code    int            `json:"code"`
+	Msg     string         `json:"msg"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func NewEnvelope(err error) *Envelope {
+	e := Wrap(err)
+	return &Envelope{Code: e.Code, Msg: e.Message, Details: e.Details}
+}
@@ -98,12 +98,22 @@ func (l *Logger) Debug(msg any) {
 	l.Print(LevelDebug, msg)
 }
 
+// WithFields 返回携带字段的新 Logger, 与已有字段合并
 func (l *Logger) WithFields(fields Fields) *Logger {
+	merged := make(Fields, len(l.LoggerFields)+len(fields))
+	for k, v := range l.LoggerFields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
 	return &Logger{
 		Formatter:    l.Formatter,
 		Outs:         l.Outs,
 		Level:        l.Level,
-		LoggerFields: fields,
+		LoggerFields: merged,
+		LogPath:      l.LogPath,
+		LogFilesize:  l.LogFilesize,
 	}
 }
 
@@ -1,16 +1,42 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/liyuanwu2020/msgo/metrics"
 	"github.com/liyuanwu2020/msgo/mslog"
+	"github.com/liyuanwu2020/msgo/tracing"
 	"log"
 	"reflect"
 	"strings"
 	"time"
 )
 
+var queryDuration = metrics.NewHistogramVec("msgo_orm_query_duration_seconds",
+	"Duration of orm queries in seconds.", metrics.DefBuckets, "operation", "table")
+
+func init() {
+	metrics.MustRegister(queryDuration)
+}
+
+// startQuery 为语句创建 span, 返回的函数结束 span 并记录耗时
+func (s *MsSession) startQuery(operation string) func() {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracing.Start(ctx, "orm "+operation, tracing.SpanKindClient)
+	span.SetAttribute("db.operation", operation)
+	span.SetAttribute("db.table", s.TableName)
+	start := time.Now()
+	return func() {
+		span.End()
+		queryDuration.Observe(time.Since(start).Seconds(), operation, s.TableName)
+	}
+}
+
 type MsDb struct {
 	db     *sql.DB
 	logger *mslog.Logger
@@ -28,6 +54,13 @@ type MsSession struct {
 	updateValues []any
 	beginTx      bool
 	tx           *sql.Tx
+	ctx          context.Context
+}
+
+// WithContext 设置链路上下文, 语句的 span 作为 ctx 中 span 的子节点
+func (s *MsSession) WithContext(ctx context.Context) *MsSession {
+	s.ctx = ctx
+	return s
 }
 
 func (s *MsSession) Table(name string) *MsSession {
@@ -35,6 +68,7 @@ func (s *MsSession) Table(name string) *MsSession {
 	return s
 }
 func (s *MsSession) Count() (int64, error) {
+	defer s.startQuery("count")()
 	query := fmt.Sprintf("select count(*) from %s ", s.TableName)
 	var sb strings.Builder
 	sb.WriteString(query)
@@ -44,8 +78,9 @@ func (s *MsSession) Count() (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	defer stmt.Close()
 	var result int64
-	row := stmt.QueryRow()
+	row := stmt.QueryRow(s.values...)
 	err = row.Err()
 	if err != nil {
 		return 0, err
@@ -82,6 +117,7 @@ func (s *MsSession) Or(field string, data any) *MsSession {
 }
 
 func (s *MsSession) QueryRow(sql string, data any, queryValues ...any) error {
+	defer s.startQuery("query_row")()
 	t := reflect.TypeOf(data)
 	stmt, err := s.db.db.Prepare(sql)
 	if err != nil {
@@ -134,11 +170,13 @@ func (s *MsSession) QueryRow(sql string, data any, queryValues ...any) error {
 }
 
 func (s *MsSession) Exec(sql string, values ...any) (int64, error) {
+	defer s.startQuery("exec")()
 	stmt, err := s.db.db.Prepare(sql)
 	if err != nil {
 		return 0, err
 	}
-	r, err := stmt.Exec(values)
+	defer stmt.Close()
+	r, err := stmt.Exec(values...)
 	if err != nil {
 		return 0, err
 	}
@@ -149,6 +187,7 @@ func (s *MsSession) Exec(sql string, values ...any) (int64, error) {
 }
 
 func (s *MsSession) Update(data ...any) (int64, error) {
+	defer s.startQuery("update")()
 	size := len(data)
 	if size <= 0 || size > 2 {
 		return -1, errors.New("params error")
@@ -217,6 +256,7 @@ func (s *MsSession) Update(data ...any) (int64, error) {
 }
 
 func (s *MsSession) Insert(data any) (int64, int64, error) {
+	defer s.startQuery("insert")()
 	s.fieldNames(data)
 	query := fmt.Sprintf("insert into %s (%s) values(%s)", s.TableName, strings.Join(s.fieldName, ","), strings.Join(s.placeHolder, ","))
 	stmt, err := s.db.db.Prepare(query)
@@ -288,6 +328,7 @@ func (s *MsSession) fieldNames(data any) {
 }
 
 func (s *MsSession) InsertBath(data []any) (int64, int64, error) {
+	defer s.startQuery("insert_batch")()
 	if len(data) == 0 {
 		panic(errors.New("data type must be slice and not empty"))
 	}
@@ -371,6 +412,7 @@ func (s *MsSession) batchFieldNames(data []any) {
 }
 
 func (s *MsSession) Select(data any, fields ...string) ([]any, error) {
+	defer s.startQuery("select")()
 	var fieldStr = "*"
 	if len(fields) > 0 {
 		fieldStr = strings.Join(fields, ",")
@@ -447,6 +489,7 @@ func (s *MsSession) Select(data any, fields ...string) ([]any, error) {
 }
 
 func (s *MsSession) Delete() error {
+	defer s.startQuery("delete")()
 	query := fmt.Sprintf("delete from %s ", s.TableName)
 	var sb strings.Builder
 	sb.WriteString(query)
@@ -512,6 +555,11 @@ func isAutoId(id any) bool {
 	return false
 }
 
+// Ping 检查数据库连通性, 用于健康检查
+func (d *MsDb) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
 func (d *MsDb) TablePrefix(prefix string) *MsDb {
 	d.Prefix = prefix
 	return d
@@ -530,7 +578,8 @@ func Open(driverName string, source string) (*MsDb, error) {
 	}
 	db.SetMaxOpenConns(1)
 	msDb := &MsDb{
-		db: db,
+		db:     db,
+		logger: mslog.Default(),
 	}
 	//最大空闲连接数，默认不配置，是2个最大空闲连接
 	db.SetMaxIdleConns(5)
@@ -1,10 +1,81 @@
 package orm
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"io"
 	"testing"
 )
 
 func TestName(t *testing.T) {
 	fmt.Println(Name("UserName"))
 }
+
+// recordDriver 记录执行的语句和参数, 查询固定返回一行 count
+type recordDriver struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordDriver) Open(name string) (driver.Conn, error) { return &recordConn{d: d}, nil }
+
+type recordConn struct{ d *recordDriver }
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{d: c.d, query: query}, nil
+}
+func (c *recordConn) Close() error              { return nil }
+func (c *recordConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type recordStmt struct {
+	d     *recordDriver
+	query string
+}
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return -1 }
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.query, s.d.args = s.query, args
+	return driver.RowsAffected(1), nil
+}
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.query, s.d.args = s.query, args
+	return &countRows{}, nil
+}
+
+type countRows struct{ done bool }
+
+func (r *countRows) Columns() []string { return []string{"count"} }
+func (r *countRows) Close() error      { return nil }
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(3)
+	return nil
+}
+
+func TestCountAndExecArgs(t *testing.T) {
+	d := &recordDriver{}
+	sql.Register("orm_record", d)
+	db, err := Open("orm_record", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := db.New().Table("user").Where("id", 7).Where("name", "bob").Count()
+	if err != nil || n != 3 {
+		t.Fatalf("Count = %d, %v", n, err)
+	}
+	if d.query != "select count(*) from user  where id = ? and name = ?" || len(d.args) != 2 || d.args[0] != int64(7) || d.args[1] != "bob" {
+		t.Fatalf("Count must bind where values, got %q %v", d.query, d.args)
+	}
+	n, err = db.New().Exec("update user set name = ? where id = ?", "alice", 7)
+	if err != nil || n != 1 {
+		t.Fatalf("Exec = %d, %v", n, err)
+	}
+	if len(d.args) != 2 || d.args[0] != "alice" || d.args[1] != int64(7) {
+		t.Fatalf("Exec must pass each value as an argument, got %v", d.args)
+	}
+}
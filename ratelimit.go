@@ -0,0 +1,81 @@
+package msgo
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/liyuanwu2020/msgo/ratelimit"
+)
+
+// LimiterConfig 限流中间件配置
+type LimiterConfig = ratelimit.Config[*Context]
+
+// Limiter 使用默认配置的限流中间件, 默认限流器在首次使用时创建, 未使用时不启动内存存储的清理协程
+func Limiter(next HandlerFunc) HandlerFunc {
+	defaultLimiterOnce.Do(func() {
+		defaultLimiter = LimiterWithConfig(LimiterConfig{})
+	})
+	return defaultLimiter(next)
+}
+
+var (
+	defaultLimiterOnce sync.Once
+	defaultLimiter     MiddlewareFunc
+)
+
+func LimiterWithConfig(conf LimiterConfig) MiddlewareFunc {
+	conf.SetDefaults(KeyByClientIP)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			result, err := conf.Allow(ctx, ctx.RoutePattern())
+			if err != nil {
+				//存储不可用时放行, 避免限流组件故障导致服务不可用
+				ctx.Logger.Error(err)
+				next(ctx)
+				return
+			}
+			result.WriteHeaders(ctx.W.Header())
+			if !result.Allowed {
+				if conf.LimitHandler != nil {
+					conf.LimitHandler(ctx, result)
+				} else {
+					ctx.Fail(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+				}
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// KeyByClientIP 按客户端 IP 限流
+func KeyByClientIP(ctx *Context) string {
+	return ratelimit.KeyIP(ctx.ClientIP().String())
+}
+
+// KeyByRoute 按路由模板限流
+func KeyByRoute(ctx *Context) string {
+	return ratelimit.KeyRoute(ctx.RequestMethod, ctx.RoutePattern())
+}
+
+// KeyByHeader 按请求头限流, 如 API Key, 请求头不存在时按客户端 IP
+func KeyByHeader(name string) func(ctx *Context) string {
+	return func(ctx *Context) string {
+		if v := ctx.R.Header.Get(name); v != "" {
+			return ratelimit.KeyHeader(v)
+		}
+		return KeyByClientIP(ctx)
+	}
+}
+
+// KeyByClaim 按 JWT claims 中的字段限流, 如 sub, 未认证时按客户端 IP
+func KeyByClaim(name string) func(ctx *Context) string {
+	return func(ctx *Context) string {
+		if claims, ok := ctx.Get("claims"); ok {
+			if key, ok := ratelimit.KeyClaim(claims, name); ok {
+				return key
+			}
+		}
+		return KeyByClientIP(ctx)
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"github.com/liyuanwu2020/msgo/internal/mapclaims"
+	"github.com/liyuanwu2020/msgo/internal/msstrings"
+)
+
+// Config 限流中间件配置, C 为框架的请求上下文类型
+type Config[C any] struct {
+	//Limiter 默认限流器, 为空时每个中间件使用独立的每秒 10 次, 突发 20 次的令牌桶
+	Limiter Limiter
+	//KeyFunc 限流维度, 默认按客户端 IP
+	KeyFunc func(ctx C) string
+	//Routes 按包含分组名的完整路由模板覆盖限流器, 如 /api/login
+	Routes map[string]Limiter
+	//LimitHandler 超出限制时的处理, 默认返回 429
+	LimitHandler func(ctx C, result Result)
+}
+
+// SetDefaults 填充默认限流器和限流维度, 创建中间件时调用一次
+func (c *Config[C]) SetDefaults(keyFunc func(ctx C) string) {
+	if c.Limiter == nil {
+		c.Limiter = NewTokenBucket(10, 20, NewMemoryStore())
+	}
+	if c.KeyFunc == nil {
+		c.KeyFunc = keyFunc
+	}
+}
+
+// Allow 按完整路由模板选择限流器, 路由限流器的 key 带路由前缀, 与默认限流器及其他路由的计数互不影响
+func (c *Config[C]) Allow(ctx C, route string) (Result, error) {
+	limiter := c.Limiter
+	key := c.KeyFunc(ctx)
+	if l, ok := c.Routes[route]; ok {
+		key = route + ":" + key
+		if l != nil {
+			limiter = l
+		}
+	}
+	return limiter.Allow(key)
+}
+
+func KeyIP(ip string) string {
+	return "ip:" + ip
+}
+
+func KeyRoute(method, route string) string {
+	return "route:" + method + " " + route
+}
+
+func KeyHeader(value string) string {
+	return "header:" + value
+}
+
+// KeyClaim 按 claims 中的字段生成 key, 字段不存在时返回 false
+func KeyClaim(claims any, name string) (string, bool) {
+	v := mapclaims.Get(claims, name)
+	if v == nil {
+		return "", false
+	}
+	return msstrings.JoinStrings("claim:", v), true
+}
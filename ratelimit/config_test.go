@@ -0,0 +1,51 @@
+package ratelimit
+
+import "testing"
+
+func TestConfigAllow(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+	route := NewTokenBucket(1, 1, store)
+	conf := Config[string]{Routes: map[string]Limiter{"/login": route, "/nil": nil}}
+	conf.SetDefaults(func(ip string) string { return KeyIP(ip) })
+	if _, ok := conf.Limiter.(*TokenBucket); !ok {
+		t.Fatalf("default limiter = %T", conf.Limiter)
+	}
+	if r, _ := conf.Allow("1.2.3.4", "/login"); !r.Allowed {
+		t.Fatal("first login request should be allowed")
+	}
+	if r, _ := conf.Allow("1.2.3.4", "/login"); r.Allowed {
+		t.Fatal("route limiter must apply to /login")
+	}
+	if r, _ := conf.Allow("1.2.3.4", "/other"); !r.Allowed || r.Limit != 20 {
+		t.Fatalf("default limiter must apply to other routes, got %+v", r)
+	}
+	if r, _ := conf.Allow("1.2.3.4", "/nil"); !r.Allowed || r.Limit != 20 {
+		t.Fatalf("nil route limiter must fall back to the default, got %+v", r)
+	}
+	other := Config[string]{}
+	other.SetDefaults(KeyIP)
+	if other.Limiter == conf.Limiter {
+		t.Fatal("each config must get its own default limiter")
+	}
+}
+
+func TestKeyClaim(t *testing.T) {
+	type claims map[string]any
+	cases := []struct {
+		claims any
+		want   string
+		ok     bool
+	}{
+		{map[string]any{"sub": "u1"}, "claim:u1", true},
+		{claims{"sub": 42}, "claim:42", true},
+		{map[string]any{"sub": nil}, "", false},
+		{map[string]any{}, "", false},
+		{"sub", "", false},
+	}
+	for _, c := range cases {
+		if got, ok := KeyClaim(c.claims, "sub"); got != c.want || ok != c.ok {
+			t.Errorf("KeyClaim(%v) = %q, %v, want %q, %v", c.claims, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Result 一次限流判断的结果
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	//ResetAfter 配额完全恢复需要的时间
+	ResetAfter time.Duration
+	//RetryAfter 被拒绝时距离下次允许的时间
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(key string) (Result, error)
+}
+
+// State 限流计数状态, 令牌桶使用 Tokens/Last, 滑动窗口使用 Prev/Curr/Window
+type State struct {
+	Tokens float64
+	Last   time.Time
+	Prev   int64
+	Curr   int64
+	Window time.Time
+}
+
+// Store 计数存储, 同一个 key 的 Update 必须是原子的, 便于后续实现共享存储
+type Store interface {
+	Update(key string, ttl time.Duration, fn func(state *State, exists bool)) (State, error)
+}
+
+// TokenBucket 令牌桶, 每秒补充 Rate 个令牌, 最多 Burst 个
+type TokenBucket struct {
+	Rate    float64
+	Burst   int
+	Store   Store
+	TimeFun func() time.Time
+}
+
+func NewTokenBucket(rate float64, burst int, store Store) *TokenBucket {
+	return &TokenBucket{Rate: rate, Burst: burst, Store: store}
+}
+
+func (b *TokenBucket) Allow(key string) (Result, error) {
+	now := now(b.TimeFun)
+	burst := float64(b.Burst)
+	allowed := false
+	ttl := time.Duration(burst / b.Rate * float64(time.Second))
+	state, err := b.Store.Update(key, ttl, func(state *State, exists bool) {
+		if !exists {
+			state.Tokens = burst
+		} else {
+			elapsed := now.Sub(state.Last).Seconds()
+			state.Tokens = math.Min(burst, state.Tokens+elapsed*b.Rate)
+		}
+		state.Last = now
+		if state.Tokens >= 1 {
+			state.Tokens--
+			allowed = true
+		}
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{
+		Allowed:    allowed,
+		Limit:      b.Burst,
+		Remaining:  int(state.Tokens),
+		ResetAfter: seconds((burst - state.Tokens) / b.Rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - state.Tokens) / b.Rate)
+	}
+	return result, nil
+}
+
+// SlidingWindow 滑动窗口计数, 按上一个窗口的剩余比例加权估算当前窗口内的请求数
+type SlidingWindow struct {
+	Limit   int
+	Window  time.Duration
+	Store   Store
+	TimeFun func() time.Time
+}
+
+func NewSlidingWindow(limit int, window time.Duration, store Store) *SlidingWindow {
+	return &SlidingWindow{Limit: limit, Window: window, Store: store}
+}
+
+func (s *SlidingWindow) Allow(key string) (Result, error) {
+	now := now(s.TimeFun)
+	start := now.Truncate(s.Window)
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(s.Window)
+	allowed := false
+	var count float64
+	state, err := s.Store.Update(key, 2*s.Window, func(state *State, exists bool) {
+		if !state.Window.Equal(start) {
+			if state.Window.Add(s.Window).Equal(start) {
+				state.Prev = state.Curr
+			} else {
+				state.Prev = 0
+			}
+			state.Curr = 0
+			state.Window = start
+		}
+		count = float64(state.Prev)*weight + float64(state.Curr)
+		if count+1 <= float64(s.Limit) {
+			state.Curr++
+			count++
+			allowed = true
+		}
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	remaining := s.Limit - int(math.Ceil(count))
+	if remaining < 0 {
+		remaining = 0
+	}
+	result := Result{
+		Allowed:    allowed,
+		Limit:      s.Limit,
+		Remaining:  remaining,
+		ResetAfter: s.Window - elapsed,
+	}
+	if !allowed {
+		retry := s.Window - elapsed
+		if state.Curr < int64(s.Limit) && state.Prev > 0 {
+			//上一个窗口的权重衰减到有空余配额所需的时间
+			need := (count + 1 - float64(s.Limit)) / float64(state.Prev)
+			retry = time.Duration(need * float64(s.Window))
+		}
+		result.RetryAfter = retry
+	}
+	return result, nil
+}
+
+func now(f func() time.Time) time.Time {
+	if f != nil {
+		return f()
+	}
+	return time.Now()
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+// MemoryStore 内存计数存储, 过期 key 定时清理
+type MemoryStore struct {
+	lock    sync.Mutex
+	entries map[string]*memoryEntry
+	release chan struct{}
+	once    sync.Once
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+		release: make(chan struct{}),
+	}
+	go s.expireEntries(time.Minute)
+	return s
+}
+
+func (s *MemoryStore) expireEntries(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			s.lock.Lock()
+			for k, e := range s.entries {
+				if now.After(e.expires) {
+					delete(s.entries, k)
+				}
+			}
+			s.lock.Unlock()
+		case <-s.release:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) Update(key string, ttl time.Duration, fn func(state *State, exists bool)) (State, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e, ok := s.entries[key]
+	if ok && time.Now().After(e.expires) {
+		ok = false
+	}
+	if !ok {
+		e = &memoryEntry{}
+		s.entries[key] = e
+	}
+	fn(&e.state, ok)
+	e.expires = time.Now().Add(ttl)
+	return e.state, nil
+}
+
+// Close 停止过期清理
+func (s *MemoryStore) Close() {
+	s.once.Do(func() {
+		close(s.release)
+	})
+}
+
+// WriteHeaders 写入 X-RateLimit-* 响应头, 拒绝时写入 Retry-After
+func (r Result) WriteHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(r.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(r.ResetAfter), 10))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(ceilSeconds(r.RetryAfter), 10))
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
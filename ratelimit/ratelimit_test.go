@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryStore()
+	defer store.Close()
+	b := NewTokenBucket(1, 2, store)
+	b.TimeFun = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		if r, _ := b.Allow("ip"); !r.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	r, _ := b.Allow("ip")
+	if r.Allowed || r.RetryAfter != time.Second {
+		t.Fatalf("expected rejection with 1s retry, got %+v", r)
+	}
+	if r, _ := b.Allow("other"); !r.Allowed {
+		t.Fatal("keys must be limited independently")
+	}
+	now = now.Add(time.Second)
+	if r, _ := b.Allow("ip"); !r.Allowed {
+		t.Fatal("token should be refilled after 1s")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	now := time.Unix(600, 0)
+	store := NewMemoryStore()
+	defer store.Close()
+	w := NewSlidingWindow(3, time.Minute, store)
+	w.TimeFun = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if r, _ := w.Allow("k"); !r.Allowed || r.Remaining != 2-i {
+			t.Fatalf("request %d: %+v", i, r)
+		}
+	}
+	if r, _ := w.Allow("k"); r.Allowed {
+		t.Fatal("fourth request in window should be rejected")
+	}
+	//下一个窗口的前半段, 上一个窗口仍有一半权重
+	now = now.Add(90 * time.Second)
+	if r, _ := w.Allow("k"); !r.Allowed {
+		t.Fatal("expected allowance after previous window decays")
+	}
+	if r, _ := w.Allow("k"); r.Allowed {
+		t.Fatal("weighted count should reject")
+	}
+}
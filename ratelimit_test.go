@@ -0,0 +1,68 @@
+package msgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/ratelimit"
+)
+
+func TestLimiterPerMiddleware(t *testing.T) {
+	e := newTestEngine()
+	g := e.Group("api")
+	g.Get("/a", func(ctx *Context) {}, LimiterWithConfig(LimiterConfig{}))
+	g.Get("/b", func(ctx *Context) {}, LimiterWithConfig(LimiterConfig{}))
+	for i := 0; i < 20; i++ {
+		if w := serve(e, http.MethodGet, "/api/a", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d", i, w.Code)
+		}
+	}
+	if w := serve(e, http.MethodGet, "/api/a", nil); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("burst exceeded: got %d", w.Code)
+	}
+	if w := serve(e, http.MethodGet, "/api/b", nil); w.Code != http.StatusOK {
+		t.Fatalf("separate middleware must not share the limiter: got %d", w.Code)
+	}
+}
+
+func TestLimiterKeyByClientIP(t *testing.T) {
+	e := newTestEngine()
+	if err := e.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	var key string
+	e.Group("api").Get("/a", func(ctx *Context) {}, LimiterWithConfig(LimiterConfig{
+		Limiter: ratelimit.NewTokenBucket(1, 1, ratelimit.NewMemoryStore()),
+		KeyFunc: func(ctx *Context) string {
+			key = KeyByClientIP(ctx)
+			return key
+		},
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/api/a", nil)
+	r.RemoteAddr = "10.0.0.1:80"
+	r.Header.Set("X-Forwarded-For", "9.9.9.9")
+	e.ServeHTTP(httptest.NewRecorder(), r)
+	if key != "ip:9.9.9.9" {
+		t.Fatalf("key = %q, want ip:9.9.9.9", key)
+	}
+}
+
+func TestLimiterRoutesIncludeGroup(t *testing.T) {
+	e := newTestEngine()
+	login := ratelimit.NewTokenBucket(1, 1, ratelimit.NewMemoryStore())
+	limiter := LimiterWithConfig(LimiterConfig{Routes: map[string]ratelimit.Limiter{
+		"/admin/login": login,
+		"/user/login":  login,
+	}})
+	e.Group("admin").Post("/login", func(ctx *Context) {}, limiter)
+	e.Group("user").Post("/login", func(ctx *Context) {}, limiter)
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if w := serve(e, http.MethodPost, "/admin/login", nil); w.Code != want {
+			t.Fatalf("admin request %d: got %d, want %d", i+1, w.Code, want)
+		}
+	}
+	if w := serve(e, http.MethodPost, "/user/login", nil); w.Code != http.StatusOK {
+		t.Fatalf("same sub-path in another group must not share the limit: got %d", w.Code)
+	}
+}
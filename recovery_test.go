@@ -0,0 +1,74 @@
+package msgo
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/mslog"
+)
+
+func newRecoveryEngine(conf RecoveryConfig, h HandlerFunc) *Engine {
+	e := newTestEngine()
+	e.Logger = mslog.New()
+	e.Group("p").Get("/panic", h, RecoveryWithConfig(conf))
+	return e
+}
+
+func TestRecovery(t *testing.T) {
+	var stack []string
+	onPanic := func(ctx *Context, value any, s []string) { stack = s }
+	cases := []struct {
+		name     string
+		handler  HandlerFunc
+		wantCode int
+		wantBody string
+	}{
+		{"panic", func(ctx *Context) { panic("boom") }, http.StatusInternalServerError, "*"},
+		{"written", func(ctx *Context) {
+			_ = ctx.String(http.StatusAccepted, "partial")
+			panic("boom")
+		}, http.StatusAccepted, "partial"},
+		{"broken pipe", func(ctx *Context) {
+			panic(fmt.Errorf("write tcp: %w", syscall.EPIPE))
+		}, http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		stack = nil
+		e := newRecoveryEngine(RecoveryConfig{OnPanic: onPanic}, c.handler)
+		w := serve(e, http.MethodGet, "/p/panic", nil)
+		if w.Code != c.wantCode {
+			t.Errorf("%s: code = %d, want %d", c.name, w.Code, c.wantCode)
+		}
+		//* 表示只检查状态码
+		if c.wantBody != "*" && w.Body.String() != c.wantBody {
+			t.Errorf("%s: body = %q, want %q", c.name, w.Body, c.wantBody)
+		}
+		if len(stack) == 0 || !strings.Contains(stack[0], "recovery_test.go") {
+			t.Errorf("%s: stack must start at the panicking handler, got %q", c.name, stack)
+		}
+	}
+}
+
+func TestRecoveryOnPanicResponse(t *testing.T) {
+	e := newRecoveryEngine(RecoveryConfig{OnPanic: func(ctx *Context, value any, stack []string) {
+		_ = ctx.String(http.StatusServiceUnavailable, "custom")
+	}}, func(ctx *Context) { panic("boom") })
+	w := serve(e, http.MethodGet, "/p/panic", nil)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "custom" {
+		t.Fatalf("got %d %q, want the OnPanic response", w.Code, w.Body)
+	}
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	e := newRecoveryEngine(RecoveryConfig{}, func(ctx *Context) { panic(http.ErrAbortHandler) })
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", v)
+		}
+	}()
+	serve(e, http.MethodGet, "/p/panic", nil)
+	t.Fatal("http.ErrAbortHandler must be re-panicked")
+}
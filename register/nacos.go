@@ -1,6 +1,7 @@
 package register
 
 import (
+	"context"
 	"fmt"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients/naming_client"
@@ -77,6 +78,21 @@ func (m *MsNacos) GetService(serviceName string) (string, error) {
 	return fmt.Sprintf("%s:%d", instance.Ip, instance.Port), nil
 }
 
+// Ping 查询服务列表检查注册中心连通性, 用于健康检查
+func (m *MsNacos) Ping(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.client.GetAllServicesInfo(vo.GetAllServiceInfoParam{PageNo: 1, PageSize: 1})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (m *MsNacos) Close() error {
 	m.client.CloseClient()
 	return nil
@@ -0,0 +1,19 @@
+package render
+
+import "net/http"
+
+type Data struct {
+	ContentType string
+	Data        []byte
+}
+
+func (d *Data) WriteContentType(w http.ResponseWriter) {
+	if d.ContentType != "" {
+		w.Header().Set("Content-Type", d.ContentType)
+	}
+}
+
+func (d *Data) Render(w http.ResponseWriter) error {
+	_, err := w.Write(d.Data)
+	return err
+}
@@ -0,0 +1,36 @@
+package render
+
+import (
+	"html/template"
+	"net/http"
+)
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>body{font-family:monospace;margin:2em}h1{color:#c0392b}pre{background:#f6f6f6;padding:1em;white-space:pre-wrap}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Name}}<p>template: <b>{{.Name}}</b></p>{{end}}
+<pre>{{.Err}}</pre>
+</body>
+</html>`))
+
+// DebugError 开发模式下的错误调试页面
+type DebugError struct {
+	Title string
+	Name  string
+	Err   error
+}
+
+func (d *DebugError) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html;charset=utf-8")
+}
+
+func (d *DebugError) Render(w http.ResponseWriter) error {
+	if d.Title == "" {
+		d.Title = "Template Error"
+	}
+	return debugTemplate.Execute(w, d)
+}
@@ -7,9 +7,15 @@ import (
 
 type JSON struct {
 	Data any
+	//ContentType 默认 application/json;charset=utf-8
+	ContentType string
 }
 
 func (J *JSON) WriteContentType(w http.ResponseWriter) {
+	if J.ContentType != "" {
+		w.Header().Set("Content-Type", J.ContentType)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
 }
 
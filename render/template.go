@@ -0,0 +1,181 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TemplateSet 命名模板集合, 每个页面单独与布局、公共片段组合解析, 页面通过 define 覆盖布局中的 block
+type TemplateSet struct {
+	Name string
+	//FS 为空时从本地文件系统加载, 可传入 embed.FS
+	FS fs.FS
+	//Layouts 布局文件 glob, 为空时直接执行页面模板
+	Layouts []string
+	//Partials 公共片段 glob, 页面中通过 template 引用
+	Partials []string
+	//Pages 页面文件 glob, 以文件名作为页面名称
+	Pages []string
+	//Layout 执行的入口模板名称, 默认使用第一个布局文件名
+	Layout    string
+	FuncMap   template.FuncMap
+	HotReload bool
+
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+	modTimes  map[string]time.Time
+	//layout 实际使用的入口模板名称, 热加载时在锁内更新
+	layout string
+}
+
+// Load 解析全部模板
+func (s *TemplateSet) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *TemplateSet) load() error {
+	layouts, err := s.glob(s.Layouts)
+	if err != nil {
+		return err
+	}
+	partials, err := s.glob(s.Partials)
+	if err != nil {
+		return err
+	}
+	pages, err := s.glob(s.Pages)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("template set %s: no pages matched", s.Name)
+	}
+	layout := s.Layout
+	if layout == "" && len(layouts) > 0 {
+		layout = path.Base(filepath.ToSlash(layouts[0]))
+	}
+	base := template.New(s.Name).Funcs(s.FuncMap)
+	for _, file := range append(layouts, partials...) {
+		if err := s.parseFile(base, file); err != nil {
+			return err
+		}
+	}
+	templates := make(map[string]*template.Template, len(pages))
+	for _, file := range pages {
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err := s.parseFile(t, file); err != nil {
+			return err
+		}
+		templates[path.Base(filepath.ToSlash(file))] = t
+	}
+	modTimes := make(map[string]time.Time)
+	for _, file := range append(append(layouts, partials...), pages...) {
+		modTimes[file] = s.modTime(file)
+	}
+	s.templates = templates
+	s.modTimes = modTimes
+	s.layout = layout
+	return nil
+}
+
+// Lookup 获取页面模板及入口名称, 开启热加载时文件变化会重新解析
+func (s *TemplateSet) Lookup(page string) (*template.Template, string, error) {
+	if s.HotReload && s.changed() {
+		s.mu.Lock()
+		err := s.load()
+		s.mu.Unlock()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	s.mu.RLock()
+	t, ok := s.templates[page]
+	layout := s.layout
+	s.mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("template set %s: page %s not found", s.Name, page)
+	}
+	if layout != "" {
+		return t, layout, nil
+	}
+	return t, page, nil
+}
+
+func (s *TemplateSet) changed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	files, err := s.glob(append(append(append([]string{}, s.Layouts...), s.Partials...), s.Pages...))
+	if err != nil || len(files) != len(s.modTimes) {
+		return true
+	}
+	for _, file := range files {
+		t, ok := s.modTimes[file]
+		if !ok || !t.Equal(s.modTime(file)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *TemplateSet) glob(patterns []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		var matches []string
+		var err error
+		if s.FS != nil {
+			matches, err = fs.Glob(s.FS, pattern)
+		} else {
+			matches, err = filepath.Glob(pattern)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	return files, nil
+}
+
+func (s *TemplateSet) parseFile(t *template.Template, file string) error {
+	var b []byte
+	var err error
+	if s.FS != nil {
+		b, err = fs.ReadFile(s.FS, file)
+	} else {
+		b, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = t.New(path.Base(filepath.ToSlash(file))).Parse(string(b))
+	return err
+}
+
+func (s *TemplateSet) modTime(file string) time.Time {
+	var info fs.FileInfo
+	var err error
+	if s.FS != nil {
+		info, err = fs.Stat(s.FS, file)
+	} else {
+		info, err = os.Stat(file)
+	}
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
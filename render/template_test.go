@@ -0,0 +1,109 @@
+package render
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTemplateSet_Lookup(t *testing.T) {
+	set := &TemplateSet{
+		Name: "site",
+		FS: fstest.MapFS{
+			"layouts/base.html":  {Data: []byte(`<main>{{template "nav.html" .}}{{block "content" .}}{{end}}</main>`)},
+			"partials/nav.html":  {Data: []byte(`<nav/>`)},
+			"pages/index.html":   {Data: []byte(`{{define "content"}}index {{.}}{{end}}`)},
+			"pages/profile.html": {Data: []byte(`{{define "content"}}profile {{.}}{{end}}`)},
+		},
+		Layouts:  []string{"layouts/*.html"},
+		Partials: []string{"partials/*.html"},
+		Pages:    []string{"pages/*.html"},
+	}
+	if err := set.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for page, want := range map[string]string{
+		"index.html":   "<main><nav/>index msgo</main>",
+		"profile.html": "<main><nav/>profile msgo</main>",
+	} {
+		tpl, name, err := set.Lookup(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := tpl.ExecuteTemplate(&buf, name, "msgo"); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("%s: got %q, want %q", page, buf.String(), want)
+		}
+	}
+	if _, _, err := set.Lookup("missing.html"); err == nil {
+		t.Error("expected error for missing page")
+	}
+}
+
+func TestTemplateSet_HotReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string, mod time.Time) {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(file, mod, mod)
+	}
+	mod := time.Now().Add(-time.Hour)
+	write("base.html", `<main>{{block "content" .}}{{end}}</main>`, mod)
+	write("index.html", `{{define "content"}}v1{{end}}`, mod)
+	set := &TemplateSet{
+		Name:      "site",
+		Layouts:   []string{filepath.Join(dir, "base.html")},
+		Pages:     []string{filepath.Join(dir, "index.html")},
+		HotReload: true,
+	}
+	if err := set.Load(); err != nil {
+		t.Fatal(err)
+	}
+	render := func() string {
+		tpl, name, err := set.Lookup("index.html")
+		if err != nil {
+			t.Error(err)
+			return ""
+		}
+		var buf bytes.Buffer
+		if err := tpl.ExecuteTemplate(&buf, name, nil); err != nil {
+			t.Error(err)
+		}
+		return buf.String()
+	}
+	if got := render(); got != "<main>v1</main>" {
+		t.Fatalf("got %q", got)
+	}
+	if set.Layout != "" {
+		t.Fatalf("loading must not modify the Layout option, got %q", set.Layout)
+	}
+
+	//热加载与并发的 Lookup 同时进行
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				render()
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		mod = mod.Add(time.Second)
+		write("index.html", `{{define "content"}}v2{{end}}`, mod)
+	}
+	wg.Wait()
+	if got := render(); got != "<main>v2</main>" {
+		t.Fatalf("changed page must be reloaded, got %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+package msgo
+
+import (
+	"github.com/liyuanwu2020/msgo/mslog"
+	"github.com/liyuanwu2020/msgo/requestid"
+)
+
+// RequestIDConfig 请求 ID 中间件配置
+type RequestIDConfig struct {
+	//Header 请求 ID 头, 默认 X-Request-ID
+	Header string
+	//Generator 生成请求 ID, 默认随机 32 位十六进制
+	Generator func() string
+}
+
+// RequestID 使用默认配置的请求 ID 中间件
+func RequestID(next HandlerFunc) HandlerFunc {
+	return RequestIDWithConfig(RequestIDConfig{})(next)
+}
+
+// RequestIDWithConfig 读取或生成请求 ID, 写入响应头、ctx.Logger 字段和 ctx.R 的 context
+func RequestIDWithConfig(conf RequestIDConfig) MiddlewareFunc {
+	if conf.Header == "" {
+		conf.Header = requestid.HeaderName
+	}
+	if conf.Generator == nil {
+		conf.Generator = requestid.New
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			id := ctx.R.Header.Get(conf.Header)
+			if !requestid.Valid(id) {
+				id = conf.Generator()
+			}
+			ctx.requestID = id
+			ctx.W.Header().Set(conf.Header, id)
+			ctx.R = ctx.R.WithContext(requestid.NewContext(ctx.R.Context(), id))
+			if ctx.Logger != nil {
+				ctx.Logger = ctx.Logger.WithFields(mslog.Fields{"request_id": id})
+			}
+			next(ctx)
+		}
+	}
+}
+
+// RequestID 当前请求 ID, 未使用 RequestID 中间件时为空
+func (c *Context) RequestID() string {
+	return c.requestID
+}
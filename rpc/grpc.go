@@ -2,9 +2,12 @@ package rpc
 
 import (
 	"context"
+	"github.com/liyuanwu2020/msgo/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"net"
 	"time"
 )
@@ -129,3 +132,64 @@ func DefaultGrpcClientConfig(addr string) *MsGrpcClientConfig {
 		Address:     addr,
 	}
 }
+
+// mdCarrier grpc metadata 载体
+type mdCarrier metadata.MD
+
+func (c mdCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c mdCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// TracingUnaryServerInterceptor 读取调用方 traceparent 并为每次调用创建服务端 span
+func TracingUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			ctx = tracing.Extract(ctx, mdCarrier(md))
+		}
+		ctx, span := tracing.Start(ctx, info.FullMethod, tracing.SpanKindServer)
+		defer span.End()
+		span.SetAttribute("rpc.system", "grpc")
+		rsp, err := handler(ctx, req)
+		span.SetAttribute("rpc.grpc.status_code", status.Code(err).String())
+		span.SetError(err)
+		return rsp, err
+	}
+}
+
+// TracingUnaryClientInterceptor 为每次调用创建客户端 span 并写入 traceparent
+func TracingUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := tracing.Start(ctx, method, tracing.SpanKindClient)
+		defer span.End()
+		span.SetAttribute("rpc.system", "grpc")
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		tracing.Inject(ctx, mdCarrier(md))
+		err := invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+		span.SetAttribute("rpc.grpc.status_code", status.Code(err).String())
+		span.SetError(err)
+		return err
+	}
+}
+
+// GrpcWithTracing 服务端开启链路追踪
+func GrpcWithTracing() MsGrpcOption {
+	return GrpcWithOptions(grpc.ChainUnaryInterceptor(TracingUnaryServerInterceptor()))
+}
+
+// WithTracing 客户端开启链路追踪
+func (c *MsGrpcClientConfig) WithTracing() *MsGrpcClientConfig {
+	c.dialOptions = append(c.dialOptions, grpc.WithChainUnaryInterceptor(TracingUnaryClientInterceptor()))
+	return c
+}
@@ -3,9 +3,13 @@ package rpc
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/liyuanwu2020/msgo/apikey"
+	"github.com/liyuanwu2020/msgo/requestid"
+	"github.com/liyuanwu2020/msgo/tracing"
 	"io"
 	"log"
 	"net/http"
@@ -19,6 +23,7 @@ import (
 type MsHttpClient struct {
 	client     http.Client
 	serviceMap map[string]MsService
+	signer     *apikey.Signer
 }
 
 func NewHttpClient() *MsHttpClient {
@@ -38,12 +43,23 @@ func NewHttpClient() *MsHttpClient {
 	}
 }
 
+// WithSigner 为发出的请求添加 HMAC 签名, 与服务端的 msgo.APIKey 中间件配合使用
+func (c *MsHttpClient) WithSigner(signer *apikey.Signer) *MsHttpClient {
+	c.signer = signer
+	return c
+}
+
 func (c *MsHttpClient) Get(url string, args map[string]any) ([]byte, error) {
+	return c.GetContext(context.Background(), url, args)
+}
+
+// GetContext 携带 context 的请求, context 中的请求 ID 会传递给下游服务
+func (c *MsHttpClient) GetContext(ctx context.Context, url string, args map[string]any) ([]byte, error) {
 	if args != nil && len(args) > 0 {
 		url = url + "?" + c.toValues(args)
 	}
 	log.Println(url)
-	request, err := http.NewRequest(http.MethodGet, url, nil)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +67,11 @@ func (c *MsHttpClient) Get(url string, args map[string]any) ([]byte, error) {
 }
 
 func (c *MsHttpClient) PostForm(url string, args map[string]any) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, strings.NewReader(c.toValues(args)))
+	return c.PostFormContext(context.Background(), url, args)
+}
+
+func (c *MsHttpClient) PostFormContext(ctx context.Context, url string, args map[string]any) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(c.toValues(args)))
 	if err != nil {
 		return nil, err
 	}
@@ -59,8 +79,12 @@ func (c *MsHttpClient) PostForm(url string, args map[string]any) ([]byte, error)
 }
 
 func (c *MsHttpClient) PostJson(url string, args map[string]any) ([]byte, error) {
+	return c.PostJsonContext(context.Background(), url, args)
+}
+
+func (c *MsHttpClient) PostJsonContext(ctx context.Context, url string, args map[string]any) ([]byte, error) {
 	jsonStr, _ := json.Marshal(args)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonStr))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonStr))
 	if err != nil {
 		return nil, err
 	}
@@ -78,11 +102,34 @@ func (c *MsHttpClient) toValues(args map[string]any) string {
 	return ""
 }
 
+// handleResponse 发送请求, 透传请求 ID 并为本次调用创建客户端 span, 配置 signer 时为请求签名
 func (c *MsHttpClient) handleResponse(request *http.Request) ([]byte, error) {
+	if id := requestid.FromContext(request.Context()); id != "" && request.Header.Get(requestid.HeaderName) == "" {
+		request.Header.Set(requestid.HeaderName, id)
+	}
+	ctx, span := tracing.Start(request.Context(), "HTTP "+request.Method, tracing.SpanKindClient)
+	defer span.End()
+	span.SetAttribute("http.method", request.Method)
+	span.SetAttribute("http.url", request.URL.String())
+	request = request.WithContext(ctx)
+	tracing.Inject(ctx, tracing.HeaderCarrier(request.Header))
+	if c.signer != nil {
+		if err := c.signer.SignRequest(request); err != nil {
+			span.SetError(err)
+			return nil, err
+		}
+	}
+	body, err := c.doRequest(request, span)
+	span.SetError(err)
+	return body, err
+}
+
+func (c *MsHttpClient) doRequest(request *http.Request, span *tracing.Span) ([]byte, error) {
 	response, err := c.client.Do(request)
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttribute("http.status_code", response.StatusCode)
 	if response.StatusCode != http.StatusOK {
 		return nil, errors.New(fmt.Sprintf("response status is %d", response.StatusCode))
 	}
@@ -155,6 +202,11 @@ func (c *MsHttpClient) RegisterHttpService(serviceName string, service MsService
 }
 
 func (c *MsHttpClient) Do(service string, method string) MsService {
+	return c.DoContext(context.Background(), service, method)
+}
+
+// DoContext 与 Do 相同, 生成的调用方法使用 ctx 发起请求
+func (c *MsHttpClient) DoContext(ctx context.Context, service string, method string) MsService {
 	msService, ok := c.serviceMap[service]
 	if !ok {
 		panic(errors.New("service not found"))
@@ -190,13 +242,13 @@ func (c *MsHttpClient) Do(service string, method string) MsService {
 	f := func(args map[string]any) ([]byte, error) {
 		fmt.Println(mt, prefix+path)
 		if mt == GET {
-			return c.Get(prefix+path, args)
+			return c.GetContext(ctx, prefix+path, args)
 		}
 		if mt == POSTForm {
-			return c.PostForm(prefix+path, args)
+			return c.PostFormContext(ctx, prefix+path, args)
 		}
 		if mt == POSTJson {
-			return c.PostJson(prefix+path, args)
+			return c.PostJsonContext(ctx, prefix+path, args)
 		}
 		return nil, errors.New("no method match :" + mt)
 	}
@@ -0,0 +1,103 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/liyuanwu2020/msgo/requestid"
+	"github.com/liyuanwu2020/msgo/tracing"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+var errNoResponse = errors.New("rpc: no response")
+
+// MsTcpClient tcp rpc 客户端, 每次调用使用一个连接
+type MsTcpClient struct {
+	Host          string
+	Port          int
+	Network       string
+	CompressType  CompressType
+	SerializeType SerializeType
+	requestId     int64
+}
+
+func NewTcpClient(host string, port int) *MsTcpClient {
+	return &MsTcpClient{
+		Host:          host,
+		Port:          port,
+		Network:       "tcp",
+		CompressType:  Gzip,
+		SerializeType: Gob,
+	}
+}
+
+// Invoke 调用远程服务方法, ctx 中的请求 ID 会通过帧元数据传递给服务端
+func (c *MsTcpClient) Invoke(ctx context.Context, serviceName string, methodName string, args ...any) (rsp *MsRpcResponse, err error) {
+	ctx, span := tracing.Start(ctx, serviceName+"/"+methodName, tracing.SpanKindClient)
+	defer func() {
+		if err != nil {
+			clientCalls.Inc(serviceName, methodName, "error")
+			span.SetError(err)
+			span.End()
+			return
+		}
+		observeCall(clientCalls, serviceName, methodName, rsp.Code)
+		endSpan(span, rsp)
+	}()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.Network, fmt.Sprintf("%s:%d", c.Host, c.Port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	req := &MsRpcRequest{
+		RequestId:   atomic.AddInt64(&c.requestId, 1),
+		ServiceName: serviceName,
+		MethodName:  methodName,
+		Args:        args,
+	}
+	serializer, err := loadSerialize(c.SerializeType)
+	if err != nil {
+		return nil, err
+	}
+	body, err := serializer.Serialize(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err = compress(body, c.CompressType)
+	if err != nil {
+		return nil, err
+	}
+	header := &Header{
+		MessageType:   msgRequest,
+		CompressType:  c.CompressType,
+		SerializeType: c.SerializeType,
+		RequestId:     req.RequestId,
+		Version:       metadataVersion,
+	}
+	header.Metadata = make(map[string]string)
+	if id := requestid.FromContext(ctx); id != "" {
+		header.Metadata[requestid.HeaderName] = id
+	}
+	tracing.Inject(ctx, tracing.MapCarrier(header.Metadata))
+	if err := writeFrame(conn, header, body); err != nil {
+		return nil, err
+	}
+	msg := decodeFrame(conn)
+	if msg == nil {
+		return nil, errNoResponse
+	}
+	rsp, ok := msg.Data.(*MsRpcResponse)
+	if !ok || rsp == nil {
+		return nil, errNoResponse
+	}
+	return rsp, nil
+}
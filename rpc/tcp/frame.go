@@ -0,0 +1,89 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+)
+
+// 协议版本 2 在 17 字节固定头之后增加元数据: 2 字节长度 + 若干 (2 字节 key 长度, key, 2 字节 value 长度, value)
+const metadataVersion = 0x02
+
+const headerLen = 17
+
+var errMetadataTooLarge = errors.New("rpc metadata too large")
+
+func encodeMetadata(md map[string]string) ([]byte, error) {
+	var buf []byte
+	for k, v := range md {
+		if len(k) > math.MaxUint16 || len(v) > math.MaxUint16 {
+			return nil, errMetadataTooLarge
+		}
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(k)))
+		buf = append(buf, k...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(v)))
+		buf = append(buf, v...)
+	}
+	if len(buf) > math.MaxUint16 {
+		return nil, errMetadataTooLarge
+	}
+	return buf, nil
+}
+
+func decodeMetadata(buf []byte) (map[string]string, error) {
+	md := make(map[string]string)
+	readString := func() (string, error) {
+		if len(buf) < 2 {
+			return "", io.ErrUnexpectedEOF
+		}
+		n := int(binary.BigEndian.Uint16(buf))
+		if len(buf) < 2+n {
+			return "", io.ErrUnexpectedEOF
+		}
+		s := string(buf[2 : 2+n])
+		buf = buf[2+n:]
+		return s, nil
+	}
+	for len(buf) > 0 {
+		k, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		md[k] = v
+	}
+	return md, nil
+}
+
+// writeFrame 按 header.Version 写入一帧数据, 版本 1 为固定头 + 消息体, 版本 2 起在消息体前增加元数据,
+// Version 为 0 时按版本 1 写入
+func writeFrame(conn net.Conn, header *Header, body []byte) error {
+	v := header.Version
+	if v == 0 {
+		v = version
+	}
+	frame := make([]byte, headerLen, headerLen+len(body))
+	frame[0] = mn
+	frame[1] = v
+	frame[6] = byte(header.MessageType)
+	frame[7] = byte(header.CompressType)
+	frame[8] = byte(header.SerializeType)
+	binary.BigEndian.PutUint64(frame[9:], uint64(header.RequestId))
+	if v >= metadataVersion {
+		md, err := encodeMetadata(header.Metadata)
+		if err != nil {
+			return err
+		}
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(md)))
+		frame = append(frame, md...)
+	}
+	frame = append(frame, body...)
+	binary.BigEndian.PutUint32(frame[2:6], uint32(len(frame)))
+	_, err := conn.Write(frame)
+	return err
+}
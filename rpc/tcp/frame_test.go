@@ -0,0 +1,86 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+)
+
+// roundTrip 在内存连接上写入一帧并解码
+func roundTrip(t *testing.T, write func(conn net.Conn) error) *MsRpcMessage {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	errs := make(chan error, 1)
+	go func() { errs <- write(client) }()
+	msg := decodeFrame(server)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil {
+		t.Fatal("frame could not be decoded")
+	}
+	return msg
+}
+
+func encodeRequest(t *testing.T, req *MsRpcRequest) []byte {
+	t.Helper()
+	body, err := GobSerializer{}.Serialize(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = compress(body, Gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestFrameVersions(t *testing.T) {
+	req := &MsRpcRequest{RequestId: 7, ServiceName: "user", MethodName: "Find", Args: []any{"1"}}
+	body := encodeRequest(t, req)
+	for _, v := range []byte{version, metadataVersion} {
+		header := &Header{Version: v, MessageType: msgRequest, CompressType: Gzip, SerializeType: Gob, RequestId: 7,
+			Metadata: map[string]string{"X-Request-Id": "abc"}}
+		msg := roundTrip(t, func(conn net.Conn) error { return writeFrame(conn, header, body) })
+		if msg.Header.Version != v || msg.Header.RequestId != 7 {
+			t.Fatalf("v%d: unexpected header %+v", v, msg.Header)
+		}
+		got := msg.Data.(*MsRpcRequest)
+		if got.ServiceName != "user" || got.MethodName != "Find" || got.Args[0] != "1" {
+			t.Fatalf("v%d: unexpected request %+v", v, got)
+		}
+		wantID := ""
+		if v >= metadataVersion {
+			wantID = "abc"
+		}
+		if id := msg.Header.Metadata["X-Request-Id"]; id != wantID {
+			t.Fatalf("v%d: metadata request id %q, want %q", v, id, wantID)
+		}
+	}
+}
+
+func TestFrameV1Layout(t *testing.T) {
+	body := encodeRequest(t, &MsRpcRequest{RequestId: 1})
+	msg := roundTrip(t, func(conn net.Conn) error {
+		return writeFrame(conn, &Header{MessageType: msgRequest, Metadata: map[string]string{"k": "v"}}, body)
+	})
+	//版本 1 的帧长度只包含固定头和消息体
+	if msg.Header.Version != version || int(msg.Header.FullLength) != headerLen+len(body) {
+		t.Fatalf("unexpected v1 header %+v for body of %d bytes", msg.Header, len(body))
+	}
+}
+
+func TestSendUsesRequestVersion(t *testing.T) {
+	for _, v := range []byte{version, metadataVersion} {
+		c := &MsTcpConn{version: v, metadata: map[string]string{"X-Request-Id": "abc"}}
+		rsp := &MsRpcResponse{RequestId: 3, Code: 200, Data: "ok"}
+		msg := roundTrip(t, func(conn net.Conn) error { return c.Send(conn, rsp) })
+		if msg.Header.Version != v {
+			t.Fatalf("response to a v%d request was sent as v%d", v, msg.Header.Version)
+		}
+		if got := msg.Data.(*MsRpcResponse); got.Code != 200 || got.Data != "ok" || got.RequestId != 3 {
+			t.Fatalf("unexpected response %+v", got)
+		}
+	}
+}
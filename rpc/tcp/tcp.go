@@ -8,10 +8,14 @@ import (
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"github.com/liyuanwu2020/msgo/metrics"
+	"github.com/liyuanwu2020/msgo/requestid"
+	"github.com/liyuanwu2020/msgo/tracing"
 	"io"
 	"log"
 	"net"
 	"reflect"
+	"strconv"
 	"time"
 )
 
@@ -44,7 +48,34 @@ type MsRpcMessage struct {
 	Data any
 }
 
+var (
+	serverCalls = metrics.NewCounterVec("msgo_rpc_server_calls_total",
+		"Total number of rpc calls handled by the tcp server.", "service", "method", "code")
+	clientCalls = metrics.NewCounterVec("msgo_rpc_client_calls_total",
+		"Total number of rpc calls made by the tcp client.", "service", "method", "code")
+)
+
+func init() {
+	metrics.MustRegister(serverCalls, clientCalls)
+}
+
+// observeCall 记录 rpc 调用次数, code 为响应码, 调用失败时为 error
+func observeCall(calls *metrics.CounterVec, service, method string, code int16) {
+	calls.Inc(service, method, strconv.Itoa(int(code)))
+}
+
+// endSpan 记录响应码, 非 200 时标记错误
+func endSpan(span *tracing.Span, rsp *MsRpcResponse) {
+	span.SetAttribute("rpc.code", rsp.Code)
+	if rsp.Code != 200 {
+		span.SetError(errors.New(rsp.Msg))
+	}
+	span.End()
+}
+
 const mn byte = 0x1d
+
+// version 协议版本 1, 没有元数据
 const version = 0x01
 
 type CompressType byte
@@ -77,6 +108,8 @@ type Header struct {
 	CompressType  CompressType
 	SerializeType SerializeType
 	RequestId     int64
+	//Metadata 版本 2 起支持, 用于传递请求 ID 等链路信息
+	Metadata map[string]string
 }
 
 type MsRpcRequest struct {
@@ -110,9 +143,12 @@ type MsTcpServer struct {
 }
 
 type MsTcpConn struct {
-	s       *MsTcpServer
-	conn    net.Conn
-	rspChan chan *MsRpcResponse
+	s        *MsTcpServer
+	conn     net.Conn
+	rspChan  chan *MsRpcResponse
+	metadata map[string]string
+	//version 请求帧的协议版本, 响应使用相同版本, 兼容只支持版本 1 的客户端
+	version byte
 }
 
 func (c *MsTcpConn) writeHandle() {
@@ -134,21 +170,6 @@ func (c *MsTcpConn) writeHandle() {
 }
 
 func (c *MsTcpConn) Send(conn net.Conn, rsp *MsRpcResponse) error {
-	headers := make([]byte, 17)
-	//magic number
-	headers[0] = mn
-	//version
-	headers[1] = version
-	//full length
-	//消息类型
-	headers[6] = byte(msgResponse)
-	//压缩类型
-	headers[7] = byte(rsp.CompressType)
-	//序列化
-	headers[8] = byte(rsp.SerializeType)
-	//请求id
-	binary.BigEndian.PutUint64(headers[9:], uint64(rsp.RequestId))
-
 	serializer, err := loadSerialize(rsp.SerializeType)
 	if err != nil {
 		return err
@@ -161,13 +182,15 @@ func (c *MsTcpConn) Send(conn net.Conn, rsp *MsRpcResponse) error {
 	if err != nil {
 		return err
 	}
-	fullLen := 17 + len(body)
-	binary.BigEndian.PutUint32(headers[2:6], uint32(fullLen))
-	_, err = conn.Write(headers[:])
-	if err != nil {
-		return err
+	header := &Header{
+		MessageType:   msgResponse,
+		CompressType:  rsp.CompressType,
+		SerializeType: rsp.SerializeType,
+		RequestId:     rsp.RequestId,
+		Version:       c.version,
+		Metadata:      c.metadata,
 	}
-	err = binary.Write(c.conn, binary.BigEndian, body[:])
+	err = writeFrame(conn, header, body)
 	if err != nil {
 		return err
 	}
@@ -218,33 +241,57 @@ func (s *MsTcpServer) readHandle(msConn *MsTcpConn) {
 			msConn.conn.Close()
 		}
 	}()
-	msg := s.decodeFrame(msConn.conn)
+	msg := decodeFrame(msConn.conn)
 	if msg == nil {
 		msConn.rspChan <- nil
 		return
 	}
+	msConn.version = msg.Header.Version
+	//请求 ID 原样回传, 并通过 context 传给服务方法
+	requestID := msg.Header.Metadata[requestid.HeaderName]
+	if requestID != "" {
+		msConn.metadata = map[string]string{requestid.HeaderName: requestID}
+	}
+	callCtx := requestid.NewContext(context.Background(), requestID)
+	callCtx = tracing.Extract(callCtx, tracing.MapCarrier(msg.Header.Metadata))
 	//根据请求
 	if msg.Header.MessageType == msgRequest {
 		req := msg.Data.(*MsRpcRequest)
 		//查找注册的服务匹配后进行调用，调用完发送到一个channel当中
 		service, ok := s.serviceMap[req.ServiceName]
 		rsp := &MsRpcResponse{RequestId: req.RequestId, CompressType: msg.Header.CompressType, SerializeType: msg.Header.SerializeType}
+		var span *tracing.Span
+		callCtx, span = tracing.Start(callCtx, req.ServiceName+"/"+req.MethodName, tracing.SpanKindServer)
+		defer endSpan(span, rsp)
 		if !ok {
 			rsp.Code = 500
 			rsp.Msg = "no service found"
+			observeCall(serverCalls, "unknown", "unknown", rsp.Code)
 			msConn.rspChan <- rsp
 			return
 		}
 		v := reflect.ValueOf(service)
 		reflectMethod := v.MethodByName(req.MethodName)
-		args := make([]reflect.Value, len(req.Args))
+		if !reflectMethod.IsValid() {
+			rsp.Code = 500
+			rsp.Msg = "no method found"
+			observeCall(serverCalls, req.ServiceName, "unknown", rsp.Code)
+			msConn.rspChan <- rsp
+			return
+		}
+		args := make([]reflect.Value, 0, len(req.Args)+1)
+		//方法第一个参数为 context.Context 时注入调用上下文
+		if mt := reflectMethod.Type(); mt.NumIn() > 0 && mt.In(0) == contextType {
+			args = append(args, reflect.ValueOf(callCtx))
+		}
 		for i := range req.Args {
-			args[i] = reflect.ValueOf(req.Args[i])
+			args = append(args, reflect.ValueOf(req.Args[i]))
 		}
 		result := reflectMethod.Call(args)
 		if len(result) == 0 {
 			//无返回结果
 			rsp.Code = 200
+			observeCall(serverCalls, req.ServiceName, req.MethodName, rsp.Code)
 			msConn.rspChan <- rsp
 			return
 		}
@@ -260,9 +307,11 @@ func (s *MsTcpServer) readHandle(msConn *MsTcpConn) {
 		if err != nil {
 			rsp.Code = 500
 			rsp.Msg = err.Error()
+		} else {
+			rsp.Code = 200
 		}
-		rsp.Code = 200
 		rsp.Data = resArgs[0]
+		observeCall(serverCalls, req.ServiceName, req.MethodName, rsp.Code)
 		msConn.rspChan <- rsp
 		log.Println("接收数据成功")
 		return
@@ -278,7 +327,9 @@ func (s *MsTcpServer) Close() {
 	}
 }
 
-func (*MsTcpServer) decodeFrame(conn net.Conn) *MsRpcMessage {
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+func decodeFrame(conn net.Conn) *MsRpcMessage {
 	//读取数据 先读取header部分
 	//1+1+4+1+1+1+8 = 17字节
 	headers := make([]byte, 17)
@@ -310,7 +361,30 @@ func (*MsTcpServer) decodeFrame(conn net.Conn) *MsRpcMessage {
 	//将body解析出来，包装成request 根据请求内容查找对应的服务，完成调用
 	//网络调用 大端
 	fl := int32(binary.BigEndian.Uint32(fullLength))
-	bodyLen := fl - 17
+	bodyLen := fl - headerLen
+	var metadata map[string]string
+	if version >= metadataVersion {
+		mdLen := make([]byte, 2)
+		if _, err := io.ReadFull(conn, mdLen); err != nil {
+			log.Println(err)
+			return nil
+		}
+		md := make([]byte, binary.BigEndian.Uint16(mdLen))
+		if _, err := io.ReadFull(conn, md); err != nil {
+			log.Println(err)
+			return nil
+		}
+		metadata, err = decodeMetadata(md)
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+		bodyLen -= int32(2 + len(md))
+	}
+	if bodyLen < 0 {
+		log.Println("frame length not valid : ", fl)
+		return nil
+	}
 	body := make([]byte, bodyLen)
 	_, err = io.ReadFull(conn, body)
 	log.Println("读完了")
@@ -338,6 +412,7 @@ func (*MsTcpServer) decodeFrame(conn net.Conn) *MsRpcMessage {
 	header.SerializeType = SerializeType(serializeType)
 	header.RequestId = int64(binary.BigEndian.Uint64(requestId))
 	header.MessageType = messageType
+	header.Metadata = metadata
 
 	if messageType == msgRequest {
 		msg := &MsRpcMessage{}
@@ -0,0 +1,99 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/liyuanwu2020/msgo/requestid"
+	"github.com/liyuanwu2020/msgo/tracing"
+)
+
+type goodsService struct{}
+
+func (s *goodsService) Find(id string) (string, error) {
+	if id == "" {
+		return "", errors.New("id is required")
+	}
+	return "goods " + id, nil
+}
+
+func TestReadHandleResponseCode(t *testing.T) {
+	s := NewTcpServer("127.0.0.1", 0)
+	s.Register("goods", &goodsService{})
+	cases := []struct {
+		arg  string
+		code int16
+		msg  string
+	}{
+		{"1", 200, ""},
+		{"", 500, "id is required"},
+	}
+	for _, c := range cases {
+		client, server := net.Pipe()
+		msConn := &MsTcpConn{conn: server, rspChan: make(chan *MsRpcResponse, 1), s: s}
+		go s.readHandle(msConn)
+		body := encodeRequest(t, &MsRpcRequest{RequestId: 1, ServiceName: "goods", MethodName: "Find", Args: []any{c.arg}})
+		header := &Header{Version: metadataVersion, MessageType: msgRequest, CompressType: Gzip, SerializeType: Gob, RequestId: 1}
+		if err := writeFrame(client, header, body); err != nil {
+			t.Fatal(err)
+		}
+		rsp := <-msConn.rspChan
+		if rsp.Code != c.code || rsp.Msg != c.msg {
+			t.Errorf("Find(%q) = %d %q, want %d %q", c.arg, rsp.Code, rsp.Msg, c.code, c.msg)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+// traceService 记录服务端收到的请求 ID 和链路
+type traceService struct {
+	requestID chan string
+	traceID   chan tracing.TraceID
+}
+
+func (s *traceService) Echo(ctx context.Context, msg string) (string, error) {
+	s.requestID <- requestid.FromContext(ctx)
+	s.traceID <- tracing.SpanContextFromContext(ctx).TraceID
+	return msg, nil
+}
+
+func TestClientInvokePropagatesMetadata(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	svc := &traceService{requestID: make(chan string, 1), traceID: make(chan tracing.TraceID, 1)}
+	s := NewTcpServer("127.0.0.1", 0)
+	s.Register("trace", svc)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		msConn := &MsTcpConn{conn: conn, rspChan: make(chan *MsRpcResponse, 1), s: s}
+		go s.readHandle(msConn)
+		msConn.writeHandle()
+	}()
+
+	ctx := requestid.NewContext(context.Background(), "req-42")
+	ctx, span := tracing.Start(ctx, "caller", tracing.SpanKindInternal)
+	defer span.End()
+	client := NewTcpClient("127.0.0.1", listener.Addr().(*net.TCPAddr).Port)
+	rsp, err := client.Invoke(ctx, "trace", "Echo", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Code != 200 || rsp.Data != "hi" {
+		t.Fatalf("Invoke = %d %v, want 200 hi", rsp.Code, rsp.Data)
+	}
+	if got := <-svc.requestID; got != "req-42" {
+		t.Errorf("server request id = %q, want req-42", got)
+	}
+	if got, want := <-svc.traceID, span.SpanContext().TraceID; got != want {
+		t.Errorf("server trace id = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package msgo
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSPNonce 在 CSP 来源中占位, 每个请求替换为 'nonce-xxx'
+const CSPNonce = "'nonce'"
+
+// SecureConfig 安全响应头配置
+type SecureConfig struct {
+	//HSTSMaxAge Strict-Transport-Security 有效期, 默认 180 天, 仅在 https 请求中发送
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	DisableHSTS           bool
+	//FrameOptions X-Frame-Options, 默认 DENY
+	FrameOptions string
+	//DisableContentTypeNosniff 不发送 X-Content-Type-Options: nosniff
+	DisableContentTypeNosniff bool
+	//ReferrerPolicy 默认 strict-origin-when-cross-origin
+	ReferrerPolicy string
+	//CSP 内容安全策略, 为空时不发送
+	CSP *ContentSecurityPolicy
+	//CSPReportOnly 使用 Content-Security-Policy-Report-Only 头
+	CSPReportOnly bool
+}
+
+// ContentSecurityPolicy CSP 构建器, 按添加顺序输出指令
+type ContentSecurityPolicy struct {
+	directives []cspDirective
+}
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+func NewCSP() *ContentSecurityPolicy {
+	return &ContentSecurityPolicy{}
+}
+
+// Directive 添加指令, 重复添加同名指令时追加来源
+func (p *ContentSecurityPolicy) Directive(name string, sources ...string) *ContentSecurityPolicy {
+	for i := range p.directives {
+		if p.directives[i].name == name {
+			p.directives[i].sources = append(p.directives[i].sources, sources...)
+			return p
+		}
+	}
+	p.directives = append(p.directives, cspDirective{name: name, sources: sources})
+	return p
+}
+
+func (p *ContentSecurityPolicy) DefaultSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("default-src", sources...)
+}
+
+func (p *ContentSecurityPolicy) ScriptSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("script-src", sources...)
+}
+
+func (p *ContentSecurityPolicy) StyleSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("style-src", sources...)
+}
+
+func (p *ContentSecurityPolicy) ImgSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("img-src", sources...)
+}
+
+func (p *ContentSecurityPolicy) ConnectSrc(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("connect-src", sources...)
+}
+
+func (p *ContentSecurityPolicy) FrameAncestors(sources ...string) *ContentSecurityPolicy {
+	return p.Directive("frame-ancestors", sources...)
+}
+
+func (p *ContentSecurityPolicy) ReportURI(uri string) *ContentSecurityPolicy {
+	return p.Directive("report-uri", uri)
+}
+
+// UsesNonce 策略中是否包含 CSPNonce 占位
+func (p *ContentSecurityPolicy) UsesNonce() bool {
+	for _, d := range p.directives {
+		for _, s := range d.sources {
+			if s == CSPNonce {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Build 生成头部取值, nonce 替换 CSPNonce 占位
+func (p *ContentSecurityPolicy) Build(nonce string) string {
+	var sb strings.Builder
+	for i, d := range p.directives {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(d.name)
+		for _, s := range d.sources {
+			if s == CSPNonce {
+				if nonce == "" {
+					continue
+				}
+				s = "'nonce-" + nonce + "'"
+			}
+			sb.WriteByte(' ')
+			sb.WriteString(s)
+		}
+	}
+	return sb.String()
+}
+
+// Secure 使用默认配置的安全响应头中间件
+func Secure(next HandlerFunc) HandlerFunc {
+	return defaultSecure(next)
+}
+
+var defaultSecure = SecureWithConfig(SecureConfig{})
+
+func SecureWithConfig(conf SecureConfig) MiddlewareFunc {
+	if conf.HSTSMaxAge <= 0 {
+		conf.HSTSMaxAge = 180 * 24 * time.Hour
+	}
+	if conf.FrameOptions == "" {
+		conf.FrameOptions = "DENY"
+	}
+	if conf.ReferrerPolicy == "" {
+		conf.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+	hsts := "max-age=" + strconv.FormatInt(int64(conf.HSTSMaxAge/time.Second), 10)
+	if conf.HSTSIncludeSubdomains {
+		hsts += "; includeSubDomains"
+	}
+	if conf.HSTSPreload {
+		hsts += "; preload"
+	}
+	cspHeader := "Content-Security-Policy"
+	if conf.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	var staticCSP string
+	useNonce := conf.CSP != nil && conf.CSP.UsesNonce()
+	if conf.CSP != nil && !useNonce {
+		staticCSP = conf.CSP.Build("")
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			h := ctx.W.Header()
+			if !conf.DisableHSTS && ctx.Scheme() == "https" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			h.Set("X-Frame-Options", conf.FrameOptions)
+			if !conf.DisableContentTypeNosniff {
+				h.Set("X-Content-Type-Options", "nosniff")
+			}
+			h.Set("Referrer-Policy", conf.ReferrerPolicy)
+			if useNonce {
+				ctx.cspNonce = newNonce()
+				h.Set(cspHeader, conf.CSP.Build(ctx.cspNonce))
+			} else if staticCSP != "" {
+				h.Set(cspHeader, staticCSP)
+			}
+			next(ctx)
+		}
+	}
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// CSPNonce 当前请求的 CSP nonce, 模板中使用 {{ cspNonce }}
+func (c *Context) CSPNonce() string {
+	return c.cspNonce
+}
@@ -0,0 +1,108 @@
+package msgo
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	e := newTestEngine()
+	e.Group("page").Get("/index", func(ctx *Context) {}, Secure)
+	w := serve(e, http.MethodGet, "/page/index", nil)
+	want := map[string]string{
+		"X-Frame-Options":           "DENY",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Strict-Transport-Security": "",
+		"Content-Security-Policy":   "",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("http %s = %q, want %q", k, got, v)
+		}
+	}
+	w = serve(e, http.MethodGet, "https://example.com/page/index", nil)
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=15552000" {
+		t.Errorf("https Strict-Transport-Security = %q", got)
+	}
+}
+
+func TestSecureHSTSOptions(t *testing.T) {
+	e := newTestEngine()
+	e.Group("page").Get("/index", func(ctx *Context) {}, SecureWithConfig(SecureConfig{
+		HSTSIncludeSubdomains:     true,
+		HSTSPreload:               true,
+		FrameOptions:              "SAMEORIGIN",
+		DisableContentTypeNosniff: true,
+	}))
+	w := serve(e, http.MethodGet, "https://example.com/page/index", nil)
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=15552000; includeSubDomains; preload" {
+		t.Errorf("Strict-Transport-Security = %q", got)
+	}
+	if got := w.Header().Get("X-Frame-Options"); got != "SAMEORIGIN" {
+		t.Errorf("X-Frame-Options = %q", got)
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "" {
+		t.Errorf("X-Content-Type-Options = %q, want empty", got)
+	}
+}
+
+func TestContentSecurityPolicyBuild(t *testing.T) {
+	p := NewCSP().DefaultSrc("'self'").ScriptSrc("'self'", CSPNonce).ImgSrc("*").ScriptSrc("cdn.example.com")
+	if !p.UsesNonce() {
+		t.Fatal("UsesNonce = false")
+	}
+	cases := []struct {
+		nonce string
+		want  string
+	}{
+		{"abc", "default-src 'self'; script-src 'self' 'nonce-abc' cdn.example.com; img-src *"},
+		{"", "default-src 'self'; script-src 'self' cdn.example.com; img-src *"},
+	}
+	for _, c := range cases {
+		if got := p.Build(c.nonce); got != c.want {
+			t.Errorf("Build(%q) = %q, want %q", c.nonce, got, c.want)
+		}
+	}
+	if NewCSP().DefaultSrc("'self'").UsesNonce() {
+		t.Error("UsesNonce = true without placeholder")
+	}
+}
+
+func TestSecureCSPNonce(t *testing.T) {
+	e := newTestEngine()
+	csp := NewCSP().ScriptSrc(CSPNonce)
+	e.Group("page").Get("/index", func(ctx *Context) {
+		_ = ctx.String(http.StatusOK, "%s", ctx.CSPNonce())
+	}, SecureWithConfig(SecureConfig{CSP: csp, CSPReportOnly: true}))
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		w := serve(e, http.MethodGet, "/page/index", nil)
+		nonce := w.Body.String()
+		if nonce == "" || seen[nonce] {
+			t.Fatalf("nonce %q is empty or reused", nonce)
+		}
+		seen[nonce] = true
+		if got := w.Header().Get("Content-Security-Policy-Report-Only"); got != "script-src 'nonce-"+nonce+"'" {
+			t.Errorf("Content-Security-Policy-Report-Only = %q", got)
+		}
+		if w.Header().Get("Content-Security-Policy") != "" {
+			t.Error("report only mode must not send Content-Security-Policy")
+		}
+	}
+}
+
+func TestSecureStaticCSP(t *testing.T) {
+	e := newTestEngine()
+	e.Group("page").Get("/index", func(ctx *Context) {
+		_ = ctx.String(http.StatusOK, "%s", ctx.CSPNonce())
+	}, SecureWithConfig(SecureConfig{CSP: NewCSP().DefaultSrc("'self'")}))
+	w := serve(e, http.MethodGet, "/page/index", nil)
+	if got := w.Header().Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Errorf("Content-Security-Policy = %q", got)
+	}
+	if strings.TrimSpace(w.Body.String()) != "" {
+		t.Errorf("static policy must not generate a nonce, got %q", w.Body)
+	}
+}
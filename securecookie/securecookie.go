@@ -0,0 +1,164 @@
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoKeys        = errors.New("securecookie: no keys configured")
+	ErrInvalidValue  = errors.New("securecookie: invalid value")
+	ErrInvalidMac    = errors.New("securecookie: signature mismatch")
+	ErrDecryptFailed = errors.New("securecookie: decrypt failed")
+	ErrExpired       = errors.New("securecookie: value expired")
+)
+
+// Codec cookie 值签名与加密, 支持密钥轮换: 第一个密钥用于签名/加密, 所有密钥都可用于校验/解密
+type Codec struct {
+	//HashKeys HMAC-SHA256 签名密钥
+	HashKeys [][]byte
+	//BlockKeys AES-GCM 加密密钥, 长度 16/24/32 字节, 为空时只签名
+	BlockKeys [][]byte
+	//MaxAge 签名时间戳有效期, 0 表示不校验
+	MaxAge  time.Duration
+	TimeFun func() time.Time
+}
+
+func New(hashKeys, blockKeys [][]byte) *Codec {
+	return &Codec{HashKeys: hashKeys, BlockKeys: blockKeys}
+}
+
+func (c *Codec) now() time.Time {
+	if c.TimeFun != nil {
+		return c.TimeFun()
+	}
+	return time.Now()
+}
+
+// Encode 编码 cookie 值, name 参与签名和加密防止值在不同 cookie 之间挪用
+func (c *Codec) Encode(name string, value []byte) (string, error) {
+	if len(c.HashKeys) == 0 && len(c.BlockKeys) == 0 {
+		return "", ErrNoKeys
+	}
+	var err error
+	if len(c.BlockKeys) > 0 {
+		value, err = encrypt(c.BlockKeys[0], []byte(name), value)
+		if err != nil {
+			return "", err
+		}
+	}
+	payload := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(payload, uint64(c.now().Unix()))
+	copy(payload[8:], value)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	if len(c.HashKeys) == 0 {
+		return encoded, nil
+	}
+	mac := sign(c.HashKeys[0], name, payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// Decode 校验并解码 cookie 值
+func (c *Codec) Decode(name, value string) ([]byte, error) {
+	if len(c.HashKeys) == 0 && len(c.BlockKeys) == 0 {
+		return nil, ErrNoKeys
+	}
+	encoded, macStr, signed := strings.Cut(value, ".")
+	if signed != (len(c.HashKeys) > 0) {
+		return nil, ErrInvalidValue
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) < 8 {
+		return nil, ErrInvalidValue
+	}
+	if signed {
+		mac, err := base64.RawURLEncoding.DecodeString(macStr)
+		if err != nil {
+			return nil, ErrInvalidValue
+		}
+		verified := false
+		for _, key := range c.HashKeys {
+			if hmac.Equal(mac, sign(key, name, payload)) {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return nil, ErrInvalidMac
+		}
+	}
+	if c.MaxAge > 0 {
+		ts := time.Unix(int64(binary.BigEndian.Uint64(payload[:8])), 0)
+		if c.now().Sub(ts) > c.MaxAge {
+			return nil, ErrExpired
+		}
+	}
+	data := payload[8:]
+	if len(c.BlockKeys) == 0 {
+		return data, nil
+	}
+	for _, key := range c.BlockKeys {
+		if plain, err := decrypt(key, []byte(name), data); err == nil {
+			return plain, nil
+		}
+	}
+	return nil, ErrDecryptFailed
+}
+
+func sign(key []byte, name string, payload []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encrypt(key, additional, plain []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, additional), nil
+}
+
+func decrypt(key, additional, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrDecryptFailed
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additional)
+}
+
+// GenerateKey 生成随机密钥
+func GenerateKey(length int) []byte {
+	k := make([]byte, length)
+	if _, err := io.ReadFull(rand.Reader, k); err != nil {
+		return nil
+	}
+	return k
+}
@@ -0,0 +1,40 @@
+package securecookie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCodec_KeyRotation(t *testing.T) {
+	oldKey, newKey := []byte("old-hash-key"), []byte("new-hash-key")
+	oldBlock, newBlock := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+
+	old := New([][]byte{oldKey}, [][]byte{oldBlock})
+	encoded, err := old.Encode("session", []byte("msgo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := New([][]byte{newKey, oldKey}, [][]byte{newBlock, oldBlock})
+	value, err := rotated.Decode("session", encoded)
+	if err != nil || string(value) != "msgo" {
+		t.Fatalf("rotated codec should decode old value: %q %v", value, err)
+	}
+	if _, err := rotated.Decode("other", encoded); err == nil {
+		t.Error("value must not be accepted under another cookie name")
+	}
+	if _, err := New([][]byte{newKey}, nil).Decode("session", encoded); err == nil {
+		t.Error("removed key must not verify")
+	}
+}
+
+func TestCodec_MaxAge(t *testing.T) {
+	now := time.Now()
+	c := New([][]byte{[]byte("hash-key")}, nil)
+	c.TimeFun = func() time.Time { return now }
+	encoded, _ := c.Encode("token", []byte("v"))
+	c.MaxAge = time.Minute
+	c.TimeFun = func() time.Time { return now.Add(2 * time.Minute) }
+	if _, err := c.Decode("token", encoded); err != ErrExpired {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+}
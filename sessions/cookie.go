@@ -0,0 +1,75 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+
+	"github.com/liyuanwu2020/msgo/securecookie"
+)
+
+// CookieStore 会话数据签名加密后保存在 cookie 中, 无法在服务端吊销
+type CookieStore struct {
+	Options Options
+	Codec   *securecookie.Codec
+}
+
+func NewCookieStore(hashKeys, blockKeys [][]byte) *CookieStore {
+	return &CookieStore{
+		Options: Options{HttpOnly: true},
+		Codec:   securecookie.New(hashKeys, blockKeys),
+	}
+}
+
+// NewCookieStoreWithCodec 复用已有的编码器, 如 Engine.EncryptedCookieCodec()
+func NewCookieStoreWithCodec(codec *securecookie.Codec) *CookieStore {
+	return &CookieStore{
+		Options: Options{HttpOnly: true},
+		Codec:   codec,
+	}
+}
+
+type cookieEntry struct {
+	ID     string
+	Values map[string]any
+}
+
+func (s *CookieStore) Load(r *http.Request, name string) (*Session, error) {
+	session := NewSession(name, s.Options)
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	b, err := s.Codec.Decode(name, cookie.Value)
+	if err != nil {
+		return session, nil
+	}
+	e := &cookieEntry{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(e); err != nil {
+		return session, nil
+	}
+	session.ID = e.ID
+	if e.Values != nil {
+		session.Values = e.Values
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *CookieStore) Save(w http.ResponseWriter, session *Session) error {
+	session.oldID = ""
+	if session.destroyed {
+		http.SetCookie(w, session.Cookie(""))
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&cookieEntry{ID: session.ID, Values: session.Values}); err != nil {
+		return err
+	}
+	value, err := s.Codec.Encode(session.Name, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, session.Cookie(value))
+	return nil
+}
@@ -0,0 +1,156 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fileEntry struct {
+	Values  map[string]any
+	Expires time.Time
+}
+
+// FileStore 文件会话存储, 每个会话一个文件, 自定义类型需要 gob.Register
+type FileStore struct {
+	Options Options
+	dir     string
+	ttl     time.Duration
+	lock    sync.RWMutex
+}
+
+func NewFileStore(dir string, ttl time.Duration) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		Options: Options{HttpOnly: true},
+		dir:     dir,
+		ttl:     ttl,
+	}, nil
+}
+
+func (s *FileStore) filename(id string) (string, bool) {
+	//会话 ID 为 base64url 字符, 拒绝包含路径的 cookie 值
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", false
+	}
+	return filepath.Join(s.dir, "sess_"+id), true
+}
+
+func (s *FileStore) read(id string) (*fileEntry, error) {
+	name, ok := s.filename(id)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	s.lock.RLock()
+	b, err := os.ReadFile(name)
+	s.lock.RUnlock()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	e := &fileEntry{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(e); err != nil {
+		return nil, err
+	}
+	if time.Now().After(e.Expires) {
+		_ = s.Revoke(id)
+		return nil, ErrSessionNotFound
+	}
+	return e, nil
+}
+
+func (s *FileStore) Load(r *http.Request, name string) (*Session, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return NewSession(name, s.Options), nil
+	}
+	e, err := s.read(cookie.Value)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return NewSession(name, s.Options), nil
+		}
+		return nil, err
+	}
+	session := NewSession(name, s.Options)
+	session.ID = cookie.Value
+	if e.Values != nil {
+		session.Values = e.Values
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *FileStore) Save(w http.ResponseWriter, session *Session) error {
+	if session.oldID != "" {
+		if err := s.Revoke(session.oldID); err != nil {
+			return err
+		}
+		session.oldID = ""
+	}
+	if session.destroyed {
+		if err := s.Revoke(session.ID); err != nil {
+			return err
+		}
+	} else {
+		var buf bytes.Buffer
+		e := &fileEntry{Values: session.Values, Expires: time.Now().Add(ttlOf(session.Options, s.ttl))}
+		if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+			return err
+		}
+		name, ok := s.filename(session.ID)
+		if !ok {
+			return ErrSessionNotFound
+		}
+		s.lock.Lock()
+		err := os.WriteFile(name, buf.Bytes(), 0600)
+		s.lock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	http.SetCookie(w, session.Cookie(session.ID))
+	return nil
+}
+
+func (s *FileStore) Exists(id string) (bool, error) {
+	_, err := s.read(id)
+	if errors.Is(err, ErrSessionNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *FileStore) Revoke(id string) error {
+	name, ok := s.filename(id)
+	if !ok {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// GC 删除过期的会话文件
+func (s *FileStore) GC() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "sess_*"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		_, _ = s.read(strings.TrimPrefix(filepath.Base(f), "sess_"))
+	}
+	return nil
+}
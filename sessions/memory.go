@@ -0,0 +1,116 @@
+package sessions
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	values  map[string]any
+	expires time.Time
+}
+
+// MemoryStore 内存会话存储, 过期会话定时清理
+type MemoryStore struct {
+	Options Options
+	ttl     time.Duration
+	lock    sync.RWMutex
+	entries map[string]*memoryEntry
+	release chan struct{}
+	once    sync.Once
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		Options: Options{HttpOnly: true},
+		ttl:     ttl,
+		entries: make(map[string]*memoryEntry),
+		release: make(chan struct{}),
+	}
+	go s.expireEntries()
+	return s
+}
+
+// 定时清理过期会话
+func (s *MemoryStore) expireEntries() {
+	interval := s.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			s.lock.Lock()
+			for id, e := range s.entries {
+				if now.After(e.expires) {
+					delete(s.entries, id)
+				}
+			}
+			s.lock.Unlock()
+		case <-s.release:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) Load(r *http.Request, name string) (*Session, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return NewSession(name, s.Options), nil
+	}
+	s.lock.RLock()
+	e, ok := s.entries[cookie.Value]
+	s.lock.RUnlock()
+	if !ok || time.Now().After(e.expires) {
+		return NewSession(name, s.Options), nil
+	}
+	session := NewSession(name, s.Options)
+	session.ID = cookie.Value
+	session.Values = copyValues(e.values)
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *MemoryStore) Save(w http.ResponseWriter, session *Session) error {
+	s.lock.Lock()
+	if session.oldID != "" {
+		delete(s.entries, session.oldID)
+		session.oldID = ""
+	}
+	if session.destroyed {
+		delete(s.entries, session.ID)
+	} else {
+		s.entries[session.ID] = &memoryEntry{
+			values:  copyValues(session.Values),
+			expires: time.Now().Add(ttlOf(session.Options, s.ttl)),
+		}
+	}
+	s.lock.Unlock()
+	http.SetCookie(w, session.Cookie(session.ID))
+	return nil
+}
+
+func (s *MemoryStore) Exists(id string) (bool, error) {
+	s.lock.RLock()
+	e, ok := s.entries[id]
+	s.lock.RUnlock()
+	return ok && time.Now().Before(e.expires), nil
+}
+
+func (s *MemoryStore) Revoke(id string) error {
+	s.lock.Lock()
+	delete(s.entries, id)
+	s.lock.Unlock()
+	return nil
+}
+
+// Close 停止过期清理
+func (s *MemoryStore) Close() {
+	s.once.Do(func() {
+		close(s.release)
+	})
+}
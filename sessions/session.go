@@ -0,0 +1,164 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+const flashKey = "_flash"
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func init() {
+	gob.Register([]any{})
+	gob.Register(map[string]any{})
+}
+
+// Store 会话存储
+type Store interface {
+	// Load 根据请求 cookie 加载会话, 不存在或失效时返回新会话
+	Load(r *http.Request, name string) (*Session, error)
+	// Save 保存会话并写入 cookie
+	Save(w http.ResponseWriter, s *Session) error
+}
+
+// Revoker 服务端存储, 可以按会话 ID 校验与吊销
+type Revoker interface {
+	Exists(id string) (bool, error)
+	Revoke(id string) error
+}
+
+// Options 会话 cookie 配置
+type Options struct {
+	Path     string
+	Domain   string
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+type Session struct {
+	ID      string
+	Name    string
+	Values  map[string]any
+	Options Options
+	IsNew   bool
+	//oldID 重新生成 ID 前的会话 ID, 保存时从存储中删除
+	oldID     string
+	destroyed bool
+}
+
+func NewSession(name string, options Options) *Session {
+	return &Session{
+		ID:      NewID(),
+		Name:    name,
+		Values:  make(map[string]any),
+		Options: options,
+		IsNew:   true,
+	}
+}
+
+func (s *Session) Get(key string) any {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key string, value any) {
+	s.Values[key] = value
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+}
+
+// AddFlash 添加一次性消息, 读取后即删除
+func (s *Session) AddFlash(value any) {
+	flashes, _ := s.Values[flashKey].([]any)
+	s.Values[flashKey] = append(flashes, value)
+}
+
+// Flashes 读取并清除一次性消息
+func (s *Session) Flashes() []any {
+	flashes, _ := s.Values[flashKey].([]any)
+	delete(s.Values, flashKey)
+	return flashes
+}
+
+// Regenerate 重新生成会话 ID, 登录成功后调用防止会话固定攻击
+func (s *Session) Regenerate() {
+	if s.oldID == "" && !s.IsNew {
+		s.oldID = s.ID
+	}
+	s.ID = NewID()
+}
+
+// OldID 重新生成前的会话 ID
+func (s *Session) OldID() string {
+	return s.oldID
+}
+
+// Destroy 销毁会话, 保存时删除存储并清除 cookie
+func (s *Session) Destroy() {
+	s.destroyed = true
+	s.Values = make(map[string]any)
+}
+
+func (s *Session) IsDestroyed() bool {
+	return s.destroyed
+}
+
+// Cookie 根据会话配置生成 cookie
+func (s *Session) Cookie(value string) *http.Cookie {
+	path := s.Options.Path
+	if path == "" {
+		path = "/"
+	}
+	maxAge := s.Options.MaxAge
+	if s.destroyed {
+		value = ""
+		maxAge = -1
+	}
+	cookie := &http.Cookie{
+		Name:     s.Name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.Options.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.Options.Secure,
+		HttpOnly: s.Options.HttpOnly,
+		SameSite: s.Options.SameSite,
+	}
+	if maxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(maxAge) * time.Second)
+	}
+	return cookie
+}
+
+// NewID 生成随机会话 ID
+func NewID() string {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func copyValues(values map[string]any) map[string]any {
+	m := make(map[string]any, len(values))
+	for k, v := range values {
+		m[k] = v
+	}
+	return m
+}
+
+func ttlOf(options Options, ttl time.Duration) time.Duration {
+	if options.MaxAge > 0 {
+		return time.Duration(options.MaxAge) * time.Second
+	}
+	return ttl
+}
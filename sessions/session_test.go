@@ -0,0 +1,91 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// roundTrip 保存会话并把响应 cookie 带到下一次请求
+func roundTrip(t *testing.T, store Store, s *Session) *http.Request {
+	w := httptest.NewRecorder()
+	if err := store.Save(w, s); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	defer store.Close()
+	s, _ := store.Load(httptest.NewRequest(http.MethodGet, "/", nil), "sid")
+	if !s.IsNew {
+		t.Fatal("expected new session")
+	}
+	s.Set("user", "msgo")
+	s.AddFlash("saved")
+	r := roundTrip(t, store, s)
+
+	loaded, _ := store.Load(r, "sid")
+	if loaded.IsNew || loaded.Get("user") != "msgo" {
+		t.Fatalf("session not loaded: %+v", loaded)
+	}
+	if f := loaded.Flashes(); len(f) != 1 || f[0] != "saved" {
+		t.Fatalf("unexpected flashes %v", f)
+	}
+
+	oldID := loaded.ID
+	loaded.Regenerate()
+	roundTrip(t, store, loaded)
+	if ok, _ := store.Exists(oldID); ok {
+		t.Error("old session id should be removed after regenerate")
+	}
+	if err := store.Revoke(loaded.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Exists(loaded.ID); ok {
+		t.Error("revoked session should not exist")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := store.Load(httptest.NewRequest(http.MethodGet, "/", nil), "sid")
+	s.Set("id", 7)
+	r := roundTrip(t, store, s)
+	loaded, err := store.Load(r, "sid")
+	if err != nil || loaded.IsNew || loaded.Get("id") != 7 {
+		t.Fatalf("session not loaded: %+v %v", loaded, err)
+	}
+	loaded.Destroy()
+	roundTrip(t, store, loaded)
+	if ok, _ := store.Exists(loaded.ID); ok {
+		t.Error("destroyed session should not exist")
+	}
+}
+
+func TestCookieStore(t *testing.T) {
+	store := NewCookieStore([][]byte{[]byte("hash-key")}, [][]byte{[]byte("0123456789abcdef")})
+	s, _ := store.Load(httptest.NewRequest(http.MethodGet, "/", nil), "sid")
+	s.Set("user", "msgo")
+	r := roundTrip(t, store, s)
+	loaded, _ := store.Load(r, "sid")
+	if loaded.IsNew || loaded.Get("user") != "msgo" {
+		t.Fatalf("session not loaded: %+v", loaded)
+	}
+
+	c, _ := r.Cookie("sid")
+	tampered := httptest.NewRequest(http.MethodGet, "/", nil)
+	tampered.AddCookie(&http.Cookie{Name: "sid", Value: "x" + c.Value})
+	if loaded, _ := store.Load(tampered, "sid"); !loaded.IsNew {
+		t.Error("tampered cookie should start a new session")
+	}
+}
@@ -0,0 +1,78 @@
+package msgo
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateDevModeReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.html")
+	write := func(data string) {
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{{define "index"}}v1 {{.}}{{end}}`)
+	for _, dev := range []bool{false, true} {
+		e := newTestEngine()
+		e.DevMode = dev
+		e.LoadTemplate(filepath.Join(dir, "*.html"))
+		e.Group("page").Get("/index", func(ctx *Context) { _ = ctx.Template("index", "msgo") })
+		if w := serve(e, http.MethodGet, "/page/index", nil); w.Body.String() != "v1 msgo" {
+			t.Fatalf("dev=%v: got %q", dev, w.Body)
+		}
+		write(`{{define "index"}}v2 {{.}}{{end}}`)
+		want := "v1 msgo"
+		if dev {
+			want = "v2 msgo"
+		}
+		if w := serve(e, http.MethodGet, "/page/index", nil); w.Body.String() != want {
+			t.Fatalf("dev=%v: after change got %q, want %q", dev, w.Body, want)
+		}
+		write(`{{define "index"}}v1 {{.}}{{end}}`)
+	}
+}
+
+func TestTemplateRequestFuncs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(file, []byte(`{{define "index"}}<script nonce="{{cspNonce}}"></script>{{csrfToken}}{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEngine()
+	e.LoadTemplate(filepath.Join(dir, "*.html"))
+	csp := SecureWithConfig(SecureConfig{CSP: NewCSP().ScriptSrc(CSPNonce)})
+	g := e.Group("page")
+	g.Get("/plain", func(ctx *Context) { _ = ctx.Template("index", nil) })
+	g.Get("/secure", func(ctx *Context) {
+		_ = ctx.Template("index", nil)
+		_ = ctx.String(http.StatusOK, "|%s|%s", ctx.CSPNonce(), ctx.CSRFToken())
+	}, csp, CSRF)
+	if w := serve(e, http.MethodGet, "/page/plain", nil); w.Body.String() != `<script nonce=""></script>` {
+		t.Fatalf("plain: got %q", w.Body)
+	}
+	//副本复用时必须绑定当前请求的值
+	for i := 0; i < 3; i++ {
+		w := serve(e, http.MethodGet, "/page/secure", nil)
+		parts := strings.Split(w.Body.String(), "|")
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			t.Fatalf("secure: got %q", w.Body)
+		}
+		if want := `<script nonce="` + parts[1] + `"></script>` + parts[2]; parts[0] != want {
+			t.Fatalf("secure: rendered %q, want %q", parts[0], want)
+		}
+	}
+	//重新设置模板后替换旧模板的请求级副本
+	e.LoadTemplate(filepath.Join(dir, "*.html"))
+	serve(e, http.MethodGet, "/page/secure", nil)
+	if n := len(e.requestTemplatesOf); n != 1 {
+		t.Fatalf("request templates cached for %d keys, want 1", n)
+	}
+	if e.requestTemplatesOf["html_render"].src != e.HTMLRender.Template {
+		t.Fatal("request templates not replaced after reload")
+	}
+}
@@ -0,0 +1,98 @@
+package token
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/liyuanwu2020/msgo"
+)
+
+const (
+	// ClaimsKey AuthInterceptor 写入 ctx 的 jwt.MapClaims 的键
+	ClaimsKey = "claims"
+	// TypedClaimsKey 设置 ClaimsFactory 时 AuthInterceptor 写入 ctx 的类型化 claims 的键
+	TypedClaimsKey = "typed_claims"
+)
+
+// Authenticate 把返回类型化 claims 的认证函数转换为 Authenticator, 如
+//
+//	j.Authenticator = token.Authenticate(func(ctx *msgo.Context) (*UserClaims, error) { ... })
+func Authenticate[C any](fn func(ctx *msgo.Context) (C, error)) func(ctx *msgo.Context) (map[string]any, error) {
+	return func(ctx *msgo.Context) (map[string]any, error) {
+		c, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data := map[string]any{}
+		if err := convertClaims(c, &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+}
+
+// ClaimsFrom 读取 AuthInterceptor 写入的 claims, 类型与 ClaimsFactory 返回的类型一致时直接返回,
+// 否则由 jwt.MapClaims 转换, 未认证或转换失败时返回 false
+func ClaimsFrom[C any](ctx *msgo.Context) (C, bool) {
+	var zero C
+	if v, ok := ctx.Get(TypedClaimsKey); ok {
+		if c, ok := v.(C); ok {
+			return c, true
+		}
+	}
+	v, ok := ctx.Get(ClaimsKey)
+	if !ok {
+		return zero, false
+	}
+	if c, ok := v.(C); ok {
+		return c, true
+	}
+	c, err := decodeClaims[C](v)
+	if err != nil {
+		return zero, false
+	}
+	return c, true
+}
+
+// MustClaimsFrom 同 ClaimsFrom, 读取失败时 panic, 用于 AuthInterceptor 之后的处理函数
+func MustClaimsFrom[C any](ctx *msgo.Context) C {
+	c, ok := ClaimsFrom[C](ctx)
+	if !ok {
+		panic(ErrTokenInvalid.WithMessage("claims are not available"))
+	}
+	return c
+}
+
+// decodeClaims 把 claims 转换为 C, C 为指针时分配新的值
+func decodeClaims[C any](claims any) (C, error) {
+	var c C
+	t := reflect.TypeOf(&c).Elem()
+	if t.Kind() == reflect.Pointer {
+		v := reflect.New(t.Elem())
+		if err := convertClaims(claims, v.Interface()); err != nil {
+			return c, err
+		}
+		return v.Interface().(C), nil
+	}
+	err := convertClaims(claims, &c)
+	return c, err
+}
+
+// typedClaims 按 ClaimsFactory 创建类型化 claims
+func (j *JwtHandler) typedClaims(claims jwt.MapClaims) (jwt.Claims, error) {
+	c := j.ClaimsFactory()
+	if err := convertClaims(claims, c); err != nil {
+		return nil, ErrTokenInvalid.WithCause(err)
+	}
+	return c, nil
+}
+
+// convertClaims 经 JSON 在 claims 类型之间转换
+func convertClaims(src, dst any) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
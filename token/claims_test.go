@@ -0,0 +1,110 @@
+package token
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/liyuanwu2020/msgo"
+)
+
+type userClaims struct {
+	jwt.RegisteredClaims
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+func newClaimsHandler() *JwtHandler {
+	j := newTestHandler()
+	j.Authenticator = Authenticate(func(ctx *msgo.Context) (*userClaims, error) {
+		return &userClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "alice"}, Name: "Alice", Roles: []string{"admin"}}, nil
+	})
+	j.ClaimsFactory = func() jwt.Claims { return &userClaims{} }
+	return j
+}
+
+func TestAuthenticate(t *testing.T) {
+	fail := Authenticate(func(ctx *msgo.Context) (*userClaims, error) { return nil, ErrTokenInvalid })
+	if _, err := fail(nil); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("authenticator error must be returned, got %v", err)
+	}
+	j := newClaimsHandler()
+	rsp := login(t, j)
+	parsed, _, err := jwt.NewParser().ParseUnverified(rsp.Token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	if claims["sub"] != "alice" || claims["name"] != "Alice" || claims["roles"].([]any)[0] != "admin" {
+		t.Fatalf("typed claims must be written into the token, got %v", claims)
+	}
+}
+
+func TestClaimsFrom(t *testing.T) {
+	j := newClaimsHandler()
+	tok := login(t, j).Token
+	e := msgo.Default()
+	var ptr *userClaims
+	var value userClaims
+	var mapClaims jwt.MapClaims
+	var okPtr, okValue, okMap bool
+	e.Group("api").Get("/me", func(ctx *msgo.Context) {
+		ptr, okPtr = ClaimsFrom[*userClaims](ctx)
+		value, okValue = ClaimsFrom[userClaims](ctx)
+		mapClaims, okMap = ClaimsFrom[jwt.MapClaims](ctx)
+		ctx.W.WriteHeader(http.StatusOK)
+	}, j.AuthInterceptor)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, bearerRequest(tok))
+	if w.Code != http.StatusOK || !okPtr || !okValue || !okMap {
+		t.Fatalf("ClaimsFrom: code %d ok %v %v %v", w.Code, okPtr, okValue, okMap)
+	}
+	if ptr.Subject != "alice" || ptr.Name != "Alice" || ptr.ExpiresAt == nil {
+		t.Fatalf("typed claims %+v", ptr)
+	}
+	if value.Name != "Alice" || len(value.Roles) != 1 || mapClaims["name"] != "Alice" {
+		t.Fatalf("converted claims %+v %v", value, mapClaims)
+	}
+
+	//未设置 ClaimsFactory 时由 jwt.MapClaims 转换
+	j = newClaimsHandler()
+	j.ClaimsFactory = nil
+	e = msgo.Default()
+	e.Group("api").Get("/me", func(ctx *msgo.Context) {
+		ptr, okPtr = ClaimsFrom[*userClaims](ctx)
+	}, j.AuthInterceptor)
+	e.ServeHTTP(httptest.NewRecorder(), bearerRequest(tok))
+	if !okPtr || ptr.Name != "Alice" {
+		t.Fatalf("claims converted from MapClaims: %+v %v", ptr, okPtr)
+	}
+}
+
+func TestClaimsFactoryMismatch(t *testing.T) {
+	type badClaims struct {
+		jwt.RegisteredClaims
+		Name int `json:"name"`
+	}
+	j := newClaimsHandler()
+	tok := login(t, j).Token
+	j.ClaimsFactory = func() jwt.Claims { return &badClaims{} }
+	if res := serveAuth(j, bearerRequest(tok), true); res.called || !errors.Is(res.err, ErrTokenInvalid) {
+		t.Fatalf("claims that do not fit the factory type: called=%v err=%v", res.called, res.err)
+	}
+}
+
+func TestMustClaimsFromWithoutAuth(t *testing.T) {
+	e := msgo.Default()
+	e.Group("api").Get("/me", func(ctx *msgo.Context) {
+		c := MustClaimsFrom[*userClaims](ctx)
+		_ = ctx.String(http.StatusOK, c.Name)
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/me", nil))
+	//Recovery 按 mserror 的状态码输出, 而不是 500
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "40102") {
+		t.Fatalf("MustClaimsFrom without claims = %d %s, want 401", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,375 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrKeyNotFound    = errors.New("token: key not found")
+	ErrNoSigningKey   = errors.New("token: no signing key")
+	ErrUnsupportedKey = errors.New("token: unsupported key type")
+)
+
+// Key 非对称签名密钥, 只有公钥的 Key 只用于校验
+type Key struct {
+	//ID 写入 token 头的 kid, 为空时使用 RFC 7638 指纹
+	ID string
+	//Alg 签名算法, 为空时根据密钥推断: RSA 为 RS256, P-256/P-384/P-521 为 ES256/ES384/ES512, Ed25519 为 EdDSA
+	Alg        string
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// NewKey 根据私钥或公钥创建 Key, alg 为空时根据密钥推断
+func NewKey(id, alg string, key any) (*Key, error) {
+	k := &Key{ID: id, Alg: alg}
+	switch v := key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		k.PrivateKey = v.(crypto.Signer)
+		k.PublicKey = k.PrivateKey.Public()
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		k.PublicKey = v
+	default:
+		return nil, fmt.Errorf("%w %T", ErrUnsupportedKey, key)
+	}
+	if err := k.checkAlg(); err != nil {
+		return nil, err
+	}
+	if k.ID == "" {
+		k.ID = thumbprint(k.PublicKey)
+	}
+	return k, nil
+}
+
+// checkAlg 推断或校验签名算法与密钥类型是否匹配
+func (k *Key) checkAlg() error {
+	var algs []string
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		algs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			algs = []string{"ES256"}
+		case elliptic.P384():
+			algs = []string{"ES384"}
+		case elliptic.P521():
+			algs = []string{"ES512"}
+		default:
+			return fmt.Errorf("%w: curve %s", ErrUnsupportedKey, pub.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		algs = []string{"EdDSA"}
+	}
+	if k.Alg == "" {
+		k.Alg = algs[0]
+		return nil
+	}
+	for _, alg := range algs {
+		if alg == k.Alg {
+			return nil
+		}
+	}
+	return fmt.Errorf("token: alg %s does not match %T", k.Alg, k.PublicKey)
+}
+
+// ParseKeyPEM 解析 PEM 格式的私钥或公钥, 支持 PKCS#1、PKCS#8、SEC 1、PKIX 和证书
+func ParseKeyPEM(id, alg string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("token: no PEM data found")
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("token: unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(id, alg, key)
+}
+
+// LoadKeyFile 读取 PEM 文件
+func LoadKeyFile(id, alg, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyPEM(id, alg, data)
+}
+
+// KeySet 密钥集合, 使用当前密钥签名, 按 kid 查找密钥校验, 轮换时新旧密钥同时有效
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    []*Key
+	current string
+}
+
+// NewKeySet 第一个带私钥的 Key 作为当前签名密钥
+func NewKeySet(keys ...*Key) (*KeySet, error) {
+	s := &KeySet{}
+	for _, k := range keys {
+		if err := s.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add 添加密钥, 没有当前签名密钥时带私钥的 Key 成为当前密钥
+func (s *KeySet) Add(key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.ID == key.ID {
+			return fmt.Errorf("token: duplicate kid %q", key.ID)
+		}
+	}
+	s.keys = append(s.keys, key)
+	if s.current == "" && key.PrivateKey != nil {
+		s.current = key.ID
+	}
+	return nil
+}
+
+// Remove 移除不再有效的密钥, 移除当前签名密钥后需要重新 SetCurrent
+func (s *KeySet) Remove(kid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.keys {
+		if k.ID == kid {
+			s.keys = append(s.keys[:i:i], s.keys[i+1:]...)
+			break
+		}
+	}
+	if s.current == kid {
+		s.current = ""
+	}
+}
+
+// SetCurrent 切换签名密钥
+func (s *KeySet) SetCurrent(kid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.ID == kid {
+			if k.PrivateKey == nil {
+				return ErrNoSigningKey
+			}
+			s.current = kid
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// Current 当前签名密钥
+func (s *KeySet) Current() (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.ID == s.current {
+			return k, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// Lookup 按 kid 查找密钥, kid 为空且只有一个密钥时返回该密钥
+func (s *KeySet) Lookup(kid string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == "" && len(s.keys) == 1 {
+		return s.keys[0], nil
+	}
+	for _, k := range s.keys {
+		if k.ID == kid {
+			return k, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Algs 集合中出现的签名算法, 用于限制可接受的 alg
+func (s *KeySet) Algs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var algs []string
+	seen := make(map[string]bool)
+	for _, k := range s.keys {
+		if !seen[k.Alg] {
+			seen[k.Alg] = true
+			algs = append(algs, k.Alg)
+		}
+	}
+	return algs
+}
+
+// JWK RFC 7517 公钥
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS 公钥集合文档
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 导出全部公钥
+func (s *KeySet) JWKS() *JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc := &JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		jwk := publicJWK(k.PublicKey)
+		jwk.Kid = k.ID
+		jwk.Use = "sig"
+		jwk.Alg = k.Alg
+		doc.Keys = append(doc.Keys, jwk)
+	}
+	return doc
+}
+
+// ParseJWKS 解析 JWKS 文档得到只用于校验的密钥集合, 忽略不支持的密钥和非签名用途的密钥
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc JWKS
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	s := &KeySet{}
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		key, err := NewKey(jwk.Kid, jwk.Alg, pub)
+		if err != nil {
+			continue
+		}
+		if err := s.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func publicJWK(pub crypto.PublicKey) JWK {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: b64(k.N.Bytes()), E: b64(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return JWK{Kty: "EC", Crv: k.Curve.Params().Name, X: b64(k.X.FillBytes(make([]byte, size))), Y: b64(k.Y.FillBytes(make([]byte, size)))}
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64(k)}
+	}
+	return JWK{}
+}
+
+func (jwk JWK) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err1 := unb64(jwk.N)
+		e, err2 := unb64(jwk.E)
+		if err1 != nil || err2 != nil || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedKey
+		}
+		x, err1 := unb64(jwk.X)
+		y, err2 := unb64(jwk.Y)
+		if err1 != nil || err2 != nil {
+			return nil, ErrUnsupportedKey
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrUnsupportedKey
+		}
+		return pub, nil
+	case "OKP":
+		x, err := unb64(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// thumbprint RFC 7638 公钥指纹
+func thumbprint(pub crypto.PublicKey) string {
+	jwk := publicJWK(pub)
+	var members string
+	switch jwk.Kty {
+	case "RSA":
+		members = `{"e":"` + jwk.E + `","kty":"RSA","n":"` + jwk.N + `"}`
+	case "EC":
+		members = `{"crv":"` + jwk.Crv + `","kty":"EC","x":"` + jwk.X + `","y":"` + jwk.Y + `"}`
+	case "OKP":
+		members = `{"crv":"` + jwk.Crv + `","kty":"OKP","x":"` + jwk.X + `"}`
+	}
+	sum := sha256.Sum256([]byte(members))
+	return b64(sum[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func unb64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,263 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	p256    *ecdsa.PrivateKey
+	p384    *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	return &testKeys{rsa: rsaKey, p256: p256, p384: p384, ed25519: edKey}
+}
+
+func pemBlock(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+// mustDER 编码失败时 panic, 只用于测试数据
+func mustDER(der []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return der
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	keys := newTestKeys(t)
+	pkcs8 := func(k any) []byte { return mustDER(x509.MarshalPKCS8PrivateKey(k)) }
+	pkix := func(k any) []byte { return mustDER(x509.MarshalPKIXPublicKey(k)) }
+	cases := []struct {
+		name    string
+		pem     []byte
+		alg     string
+		private bool
+	}{
+		{"pkcs1 private", pemBlock("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(keys.rsa)), "RS256", true},
+		{"pkcs1 public", pemBlock("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&keys.rsa.PublicKey)), "RS256", false},
+		{"sec1 private", pemBlock("EC PRIVATE KEY", mustDER(x509.MarshalECPrivateKey(keys.p384))), "ES384", true},
+		{"pkcs8 ec", pemBlock("PRIVATE KEY", pkcs8(keys.p256)), "ES256", true},
+		{"pkcs8 ed25519", pemBlock("PRIVATE KEY", pkcs8(keys.ed25519)), "EdDSA", true},
+		{"pkix public", pemBlock("PUBLIC KEY", pkix(&keys.p256.PublicKey)), "ES256", false},
+		{"certificate", pemBlock("CERTIFICATE", selfSigned(t, keys.p256)), "ES256", false},
+	}
+	for _, c := range cases {
+		key, err := ParseKeyPEM("", "", c.pem)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if key.Alg != c.alg || (key.PrivateKey != nil) != c.private || key.ID == "" {
+			t.Errorf("%s: alg %s private %v kid %q, want alg %s private %v", c.name, key.Alg, key.PrivateKey != nil, key.ID, c.alg, c.private)
+		}
+	}
+
+	//私钥和对应公钥的默认 kid 为相同的指纹
+	priv, _ := ParseKeyPEM("", "", cases[0].pem)
+	pub, _ := ParseKeyPEM("", "", cases[1].pem)
+	if priv.ID != pub.ID {
+		t.Fatalf("thumbprint of the private key %q differs from the public key %q", priv.ID, pub.ID)
+	}
+	if key, _ := ParseKeyPEM("k1", "PS256", cases[0].pem); key.ID != "k1" || key.Alg != "PS256" {
+		t.Fatalf("explicit kid and alg must be kept, got %+v", key)
+	}
+
+	for _, data := range [][]byte{[]byte("not pem"), pemBlock("DSA PRIVATE KEY", []byte{1}), pemBlock("PRIVATE KEY", []byte{1})} {
+		if _, err := ParseKeyPEM("", "", data); err == nil {
+			t.Errorf("ParseKeyPEM(%q) should fail", data)
+		}
+	}
+}
+
+func selfSigned(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "msgo"}, NotAfter: time.Now().Add(time.Hour)}
+	return mustDER(x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key))
+}
+
+func TestNewKeyAlg(t *testing.T) {
+	keys := newTestKeys(t)
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	cases := []struct {
+		name string
+		alg  string
+		key  any
+		ok   bool
+	}{
+		{"rsa pss", "PS384", keys.rsa, true},
+		{"rsa with ec alg", "ES256", keys.rsa, false},
+		{"p256 with es384", "ES384", keys.p256, false},
+		{"ed25519 public", "EdDSA", keys.ed25519.Public(), true},
+		{"ed25519 with rs256", "RS256", keys.ed25519, false},
+		{"unsupported curve", "", p224, false},
+		{"unsupported type", "", []byte("secret"), false},
+	}
+	for _, c := range cases {
+		if _, err := NewKey("", c.alg, c.key); (err == nil) != c.ok {
+			t.Errorf("%s: NewKey error %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+	if _, err := NewKey("", "", []byte("secret")); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("expected ErrUnsupportedKey, got %v", err)
+	}
+}
+
+func newKeySet(t *testing.T, keys ...crypto.Signer) *KeySet {
+	t.Helper()
+	set := &KeySet{}
+	for i, k := range keys {
+		key, err := NewKey("k"+string(rune('1'+i)), "", k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Add(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return set
+}
+
+func signWith(t *testing.T, set *KeySet) string {
+	t.Helper()
+	j := &JwtHandler{KeySet: set}
+	if err := j.init(); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := j.sign(validClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	keys := newTestKeys(t)
+	set := newKeySet(t, keys.rsa, keys.p384, keys.ed25519)
+	data, err := json.Marshal(set.JWKS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"d"`) {
+		t.Fatal("JWKS must not contain private key material")
+	}
+	parsed, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parsed.Current(); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("keys from JWKS can only verify, got %v", err)
+	}
+	for _, kid := range []string{"k1", "k2", "k3"} {
+		orig, _ := set.Lookup(kid)
+		got, err := parsed.Lookup(kid)
+		if err != nil || got.Alg != orig.Alg || !got.PublicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(orig.PublicKey) {
+			t.Fatalf("kid %s did not round trip: %+v, %v", kid, got, err)
+		}
+		//只用该密钥签名, 由解析得到的公钥集合校验
+		signer := &KeySet{}
+		_ = signer.Add(orig)
+		verifier := &JwtHandler{KeySet: parsed, TimeFun: func() time.Time { return testNow }}
+		if res := serveAuth(verifier, bearerRequest(signWith(t, signer)), true); !res.called {
+			t.Fatalf("token signed with %s (%s) was rejected: %v", kid, orig.Alg, res.err)
+		}
+	}
+
+	//非签名用途和不支持的密钥被忽略
+	doc := `{"keys":[{"kty":"RSA","use":"enc","kid":"e","n":"AQAB","e":"AQAB"},{"kty":"oct","kid":"o"},{"kty":"EC","crv":"P-256","kid":"bad","x":"AQ","y":"AQ"}]}`
+	if s, err := ParseJWKS([]byte(doc)); err != nil || len(s.Algs()) != 0 {
+		t.Fatalf("unexpected keys from %s: %v", doc, err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys := newTestKeys(t)
+	set := newKeySet(t, keys.p256)
+	j := &JwtHandler{KeySet: set, TimeFun: func() time.Time { return testNow }}
+	old := signWith(t, set)
+
+	next, _ := NewKey("k2", "", keys.ed25519)
+	if err := set.Add(next); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Add(next); err == nil {
+		t.Fatal("duplicate kid must be rejected")
+	}
+	if err := set.SetCurrent("k2"); err != nil {
+		t.Fatal(err)
+	}
+	rotated := signWith(t, set)
+	parsed, _, err := jwt.NewParser().ParseUnverified(rotated, jwt.MapClaims{})
+	if err != nil || parsed.Header["kid"] != "k2" || parsed.Header["alg"] != "EdDSA" {
+		t.Fatalf("token must be signed with the current key, header %v, %v", parsed.Header, err)
+	}
+	for _, tok := range []string{old, rotated} {
+		if res := serveAuth(j, bearerRequest(tok), true); !res.called {
+			t.Fatalf("tokens of both keys must verify during rotation: %v", res.err)
+		}
+	}
+
+	set.Remove("k1")
+	if res := serveAuth(j, bearerRequest(old), true); res.called || !errors.Is(res.err, ErrTokenInvalid) {
+		t.Fatalf("token of a removed key must be rejected, called=%v err=%v", res.called, res.err)
+	}
+
+	pub, _ := NewKey("k3", "", keys.rsa.Public())
+	_ = set.Add(pub)
+	if err := set.SetCurrent("k3"); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("public-only key can not sign, got %v", err)
+	}
+	if err := set.SetCurrent("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	set.Remove("k2")
+	if _, err := set.Current(); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("removing the current key must clear it, got %v", err)
+	}
+}
+
+func TestSignTokenSymmetric(t *testing.T) {
+	j := newTestHandler()
+	if err := j.init(); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := j.sign(validClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, _ := jwt.NewParser().ParseUnverified(tok, jwt.MapClaims{})
+	if parsed.Header["alg"] != "HS256" || parsed.Header["kid"] != nil {
+		t.Fatalf("unexpected header %v", parsed.Header)
+	}
+	j.Alg = "HS999"
+	if _, err := j.sign(validClaims()); err == nil {
+		t.Fatal("unknown alg must fail")
+	}
+}
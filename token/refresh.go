@@ -0,0 +1,163 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/mserror"
+)
+
+// 刷新令牌相关的 claim
+const (
+	TypeClaim   = "typ"
+	FamilyClaim = "fid"
+
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+var (
+	ErrRefreshTokenReused  = mserror.Register(40108, http.StatusUnauthorized, "refresh token is reused")
+	ErrRefreshTokenRevoked = mserror.Register(40109, http.StatusUnauthorized, "refresh token is revoked")
+)
+
+// RefreshToken 已签发的刷新令牌, Family 为同一次登录轮换得到的全部令牌共用的 ID
+type RefreshToken struct {
+	ID        string
+	Family    string
+	Subject   string
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
+
+// RefreshStore 刷新令牌存储, 用于一次性使用、重用检测和服务端吊销
+type RefreshStore interface {
+	// Save 保存新签发的刷新令牌
+	Save(ctx context.Context, t *RefreshToken) error
+	// Use 原子地把令牌标记为已使用, 已使用返回 ErrRefreshTokenReused, 已吊销或不存在返回 ErrRefreshTokenRevoked
+	Use(ctx context.Context, id string) error
+	// RevokeFamily 吊销一次登录轮换得到的全部令牌
+	RevokeFamily(ctx context.Context, family string) error
+	// RevokeSubject 吊销用户的全部令牌, 用于在所有设备退出登录
+	RevokeSubject(ctx context.Context, subject string) error
+	// FamilyRevoked 登录是否已被吊销
+	FamilyRevoked(ctx context.Context, family string) (bool, error)
+}
+
+// newTokenID 随机 128 位 ID
+func newTokenID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MemoryRefreshStore 内存刷新令牌存储, 过期令牌在写入时清理, 适合单实例部署
+type MemoryRefreshStore struct {
+	mu      sync.Mutex
+	tokens  map[string]*RefreshToken
+	revoked map[string]time.Time
+	//RevokeTTL 吊销记录至少保留的时间, 默认 7 天, 不能短于访问令牌的有效期,
+	//否则刷新令牌已过期的登录在访问令牌过期前就会丢失吊销记录
+	RevokeTTL time.Duration
+	TimeFun   func() time.Time
+}
+
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		tokens:    make(map[string]*RefreshToken),
+		revoked:   make(map[string]time.Time),
+		RevokeTTL: 7 * 24 * time.Hour,
+		TimeFun:   time.Now,
+	}
+}
+
+func (s *MemoryRefreshStore) Save(ctx context.Context, t *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	c := *t
+	s.tokens[t.ID] = &c
+	return nil
+}
+
+func (s *MemoryRefreshStore) Use(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok || t.Revoked {
+		return ErrRefreshTokenRevoked
+	}
+	if _, ok := s.revoked[t.Family]; ok {
+		return ErrRefreshTokenRevoked
+	}
+	if t.Used {
+		return ErrRefreshTokenReused
+	}
+	t.Used = true
+	return nil
+}
+
+func (s *MemoryRefreshStore) RevokeFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires := time.Time{}
+	for _, t := range s.tokens {
+		if t.Family == family {
+			t.Revoked = true
+			if t.ExpiresAt.After(expires) {
+				expires = t.ExpiresAt
+			}
+		}
+	}
+	s.revoke(family, expires)
+	return nil
+}
+
+func (s *MemoryRefreshStore) RevokeSubject(ctx context.Context, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.Subject == subject {
+			t.Revoked = true
+			s.revoke(t.Family, t.ExpiresAt)
+		}
+	}
+	return nil
+}
+
+// revoke 记录吊销, 保留到令牌过期且至少 RevokeTTL, 调用方持有锁
+func (s *MemoryRefreshStore) revoke(family string, expires time.Time) {
+	if least := s.TimeFun().Add(s.RevokeTTL); expires.Before(least) {
+		expires = least
+	}
+	if expires.After(s.revoked[family]) {
+		s.revoked[family] = expires
+	}
+}
+
+func (s *MemoryRefreshStore) FamilyRevoked(ctx context.Context, family string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[family]
+	return ok, nil
+}
+
+// expire 清理过期的令牌和吊销记录, 调用方持有锁
+func (s *MemoryRefreshStore) expire() {
+	now := s.TimeFun()
+	for id, t := range s.tokens {
+		if now.After(t.ExpiresAt) {
+			delete(s.tokens, id)
+		}
+	}
+	for family, expires := range s.revoked {
+		if now.After(expires) {
+			delete(s.revoked, family)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package token
+
+import (
+	"context"
+	"time"
+
+	"github.com/liyuanwu2020/msgo/orm"
+)
+
+// OrmRefreshStore 数据库刷新令牌存储, 适合多实例部署, 表结构:
+//
+//	create table ms_refresh_token (
+//		id         varchar(64)  not null primary key,
+//		family     varchar(64)  not null,
+//		subject    varchar(128) not null,
+//		expires_at bigint       not null,
+//		used       tinyint      not null default 0,
+//		revoked    tinyint      not null default 0
+//	);
+//	create index idx_ms_refresh_token_family on ms_refresh_token (family);
+//	create index idx_ms_refresh_token_subject on ms_refresh_token (subject);
+type OrmRefreshStore struct {
+	db    *orm.MsDb
+	table string
+	//RevokeTTL 吊销时没有未过期的令牌, 吊销记录保留的时间, 默认 7 天, 不能短于访问令牌的有效期
+	RevokeTTL time.Duration
+}
+
+// NewOrmRefreshStore table 为空时使用 ms_refresh_token, 表名会加上 db 的前缀
+func NewOrmRefreshStore(db *orm.MsDb, table string) *OrmRefreshStore {
+	if table == "" {
+		table = "ms_refresh_token"
+	}
+	return &OrmRefreshStore{db: db, table: db.Prefix + table, RevokeTTL: 7 * 24 * time.Hour}
+}
+
+func (s *OrmRefreshStore) session(ctx context.Context) *orm.MsSession {
+	return s.db.New().Table(s.table).WithContext(ctx)
+}
+
+// Save 保存令牌并清理已过期的令牌
+func (s *OrmRefreshStore) Save(ctx context.Context, t *RefreshToken) error {
+	if _, err := s.session(ctx).Exec("delete from "+s.table+" where expires_at < ?", time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := s.session(ctx).Exec("insert into "+s.table+" (id, family, subject, expires_at, used, revoked) values (?, ?, ?, ?, 0, 0)",
+		t.ID, t.Family, t.Subject, t.ExpiresAt.Unix())
+	return err
+}
+
+func (s *OrmRefreshStore) Use(ctx context.Context, id string) error {
+	n, err := s.session(ctx).Exec("update "+s.table+" set used = 1 where id = ? and used = 0 and revoked = 0", id)
+	if err != nil {
+		return err
+	}
+	if n == 1 {
+		return nil
+	}
+	used, err := s.session(ctx).Where("id", id).Where("used", 1).Where("revoked", 0).Count()
+	if err != nil {
+		return err
+	}
+	if used > 0 {
+		return ErrRefreshTokenReused
+	}
+	return ErrRefreshTokenRevoked
+}
+
+func (s *OrmRefreshStore) RevokeFamily(ctx context.Context, family string) error {
+	n, err := s.session(ctx).Exec("update "+s.table+" set revoked = 1 where family = ?", family)
+	if err != nil || n > 0 {
+		return err
+	}
+	//令牌已过期被清理时写入一条已吊销的记录, 该登录仍在有效期内的访问令牌不能再通过校验
+	_, err = s.session(ctx).Exec("insert into "+s.table+" (id, family, subject, expires_at, used, revoked) values (?, ?, '', ?, 1, 1)",
+		"revoked:"+family, family, time.Now().Add(s.RevokeTTL).Unix())
+	return err
+}
+
+func (s *OrmRefreshStore) RevokeSubject(ctx context.Context, subject string) error {
+	_, err := s.session(ctx).Exec("update "+s.table+" set revoked = 1 where subject = ?", subject)
+	return err
+}
+
+func (s *OrmRefreshStore) FamilyRevoked(ctx context.Context, family string) (bool, error) {
+	n, err := s.session(ctx).Where("family", family).Where("revoked", 1).Count()
+	return n > 0, err
+}
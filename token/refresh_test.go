@@ -0,0 +1,197 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/liyuanwu2020/msgo"
+)
+
+// newMemoryStore 与 newTestHandler 使用相同的时钟
+func newMemoryStore() *MemoryRefreshStore {
+	s := NewMemoryRefreshStore()
+	s.TimeFun = func() time.Time { return testNow }
+	return s
+}
+
+func newRefreshHandler(store RefreshStore) *JwtHandler {
+	j := newTestHandler()
+	j.RefreshStore = store
+	j.CheckRevoked = true
+	j.Authenticator = func(ctx *msgo.Context) (map[string]any, error) {
+		return map[string]any{"sub": "alice"}, nil
+	}
+	return j
+}
+
+// refresh 通过路由使用 X-Refresh-Token 请求头调用 RefreshTokenHandler
+func refresh(j *JwtHandler, refreshToken string) (*JwtResponse, error) {
+	var rsp *JwtResponse
+	var err error
+	e := msgo.Default()
+	e.Group("auth").Post("/refresh", func(ctx *msgo.Context) {
+		rsp, err = j.RefreshTokenHandler(ctx)
+	})
+	r := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+	r.Header.Set("X-Refresh-Token", refreshToken)
+	e.ServeHTTP(httptest.NewRecorder(), r)
+	return rsp, err
+}
+
+func TestRefreshRotation(t *testing.T) {
+	j := newRefreshHandler(newMemoryStore())
+	first := login(t, j)
+	second, err := refresh(j, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh must rotate the refresh token")
+	}
+	res := serveAuth(j, bearerRequest(second.Token), true)
+	if !res.called || res.claims["sub"] != "alice" || res.claims[FamilyClaim] == "" {
+		t.Fatalf("rotated access token: called=%v claims=%v err=%v", res.called, res.claims, res.err)
+	}
+	third, err := refresh(j, second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refresh(j, first.Token); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("access token used as refresh token must fail, got %v", err)
+	}
+
+	//已使用的刷新令牌再次出现时吊销整个登录, 包括最新签发的令牌
+	if _, err := refresh(j, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused refresh token: got %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := refresh(j, third.RefreshToken); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Fatalf("latest refresh token after reuse: got %v, want ErrRefreshTokenRevoked", err)
+	}
+	if res := serveAuth(j, bearerRequest(third.Token), true); res.called || !errors.Is(res.err, ErrRefreshTokenRevoked) {
+		t.Fatalf("access token of a revoked login: called=%v err=%v", res.called, res.err)
+	}
+}
+
+func TestRefreshWithoutStore(t *testing.T) {
+	j := newRefreshHandler(nil)
+	first := login(t, j)
+	for i := 0; i < 2; i++ {
+		if _, err := refresh(j, first.RefreshToken); err != nil {
+			t.Fatalf("without a store refresh tokens are only checked by signature, got %v", err)
+		}
+	}
+}
+
+func TestLogoutRevokesLogin(t *testing.T) {
+	j := newRefreshHandler(newMemoryStore())
+	phone, laptop := login(t, j), login(t, j)
+
+	logout := func(handler func(ctx *msgo.Context) error, access string) error {
+		var err error
+		e := msgo.Default()
+		e.Group("auth").Post("/logout", func(ctx *msgo.Context) { err = handler(ctx) }, j.AuthInterceptor)
+		r := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+		r.Header.Set("Authorization", "Bearer "+access)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		if w.Code == http.StatusUnauthorized {
+			return ErrTokenInvalid
+		}
+		return err
+	}
+	if err := logout(j.LogoutHandler, phone.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refresh(j, phone.RefreshToken); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Fatalf("refresh after logout: got %v", err)
+	}
+	if _, err := refresh(j, laptop.RefreshToken); err != nil {
+		t.Fatalf("logout must only revoke the current login, got %v", err)
+	}
+
+	tablet := login(t, j)
+	if err := logout(j.LogoutAllHandler, tablet.Token); err != nil {
+		t.Fatal(err)
+	}
+	for _, rsp := range []*JwtResponse{laptop, tablet} {
+		if res := serveAuth(j, bearerRequest(rsp.Token), true); res.called {
+			t.Fatal("logout from all devices must revoke every login of the subject")
+		}
+	}
+}
+
+func TestMemoryRefreshStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	s := NewMemoryRefreshStore()
+	s.TimeFun = func() time.Time { return now }
+	s.RevokeTTL = time.Minute
+	_ = s.Save(ctx, &RefreshToken{ID: "a", Family: "f1", Subject: "alice", ExpiresAt: now.Add(time.Minute)})
+	_ = s.Save(ctx, &RefreshToken{ID: "b", Family: "f2", Subject: "bob", ExpiresAt: now.Add(time.Hour)})
+
+	if err := s.Use(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Use(ctx, "a"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("second use: got %v", err)
+	}
+	if err := s.Use(ctx, "missing"); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Fatalf("unknown token: got %v", err)
+	}
+	_ = s.RevokeSubject(ctx, "bob")
+	if revoked, _ := s.FamilyRevoked(ctx, "f2"); !revoked {
+		t.Fatal("revoking a subject must revoke its families")
+	}
+	if err := s.Use(ctx, "b"); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Fatalf("revoked token: got %v", err)
+	}
+	_ = s.RevokeFamily(ctx, "f1")
+
+	//过期的令牌和吊销记录在下一次写入时清理
+	now = now.Add(2 * time.Minute)
+	_ = s.Save(ctx, &RefreshToken{ID: "c", Family: "f3", ExpiresAt: now.Add(time.Hour)})
+	if _, ok := s.tokens["a"]; ok {
+		t.Fatal("expired token must be removed")
+	}
+	if revoked, _ := s.FamilyRevoked(ctx, "f1"); revoked {
+		t.Fatal("revocation of an expired family must be removed")
+	}
+	if revoked, _ := s.FamilyRevoked(ctx, "f2"); !revoked {
+		t.Fatal("revocation must be kept until the family expires")
+	}
+}
+
+func TestMemoryRefreshStoreRevokeAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	s := NewMemoryRefreshStore()
+	s.TimeFun = func() time.Time { return now }
+	s.RevokeTTL = time.Hour
+	_ = s.Save(ctx, &RefreshToken{ID: "a", Family: "f1", Subject: "alice", ExpiresAt: now.Add(time.Minute)})
+
+	//刷新令牌过期并被清理后退出登录, 访问令牌可能仍在有效期内
+	now = now.Add(2 * time.Minute)
+	_ = s.Save(ctx, &RefreshToken{ID: "b", Family: "f2", Subject: "bob", ExpiresAt: now.Add(time.Minute)})
+	if _, ok := s.tokens["a"]; ok {
+		t.Fatal("expired token must be removed")
+	}
+	_ = s.RevokeFamily(ctx, "f1")
+	_ = s.RevokeSubject(ctx, "bob")
+
+	now = now.Add(30 * time.Minute)
+	_ = s.Save(ctx, &RefreshToken{ID: "c", Family: "f3", ExpiresAt: now.Add(time.Hour)})
+	for _, family := range []string{"f1", "f2"} {
+		if revoked, _ := s.FamilyRevoked(ctx, family); !revoked {
+			t.Fatalf("revocation of %s must be kept for RevokeTTL", family)
+		}
+	}
+	now = now.Add(time.Hour)
+	_ = s.Save(ctx, &RefreshToken{ID: "d", Family: "f4", ExpiresAt: now.Add(time.Hour)})
+	if revoked, _ := s.FamilyRevoked(ctx, "f1"); revoked {
+		t.Fatal("revocation must be removed after RevokeTTL")
+	}
+}
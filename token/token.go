@@ -2,31 +2,80 @@ package token
 
 import (
 	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/liyuanwu2020/msgo"
+	"github.com/liyuanwu2020/msgo/mserror"
+	"github.com/liyuanwu2020/msgo/sessions"
 	"net/http"
+	"sync"
 	"time"
 )
 
 type JwtHandler struct {
-	Alg            string
-	Timeout        time.Duration
+	Alg     string
+	Timeout time.Duration
+	//RefreshTimeout 刷新令牌有效期, 默认 7 天
 	RefreshTimeout time.Duration
 	TimeFun        func() time.Time
 	Key            []byte
-	PrivateKey     string
-	RefreshKey     string
+	//PrivateKey PEM 格式私钥, 设置后使用非对称算法签名, Alg 为空时根据密钥推断
+	PrivateKey string
+	//PublicKey PEM 格式公钥, 只校验 token 不签发时使用
+	PublicKey string
+	//KeySet 多密钥轮换时使用, 设置后忽略 PrivateKey 和 PublicKey
+	KeySet *KeySet
+	//RefreshKey 刷新令牌在 ctx.Keys 中的键, 设置且存在时优先于 RefreshTokenLookup
+	RefreshKey string
+	//RefreshTokenLookup 刷新令牌查找顺序, 默认 "form:refresh_token,header:X-Refresh-Token", SendCookie 时再查找 cookie
+	RefreshTokenLookup string
+	//RefreshCookieName 刷新令牌 cookie 名称, 默认 refresh_token
+	RefreshCookieName string
+	//RefreshStore 设置后刷新令牌只能使用一次, 重复使用时吊销整个登录, 并支持服务端退出登录
+	RefreshStore RefreshStore
+	//CheckRevoked 每次请求校验 token 所属登录是否已吊销, 需要 RefreshStore
+	CheckRevoked   bool
 	SendCookie     bool
 	CookieName     string
 	CookieMaxAge   int
 	CookieDomain   string
 	SecureCookie   bool
 	CookieHTTPOnly bool
-	Authenticator  func(ctx *msgo.Context) (map[string]any, error)
-	Header         string
-	AuthHandler    func(ctx *msgo.Context, err error)
+	//Authenticator 登录校验, 返回写入 token 的业务 claim, 返回类型化 claims 时使用 Authenticate 转换
+	Authenticator func(ctx *msgo.Context) (map[string]any, error)
+	//ClaimsFactory 返回新的类型化 claims 指针, 设置后 AuthInterceptor 把 token 解码到其中, 通过 ClaimsFrom 读取
+	ClaimsFactory func() jwt.Claims
+	Header        string
+	//TokenHeadName 请求头中 token 的认证方案, 默认 Bearer
+	TokenHeadName string
+	//TokenLookup token 查找顺序, 如 "header:Authorization,query:token,cookie:jwt_token",
+	//默认先查找 Header 请求头, SendCookie 时再查找 cookie
+	TokenLookup string
+	//Issuer 非空时签发的 token 写入 iss, 校验时 iss 必须一致
+	Issuer string
+	//Audience 非空时签发的 token 写入 aud, 校验时 aud 必须包含其中之一
+	Audience []string
+	//Leeway 校验 exp、nbf、iat 时允许的时钟偏差
+	Leeway time.Duration
+	//AuthHandler 认证失败时调用, 默认返回 401
+	AuthHandler func(ctx *msgo.Context, err error)
+	//Session 登录时绑定服务端会话, 会话注销或吊销后 token 随之失效
+	Session bool
+	//CookieMode token cookie 的保护方式, 签名和加密需要 Engine.SetCookieKeys 配置密钥
+	CookieMode CookieMode
+
+	initOnce sync.Once
+	initErr  error
 }
 
+type CookieMode int
+
+const (
+	CookiePlain CookieMode = iota
+	CookieSigned
+	CookieEncrypted
+)
+
 type JwtResponse struct {
 	Token        string
 	RefreshToken string
@@ -34,197 +83,450 @@ type JwtResponse struct {
 
 const JWTToken = "jwt_token"
 
+const SessionClaim = "sid"
+
+// 认证失败的错误码, 均返回 401
+var (
+	ErrTokenMissing         = mserror.Register(40101, http.StatusUnauthorized, "token is missing")
+	ErrTokenInvalid         = mserror.Register(40102, http.StatusUnauthorized, "token is invalid")
+	ErrTokenExpired         = mserror.Register(40103, http.StatusUnauthorized, "token is expired")
+	ErrTokenNotValidYet     = mserror.Register(40104, http.StatusUnauthorized, "token is not valid yet")
+	ErrTokenIssuerInvalid   = mserror.Register(40105, http.StatusUnauthorized, "token issuer is invalid")
+	ErrTokenAudienceInvalid = mserror.Register(40106, http.StatusUnauthorized, "token audience is invalid")
+	ErrSessionRevoked       = mserror.Register(40107, http.StatusUnauthorized, "session is revoked")
+)
+
+// LogoutHandler 清除 cookie 和会话, 配置 RefreshStore 时同时在服务端吊销本次登录
 func (j *JwtHandler) LogoutHandler(ctx *msgo.Context) error {
-	//清除cookie即可
+	if err := j.init(); err != nil {
+		return err
+	}
+	if j.RefreshStore != nil {
+		if family := j.requestFamily(ctx); family != "" {
+			if err := j.RefreshStore.RevokeFamily(ctx.R.Context(), family); err != nil {
+				return err
+			}
+		}
+	}
 	if j.SendCookie {
-		if j.CookieName == "" {
-			j.CookieName = JWTToken
+		if err := j.setCookie(ctx, j.CookieName, "", -1); err != nil {
+			return err
+		}
+		if err := j.setCookie(ctx, j.RefreshCookieName, "", -1); err != nil {
+			return err
+		}
+	}
+	if j.Session {
+		if s := ctx.Session(); s != nil {
+			s.Destroy()
+			return ctx.SaveSession()
 		}
-		ctx.SetCookie(j.CookieName, "", -1, "/", j.CookieDomain, j.SecureCookie, j.CookieHTTPOnly)
 	}
 	return nil
 }
 
+// LogoutAllHandler 吊销当前用户在所有设备上的登录, 用户由 AuthInterceptor 写入的 claims 中的 sub 确定
+func (j *JwtHandler) LogoutAllHandler(ctx *msgo.Context) error {
+	if j.RefreshStore == nil {
+		return errors.New("token: RefreshStore is not configured")
+	}
+	claims, _ := ctx.Get(ClaimsKey)
+	mc, _ := claims.(jwt.MapClaims)
+	sub, _ := mc["sub"].(string)
+	if sub == "" {
+		return ErrTokenInvalid.WithMessage("token has no sub claim")
+	}
+	if err := j.RefreshStore.RevokeSubject(ctx.R.Context(), sub); err != nil {
+		return err
+	}
+	return j.LogoutHandler(ctx)
+}
+
+// requestFamily 本次登录的 family, 优先取 AuthInterceptor 写入的 claims, 其次取请求中的刷新令牌
+func (j *JwtHandler) requestFamily(ctx *msgo.Context) string {
+	if claims, ok := ctx.Get(ClaimsKey); ok {
+		if mc, ok := claims.(jwt.MapClaims); ok {
+			if family, _ := mc[FamilyClaim].(string); family != "" {
+				return family
+			}
+		}
+	}
+	tokenStr, err := j.refreshTokenString(ctx)
+	if err != nil {
+		return ""
+	}
+	t, err := j.parseToken(tokenStr)
+	if err != nil {
+		return ""
+	}
+	family, _ := t.Claims.(jwt.MapClaims)[FamilyClaim].(string)
+	return family
+}
+
+func (j *JwtHandler) setCookie(ctx *msgo.Context, name, value string, maxAge int) error {
+	switch j.CookieMode {
+	case CookieSigned:
+		return ctx.SetSignedCookie(name, value, maxAge, "/", j.CookieDomain, j.SecureCookie, j.CookieHTTPOnly)
+	case CookieEncrypted:
+		return ctx.SetEncryptedCookie(name, value, maxAge, "/", j.CookieDomain, j.SecureCookie, j.CookieHTTPOnly)
+	}
+	ctx.SetCookie(name, value, maxAge, "/", j.CookieDomain, j.SecureCookie, j.CookieHTTPOnly)
+	return nil
+}
+
+func (j *JwtHandler) getNamedCookie(ctx *msgo.Context, name string) (string, error) {
+	switch j.CookieMode {
+	case CookieSigned:
+		return ctx.GetSignedCookie(name)
+	case CookieEncrypted:
+		return ctx.GetEncryptedCookie(name)
+	}
+	return ctx.GetCookie(name)
+}
+
+// bindSession 登录成功后重新生成会话 ID, 并写入 token 的 sid
+func (j *JwtHandler) bindSession(ctx *msgo.Context, claims jwt.MapClaims) error {
+	s := ctx.Session()
+	if s == nil {
+		return errors.New("session store is not configured")
+	}
+	s.Regenerate()
+	claims[SessionClaim] = s.ID
+	return ctx.SaveSession()
+}
+
+// sessionActive token 绑定的会话是否仍然有效
+func (j *JwtHandler) sessionActive(ctx *msgo.Context, claims jwt.MapClaims) bool {
+	sid, _ := claims[SessionClaim].(string)
+	if sid == "" {
+		return false
+	}
+	if r, ok := ctx.SessionStore().(sessions.Revoker); ok {
+		exists, err := r.Exists(sid)
+		return err == nil && exists
+	}
+	s := ctx.Session()
+	return s != nil && !s.IsNew && s.ID == sid
+}
+
 func (j *JwtHandler) LoginHandler(ctx *msgo.Context) (*JwtResponse, error) {
 	data, err := j.Authenticator(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if j.Alg == "" {
-		j.Alg = "HS256"
+	if err := j.init(); err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	for k, v := range data {
+		claims[k] = v
 	}
-	if j.TimeFun == nil {
-		j.TimeFun = func() time.Time {
-			return time.Now()
+	if j.Session {
+		if err := j.bindSession(ctx, claims); err != nil {
+			return nil, err
 		}
 	}
-	signingMethod := jwt.GetSigningMethod(j.Alg)
-	token := jwt.New(signingMethod)
-	claims := token.Claims.(jwt.MapClaims)
-	if data != nil {
-		for k, v := range data {
-			claims[k] = v
+	return j.issue(ctx, claims, newTokenID())
+}
+
+// reservedClaims 签发时重新生成的 claim
+var reservedClaims = []string{"exp", "iat", "nbf", "jti", TypeClaim, FamilyClaim}
+
+// issue 签发 access token 和刷新令牌, 同一次登录轮换得到的令牌共用 family
+func (j *JwtHandler) issue(ctx *msgo.Context, base jwt.MapClaims, family string) (*JwtResponse, error) {
+	now := j.TimeFun()
+	access := j.newClaims(base, now, now.Add(j.Timeout))
+	access[TypeClaim] = TypeAccess
+	if j.RefreshStore != nil {
+		access[FamilyClaim] = family
+	}
+	tokenStr, err := j.sign(access)
+	if err != nil {
+		return nil, err
+	}
+	refreshExpire := now.Add(j.RefreshTimeout)
+	refresh := j.newClaims(base, now, refreshExpire)
+	refresh[TypeClaim] = TypeRefresh
+	refresh[FamilyClaim] = family
+	refresh["jti"] = newTokenID()
+	refreshTokenStr, err := j.sign(refresh)
+	if err != nil {
+		return nil, err
+	}
+	if j.RefreshStore != nil {
+		sub, _ := base["sub"].(string)
+		rt := &RefreshToken{ID: refresh["jti"].(string), Family: family, Subject: sub, ExpiresAt: refreshExpire}
+		if err := j.RefreshStore.Save(ctx.R.Context(), rt); err != nil {
+			return nil, err
 		}
 	}
-	expire := j.TimeFun().Add(j.Timeout)
-	//
-	claims["exp"] = expire.Unix()
-	claims["iat"] = j.TimeFun().Unix()
-	var tokenStr string
-	var tokenErr error
-	if j.usingPublicKeyAlgo() {
-		tokenStr, tokenErr = token.SignedString(j.PrivateKey)
-	} else {
-		tokenStr, tokenErr = token.SignedString(j.Key)
-	}
-	if tokenErr != nil {
-		return nil, tokenErr
-	}
-	refreshTokenStr, tokenErr := j.refreshToken(token)
-	if tokenErr != nil {
-		return nil, tokenErr
-	}
-	req := &JwtResponse{
+	if j.SendCookie {
+		maxAge := j.CookieMaxAge
+		if maxAge == 0 {
+			maxAge = int(j.Timeout.Seconds())
+		}
+		if err := j.setCookie(ctx, j.CookieName, tokenStr, maxAge); err != nil {
+			return nil, err
+		}
+		if err := j.setCookie(ctx, j.RefreshCookieName, refreshTokenStr, int(j.RefreshTimeout.Seconds())); err != nil {
+			return nil, err
+		}
+	}
+	return &JwtResponse{
 		Token:        tokenStr,
 		RefreshToken: refreshTokenStr,
+	}, nil
+}
+
+// newClaims 复制业务 claim 并写入 exp、iat、iss、aud
+func (j *JwtHandler) newClaims(base jwt.MapClaims, now, expire time.Time) jwt.MapClaims {
+	claims := make(jwt.MapClaims, len(base)+4)
+	for k, v := range base {
+		claims[k] = v
 	}
-	if j.SendCookie {
+	for _, k := range reservedClaims {
+		delete(claims, k)
+	}
+	claims["exp"] = expire.Unix()
+	claims["iat"] = now.Unix()
+	if j.Issuer != "" {
+		claims["iss"] = j.Issuer
+	}
+	if len(j.Audience) == 1 {
+		claims["aud"] = j.Audience[0]
+	} else if len(j.Audience) > 1 {
+		claims["aud"] = j.Audience
+	}
+	return claims
+}
+
+func (j *JwtHandler) sign(claims jwt.MapClaims) (string, error) {
+	signingMethod := jwt.GetSigningMethod(j.Alg)
+	if signingMethod == nil {
+		return "", fmt.Errorf("token: unknown alg %q", j.Alg)
+	}
+	return j.signToken(jwt.NewWithClaims(signingMethod, claims))
+}
+
+// init 补全默认配置并加载密钥, 非对称算法未指定 Alg 时使用当前签名密钥的算法
+func (j *JwtHandler) init() error {
+	j.initOnce.Do(func() {
+		if j.TimeFun == nil {
+			j.TimeFun = time.Now
+		}
+		if j.Header == "" {
+			j.Header = "Authorization"
+		}
+		if j.TokenHeadName == "" {
+			j.TokenHeadName = "Bearer"
+		}
 		if j.CookieName == "" {
 			j.CookieName = JWTToken
 		}
-		if j.CookieMaxAge == 0 {
-			j.CookieMaxAge = int(expire.Unix() - j.TimeFun().Unix())
+		if j.RefreshCookieName == "" {
+			j.RefreshCookieName = "refresh_token"
 		}
-		maxAge := j.CookieMaxAge
-		ctx.SetCookie(j.CookieName, tokenStr, maxAge, "/", j.CookieDomain, j.SecureCookie, j.CookieHTTPOnly)
-	}
-	return req, nil
+		if j.RefreshTimeout <= 0 {
+			j.RefreshTimeout = 7 * 24 * time.Hour
+		}
+		if !j.usingPublicKeyAlgo() {
+			if j.Alg == "" {
+				j.Alg = "HS256"
+			}
+			return
+		}
+		if j.KeySet == nil {
+			j.KeySet, j.initErr = j.loadKeys()
+			if j.initErr != nil {
+				return
+			}
+		}
+		if j.Alg == "" {
+			if key, err := j.KeySet.Current(); err == nil {
+				j.Alg = key.Alg
+			} else if algs := j.KeySet.Algs(); len(algs) > 0 {
+				j.Alg = algs[0]
+			}
+		}
+	})
+	return j.initErr
+}
 
+// loadKeys 从 PrivateKey 或 PublicKey 加载密钥
+func (j *JwtHandler) loadKeys() (*KeySet, error) {
+	switch {
+	case j.PrivateKey != "":
+		key, err := ParseKeyPEM("", j.Alg, []byte(j.PrivateKey))
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySet(key)
+	case j.PublicKey != "":
+		key, err := ParseKeyPEM("", j.Alg, []byte(j.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySet(key)
+	}
+	return nil, ErrNoSigningKey
 }
 
 func (j *JwtHandler) usingPublicKeyAlgo() bool {
+	if j.KeySet != nil || j.PrivateKey != "" || j.PublicKey != "" {
+		return true
+	}
 	switch j.Alg {
-	case "RS256", "RS512", "RS384":
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA":
 		return true
 	}
 	return false
 }
 
-func (j *JwtHandler) refreshToken(token *jwt.Token) (string, error) {
-	claims := token.Claims.(jwt.MapClaims)
-	expire := j.TimeFun().Add(j.Timeout)
-	claims["exp"] = expire.Unix()
-	var tokenStr string
-	var tokenErr error
-	if j.usingPublicKeyAlgo() {
-		tokenStr, tokenErr = token.SignedString(j.PrivateKey)
-	} else {
-		tokenStr, tokenErr = token.SignedString(j.Key)
+// signToken 对称算法使用 Key 签名, 非对称算法使用当前签名密钥并写入 kid
+func (j *JwtHandler) signToken(token *jwt.Token) (string, error) {
+	if !j.usingPublicKeyAlgo() {
+		return token.SignedString(j.Key)
+	}
+	key, err := j.KeySet.Current()
+	if err != nil {
+		return "", err
 	}
-	if tokenErr != nil {
-		return "", tokenErr
+	token.Method = jwt.GetSigningMethod(key.Alg)
+	token.Header["alg"] = key.Alg
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// JWKSHandler 输出公钥集合, 供其他服务校验本服务签发的 token
+func (j *JwtHandler) JWKSHandler(ctx *msgo.Context) {
+	if err := j.init(); err != nil || j.KeySet == nil {
+		ctx.HandleWithError(http.StatusOK, nil, mserror.ErrNotFound)
+		return
 	}
-	return tokenStr, nil
+	ctx.W.Header().Set("Cache-Control", "public, max-age=300")
+	_ = ctx.JSON(http.StatusOK, j.KeySet.JWKS())
 }
 
+// RefreshTokenHandler 校验刷新令牌并轮换签发新的令牌, 配置 RefreshStore 时旧令牌立即失效, 重复使用会吊销整个登录
 func (j *JwtHandler) RefreshTokenHandler(ctx *msgo.Context) (*JwtResponse, error) {
-	rToken, ok := ctx.Get(j.RefreshKey)
-	if !ok {
-		return nil, errors.New("refresh token is null")
-	}
-	if j.Alg == "" {
-		j.Alg = "HS256"
+	if err := j.init(); err != nil {
+		return nil, err
 	}
-	if j.TimeFun == nil {
-		j.TimeFun = func() time.Time {
-			return time.Now()
-		}
+	tokenStr, err := j.refreshTokenString(ctx)
+	if err != nil {
+		return nil, err
 	}
-	//解析token
-	token, err := jwt.Parse(rToken.(string), func(token *jwt.Token) (interface{}, error) {
-		if j.usingPublicKeyAlgo() {
-			return j.PrivateKey, nil
-		} else {
-			return j.Key, nil
-		}
-	})
+	t, err := j.parseToken(tokenStr)
 	if err != nil {
 		return nil, err
 	}
-	claims := token.Claims.(jwt.MapClaims)
-	ctx.Logger.Info(claims)
-	expire := j.TimeFun().Add(j.Timeout)
-	//
-	claims["exp"] = expire.Unix()
-	claims["iat"] = j.TimeFun().Unix()
-	var tokenStr string
-	var tokenErr error
-	if j.usingPublicKeyAlgo() {
-		tokenStr, tokenErr = token.SignedString(j.PrivateKey)
-	} else {
-		tokenStr, tokenErr = token.SignedString(j.Key)
-	}
-	if tokenErr != nil {
-		return nil, tokenErr
-	}
-	refreshTokenStr, tokenErr := j.refreshToken(token)
-	if tokenErr != nil {
-		return nil, tokenErr
-	}
-	req := &JwtResponse{
-		Token:        tokenStr,
-		RefreshToken: refreshTokenStr,
+	claims := t.Claims.(jwt.MapClaims)
+	if typ, _ := claims[TypeClaim].(string); typ != TypeRefresh {
+		return nil, ErrTokenInvalid.WithMessage("token is not a refresh token")
 	}
-	if j.SendCookie {
-		if j.CookieName == "" {
-			j.CookieName = JWTToken
-		}
-		if j.CookieMaxAge == 0 {
-			j.CookieMaxAge = int(expire.Unix() - j.TimeFun().Unix())
+	family, _ := claims[FamilyClaim].(string)
+	if j.RefreshStore != nil {
+		jti, _ := claims["jti"].(string)
+		if err := j.RefreshStore.Use(ctx.R.Context(), jti); err != nil {
+			if errors.Is(err, ErrRefreshTokenReused) {
+				//已使用的令牌再次出现, 说明令牌可能已泄露, 吊销整个登录
+				if revokeErr := j.RefreshStore.RevokeFamily(ctx.R.Context(), family); revokeErr != nil {
+					return nil, revokeErr
+				}
+			}
+			return nil, err
 		}
-		maxAge := j.CookieMaxAge
-		ctx.SetCookie(j.CookieName, tokenStr, maxAge, "/", j.CookieDomain, j.SecureCookie, j.CookieHTTPOnly)
 	}
-	return req, nil
+	if j.Session && !j.sessionActive(ctx, claims) {
+		return nil, ErrSessionRevoked
+	}
+	return j.issue(ctx, claims, family)
+}
 
+// refreshTokenString 优先读取 ctx.Keys 中的 RefreshKey, 否则按 RefreshTokenLookup 查找
+func (j *JwtHandler) refreshTokenString(ctx *msgo.Context) (string, error) {
+	if j.RefreshKey != "" {
+		if v, ok := ctx.Get(j.RefreshKey); ok {
+			if tokenStr, ok := v.(string); ok && tokenStr != "" {
+				return tokenStr, nil
+			}
+		}
+	}
+	return j.lookupToken(ctx, j.refreshTokenLookups(), "")
 }
 
+// AuthInterceptor 按 TokenLookup 查找并校验 token, 任何失败都交给 AuthHandler 并中止处理链
 func (j *JwtHandler) AuthInterceptor(next msgo.HandlerFunc) msgo.HandlerFunc {
+	if err := j.init(); err != nil {
+		panic("token: " + err.Error())
+	}
+	lookups := j.tokenLookups()
 	return func(ctx *msgo.Context) {
-		if j.Header == "" {
-			j.Header = "Authorization"
+		tokenStr, err := j.lookupToken(ctx, lookups, j.TokenHeadName)
+		if err != nil {
+			j.unauthorized(ctx, err)
+			return
 		}
-		token := ctx.R.Header.Get(j.Header)
-		if token == "" {
-			if j.SendCookie {
-				token, err := ctx.GetCookie(j.CookieName)
-				if err != nil {
-					if j.AuthHandler == nil {
-						ctx.W.WriteHeader(http.StatusUnauthorized)
-					} else {
-						j.AuthHandler(ctx, nil)
-					}
-					return
-				} else {
-					//解析token
-					t, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-						if j.usingPublicKeyAlgo() {
-							return j.PrivateKey, nil
-						} else {
-							return j.Key, nil
-						}
-					})
-					if err != nil {
-						if j.AuthHandler == nil {
-							ctx.W.WriteHeader(http.StatusUnauthorized)
-						} else {
-							j.AuthHandler(ctx, nil)
-						}
-					}
-					ctx.Set("claims", t.Claims.(jwt.MapClaims))
-				}
+		t, err := j.parseToken(tokenStr)
+		if err != nil {
+			j.unauthorized(ctx, err)
+			return
+		}
+		claims := t.Claims.(jwt.MapClaims)
+		if typ, _ := claims[TypeClaim].(string); typ == TypeRefresh {
+			j.unauthorized(ctx, ErrTokenInvalid.WithMessage("refresh token can not be used for authentication"))
+			return
+		}
+		if j.Session && !j.sessionActive(ctx, claims) {
+			j.unauthorized(ctx, ErrSessionRevoked)
+			return
+		}
+		if err := j.checkRevoked(ctx, claims); err != nil {
+			j.unauthorized(ctx, err)
+			return
+		}
+		if j.ClaimsFactory != nil {
+			typed, err := j.typedClaims(claims)
+			if err != nil {
+				j.unauthorized(ctx, err)
+				return
 			}
+			ctx.Set(TypedClaimsKey, typed)
 		}
+		ctx.Set(ClaimsKey, claims)
 		next(ctx)
 	}
 }
+
+// checkRevoked CheckRevoked 时校验 token 所属登录是否已在服务端吊销
+func (j *JwtHandler) checkRevoked(ctx *msgo.Context, claims jwt.MapClaims) error {
+	if !j.CheckRevoked || j.RefreshStore == nil {
+		return nil
+	}
+	family, _ := claims[FamilyClaim].(string)
+	if family == "" {
+		return nil
+	}
+	revoked, err := j.RefreshStore.FamilyRevoked(ctx.R.Context(), family)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrRefreshTokenRevoked.WithMessage("login is revoked")
+	}
+	return nil
+}
+
+// unauthorized 认证失败, 未设置 AuthHandler 时按 HandleWithError 输出 401
+func (j *JwtHandler) unauthorized(ctx *msgo.Context, err error) {
+	if j.AuthHandler != nil {
+		j.AuthHandler(ctx, err)
+		return
+	}
+	challenge := j.TokenHeadName
+	if !errors.Is(err, ErrTokenMissing) {
+		challenge += ` error="invalid_token"`
+	}
+	ctx.W.Header().Set("WWW-Authenticate", challenge)
+	ctx.HandleWithError(http.StatusUnauthorized, nil, err)
+}
@@ -0,0 +1,270 @@
+package token
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/liyuanwu2020/msgo"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+func newTestHandler() *JwtHandler {
+	return &JwtHandler{
+		Key:     []byte("secret"),
+		Timeout: time.Hour,
+		TimeFun: func() time.Time { return testNow },
+	}
+}
+
+func signHS(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(method, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{"sub": "alice", "exp": testNow.Add(time.Hour).Unix(), "iat": testNow.Unix()}
+}
+
+// authResult 经过 AuthInterceptor 的一次请求, err 为交给 AuthHandler 的错误
+type authResult struct {
+	called bool
+	err    error
+	claims jwt.MapClaims
+	rec    *httptest.ResponseRecorder
+}
+
+// serveAuth 使用 AuthInterceptor 保护 GET /api/me, capture 为 true 时记录 AuthHandler 收到的错误
+func serveAuth(j *JwtHandler, r *http.Request, capture bool) *authResult {
+	res := &authResult{rec: httptest.NewRecorder()}
+	if capture {
+		j.AuthHandler = func(ctx *msgo.Context, err error) {
+			res.err = err
+			ctx.W.WriteHeader(http.StatusUnauthorized)
+		}
+	}
+	e := msgo.Default()
+	e.Group("api").Get("/me", func(ctx *msgo.Context) {
+		res.called = true
+		if v, ok := ctx.Get(ClaimsKey); ok {
+			res.claims = v.(jwt.MapClaims)
+		}
+		ctx.W.WriteHeader(http.StatusOK)
+	}, j.AuthInterceptor)
+	e.ServeHTTP(res.rec, r)
+	return res
+}
+
+func bearerRequest(tok string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	if tok != "" {
+		r.Header.Set("Authorization", "Bearer "+tok)
+	}
+	return r
+}
+
+func TestBearerHeader(t *testing.T) {
+	tok := signHS(t, jwt.SigningMethodHS256, validClaims())
+	cases := []struct {
+		header string
+		want   error
+	}{
+		{"Bearer " + tok, nil},
+		{"bearer  " + tok, nil},
+		{"", ErrTokenMissing},
+		{tok, ErrTokenInvalid},
+		{"Basic " + tok, ErrTokenInvalid},
+		{"Bearer ", ErrTokenInvalid},
+		{"Bearer " + tok + "x", ErrTokenInvalid},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		if c.header != "" {
+			r.Header.Set("Authorization", c.header)
+		}
+		res := serveAuth(newTestHandler(), r, true)
+		if c.want == nil {
+			if !res.called || res.claims["sub"] != "alice" {
+				t.Errorf("Authorization %q: called=%v claims=%v err=%v", c.header, res.called, res.claims, res.err)
+			}
+			continue
+		}
+		if res.called || !errors.Is(res.err, c.want) {
+			t.Errorf("Authorization %q: called=%v err=%v, want %v", c.header, res.called, res.err, c.want)
+		}
+	}
+}
+
+func TestDefaultAuthHandler(t *testing.T) {
+	cases := []struct {
+		tok, challenge string
+	}{
+		{"", "Bearer"},
+		{"invalid", `Bearer error="invalid_token"`},
+	}
+	for _, c := range cases {
+		res := serveAuth(newTestHandler(), bearerRequest(c.tok), false)
+		if res.called || res.rec.Code != http.StatusUnauthorized || res.rec.Header().Get("WWW-Authenticate") != c.challenge {
+			t.Errorf("token %q = %d %q, want 401 %q", c.tok, res.rec.Code, res.rec.Header().Get("WWW-Authenticate"), c.challenge)
+		}
+	}
+}
+
+func TestTokenLookupOrder(t *testing.T) {
+	good := signHS(t, jwt.SigningMethodHS256, validClaims())
+	lookup := "header:Authorization,query:token,cookie:jwt_token"
+	cases := []struct {
+		name   string
+		header string
+		query  string
+		cookie string
+		want   error
+	}{
+		{"query", "", good, "", nil},
+		{"cookie", "", "", good, nil},
+		{"header before query", "Bearer " + good, "bad", "", nil},
+		//先找到的 token 无效时直接失败, 不继续尝试后面的来源
+		{"invalid header stops", "Bearer bad", good, good, ErrTokenInvalid},
+		{"invalid query stops", "", "bad", good, ErrTokenInvalid},
+		{"none", "", "", "", ErrTokenMissing},
+	}
+	for _, c := range cases {
+		target := "/api/me"
+		if c.query != "" {
+			target += "?token=" + c.query
+		}
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if c.header != "" {
+			r.Header.Set("Authorization", c.header)
+		}
+		if c.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: JWTToken, Value: c.cookie})
+		}
+		j := newTestHandler()
+		j.TokenLookup = lookup
+		res := serveAuth(j, r, true)
+		if c.want == nil && !res.called {
+			t.Errorf("%s: expected the handler to run, err %v", c.name, res.err)
+		}
+		if c.want != nil && (res.called || !errors.Is(res.err, c.want)) {
+			t.Errorf("%s: called=%v err=%v, want %v", c.name, res.called, res.err, c.want)
+		}
+	}
+
+	//默认只查找请求头, SendCookie 时再查找 cookie
+	r := httptest.NewRequest(http.MethodGet, "/api/me?token="+good, nil)
+	if res := serveAuth(newTestHandler(), r, true); res.called {
+		t.Fatal("query must not be searched by default")
+	}
+	r = httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	r.AddCookie(&http.Cookie{Name: JWTToken, Value: good})
+	j := newTestHandler()
+	j.SendCookie = true
+	if res := serveAuth(j, r, true); !res.called {
+		t.Fatalf("cookie should be searched with SendCookie, err %v", res.err)
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	with := func(k string, v any) jwt.MapClaims {
+		c := validClaims()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+	at := func(d time.Duration) int64 { return testNow.Add(d).Unix() }
+	cases := []struct {
+		name   string
+		method jwt.SigningMethod
+		claims jwt.MapClaims
+		want   error
+	}{
+		{"valid", jwt.SigningMethodHS256, validClaims(), nil},
+		{"no exp", jwt.SigningMethodHS256, with("exp", nil), ErrTokenInvalid},
+		{"expired within leeway", jwt.SigningMethodHS256, with("exp", at(-30*time.Second)), nil},
+		{"expired", jwt.SigningMethodHS256, with("exp", at(-2*time.Minute)), ErrTokenExpired},
+		{"nbf within leeway", jwt.SigningMethodHS256, with("nbf", at(30*time.Second)), nil},
+		{"not valid yet", jwt.SigningMethodHS256, with("nbf", at(2*time.Minute)), ErrTokenNotValidYet},
+		{"issued in the future", jwt.SigningMethodHS256, with("iat", at(2*time.Minute)), ErrTokenNotValidYet},
+		{"issuer", jwt.SigningMethodHS256, with("iss", "msgo"), nil},
+		{"wrong issuer", jwt.SigningMethodHS256, with("iss", "other"), ErrTokenIssuerInvalid},
+		{"missing issuer", jwt.SigningMethodHS256, with("iss", nil), ErrTokenIssuerInvalid},
+		{"audience list", jwt.SigningMethodHS256, with("aud", []string{"other", "app"}), nil},
+		{"wrong audience", jwt.SigningMethodHS256, with("aud", "other"), ErrTokenAudienceInvalid},
+		{"other alg", jwt.SigningMethodHS384, validClaims(), ErrTokenInvalid},
+	}
+	for _, c := range cases {
+		claims := c.claims
+		if _, ok := claims["iss"]; !ok && c.name != "missing issuer" {
+			claims["iss"] = "msgo"
+		}
+		if _, ok := claims["aud"]; !ok {
+			claims["aud"] = "app"
+		}
+		j := newTestHandler()
+		j.Leeway = time.Minute
+		j.Issuer = "msgo"
+		j.Audience = []string{"api", "app"}
+		res := serveAuth(j, bearerRequest(signHS(t, c.method, claims)), true)
+		if c.want == nil && !res.called {
+			t.Errorf("%s: expected the token to be accepted, err %v", c.name, res.err)
+		}
+		if c.want != nil && (res.called || !errors.Is(res.err, c.want)) {
+			t.Errorf("%s: called=%v err=%v, want %v", c.name, res.called, res.err, c.want)
+		}
+	}
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := serveAuth(newTestHandler(), bearerRequest(none), true); res.called || !errors.Is(res.err, ErrTokenInvalid) {
+		t.Fatalf("alg none must be rejected, called=%v err=%v", res.called, res.err)
+	}
+}
+
+func TestLoginTokenAuthenticates(t *testing.T) {
+	j := newTestHandler()
+	j.Issuer = "msgo"
+	j.Audience = []string{"app"}
+	j.Authenticator = func(ctx *msgo.Context) (map[string]any, error) {
+		return map[string]any{"sub": "alice"}, nil
+	}
+	rsp := login(t, j)
+	res := serveAuth(j, bearerRequest(rsp.Token), true)
+	if !res.called || res.claims["iss"] != "msgo" || res.claims["aud"] != "app" {
+		t.Fatalf("issued token: called=%v claims=%v err=%v", res.called, res.claims, res.err)
+	}
+	//刷新令牌不能用于访问接口
+	res = serveAuth(j, bearerRequest(rsp.RefreshToken), true)
+	if res.called || !errors.Is(res.err, ErrTokenInvalid) {
+		t.Fatalf("refresh token used as access token: called=%v err=%v", res.called, res.err)
+	}
+}
+
+// login 通过路由调用 LoginHandler
+func login(t *testing.T, j *JwtHandler) *JwtResponse {
+	t.Helper()
+	var rsp *JwtResponse
+	var err error
+	e := msgo.Default()
+	e.Group("auth").Post("/login", func(ctx *msgo.Context) {
+		rsp, err = j.LoginHandler(ctx)
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/auth/login", nil))
+	if err != nil || rsp == nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	return rsp
+}
@@ -0,0 +1,154 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/liyuanwu2020/msgo"
+)
+
+type tokenLookup struct {
+	source string
+	name   string
+}
+
+// tokenLookups 解析 TokenLookup, 未配置时默认查找 Header 请求头, SendCookie 时再查找 cookie
+func (j *JwtHandler) tokenLookups() []tokenLookup {
+	if j.TokenLookup == "" {
+		lookups := []tokenLookup{{source: "header", name: j.Header}}
+		if j.SendCookie {
+			lookups = append(lookups, tokenLookup{source: "cookie", name: j.CookieName})
+		}
+		return lookups
+	}
+	return parseTokenLookup(j.TokenLookup)
+}
+
+// refreshTokenLookups 解析 RefreshTokenLookup, 未配置时默认查找表单和 X-Refresh-Token 请求头, SendCookie 时再查找 cookie
+func (j *JwtHandler) refreshTokenLookups() []tokenLookup {
+	if j.RefreshTokenLookup == "" {
+		lookups := []tokenLookup{{source: "form", name: "refresh_token"}, {source: "header", name: "X-Refresh-Token"}}
+		if j.SendCookie {
+			lookups = append(lookups, tokenLookup{source: "cookie", name: j.RefreshCookieName})
+		}
+		return lookups
+	}
+	return parseTokenLookup(j.RefreshTokenLookup)
+}
+
+func parseTokenLookup(spec string) []tokenLookup {
+	var lookups []tokenLookup
+	for _, part := range strings.Split(spec, ",") {
+		source, name, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			panic("token: invalid token lookup " + spec)
+		}
+		source = strings.ToLower(strings.TrimSpace(source))
+		switch source {
+		case "header", "query", "cookie", "form":
+		default:
+			panic("token: unknown token lookup source " + source)
+		}
+		lookups = append(lookups, tokenLookup{source: source, name: strings.TrimSpace(name)})
+	}
+	return lookups
+}
+
+// lookupToken 按顺序查找 token, scheme 非空时请求头必须为 "scheme token", 认证方案不符时直接失败
+func (j *JwtHandler) lookupToken(ctx *msgo.Context, lookups []tokenLookup, scheme string) (string, error) {
+	for _, l := range lookups {
+		switch l.source {
+		case "header":
+			value := ctx.R.Header.Get(l.name)
+			if value == "" {
+				continue
+			}
+			if scheme == "" {
+				return value, nil
+			}
+			s, tokenStr, ok := strings.Cut(value, " ")
+			if !ok || !strings.EqualFold(s, scheme) || strings.TrimSpace(tokenStr) == "" {
+				return "", ErrTokenInvalid.WithMessage("authorization header must be %s <token>", scheme)
+			}
+			return strings.TrimSpace(tokenStr), nil
+		case "query":
+			if value := ctx.GetQuery(l.name); value != "" {
+				return value, nil
+			}
+		case "form":
+			if value, err := ctx.GetPost(l.name); err == nil && value != "" {
+				return value, nil
+			}
+		case "cookie":
+			value, err := j.getNamedCookie(ctx, l.name)
+			if err == nil && value != "" {
+				return value, nil
+			}
+		}
+	}
+	return "", ErrTokenMissing
+}
+
+// parseToken 校验签名算法、签名和 exp、nbf、iat、iss、aud, 非对称算法按 kid 查找公钥
+func (j *JwtHandler) parseToken(tokenStr string) (*jwt.Token, error) {
+	algs := []string{j.Alg}
+	if j.usingPublicKeyAlgo() {
+		algs = j.KeySet.Algs()
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods(algs), jwt.WithoutClaimsValidation())
+	t, err := parser.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		if !j.usingPublicKeyAlgo() {
+			return j.Key, nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, err := j.KeySet.Lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.Alg != token.Method.Alg() {
+			return nil, fmt.Errorf("token: alg %s does not match key %s", token.Method.Alg(), kid)
+		}
+		return key.PublicKey, nil
+	})
+	if err != nil {
+		return nil, ErrTokenInvalid.WithCause(err)
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok || !t.Valid {
+		return nil, ErrTokenInvalid
+	}
+	if err := j.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// validateClaims exp 必须存在, nbf、iat 存在时校验, 配置了 Issuer、Audience 时 iss、aud 必须匹配
+func (j *JwtHandler) validateClaims(claims jwt.MapClaims) error {
+	now := j.TimeFun()
+	if _, ok := claims["exp"]; !ok {
+		return ErrTokenInvalid.WithMessage("token has no exp claim")
+	}
+	if !claims.VerifyExpiresAt(now.Add(-j.Leeway).Unix(), true) {
+		return ErrTokenExpired
+	}
+	if !claims.VerifyNotBefore(now.Add(j.Leeway).Unix(), false) {
+		return ErrTokenNotValidYet
+	}
+	if !claims.VerifyIssuedAt(now.Add(j.Leeway).Unix(), false) {
+		return ErrTokenNotValidYet
+	}
+	if j.Issuer != "" && !claims.VerifyIssuer(j.Issuer, true) {
+		return ErrTokenIssuerInvalid
+	}
+	if len(j.Audience) > 0 {
+		for _, aud := range j.Audience {
+			if claims.VerifyAudience(aud, true) {
+				return nil
+			}
+		}
+		return ErrTokenAudienceInvalid
+	}
+	return nil
+}
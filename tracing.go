@@ -0,0 +1,50 @@
+package msgo
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/liyuanwu2020/msgo/mslog"
+	"github.com/liyuanwu2020/msgo/tracing"
+)
+
+// Tracing 为每个请求创建服务端 span, 读取上游 traceparent 并放入请求 context
+// 处理函数中使用 ctx.R.Context() 调用 rpc、orm 等即可延续链路
+func Tracing(next HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		parent := tracing.Extract(ctx.R.Context(), tracing.HeaderCarrier(ctx.R.Header))
+		route := ctx.RoutePattern()
+		spanCtx, span := tracing.Start(parent, ctx.R.Method+" "+route, tracing.SpanKindServer)
+		span.SetAttribute("http.method", ctx.R.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", ctx.R.URL.RequestURI())
+		ctx.R = ctx.R.WithContext(spanCtx)
+		if ctx.Logger != nil {
+			ctx.Logger = ctx.Logger.WithFields(mslog.Fields{"trace_id": span.SpanContext().TraceID.String()})
+		}
+		defer func() {
+			if err := recover(); err != nil {
+				span.SetError(fmt.Errorf("panic: %v", err))
+				span.End()
+				panic(err)
+			}
+			status := ctx.StatusCode
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttribute("http.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.SetError(httpStatusError(status))
+			}
+			span.End()
+		}()
+		next(ctx)
+	}
+}
+
+type httpStatusError int
+
+func (e httpStatusError) Error() string {
+	return strconv.Itoa(int(e)) + " " + http.StatusText(int(e))
+}
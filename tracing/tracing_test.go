@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Fatalf("unexpected span context %+v", sc)
+	}
+	if got := sc.Traceparent(); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("round trip mismatch: %s", got)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Errorf("future versions may append fields: %v", err)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	exp := NewInMemoryExporter()
+	tracer := NewTracer(exp)
+
+	ctx, client := tracer.Start(context.Background(), "client", SpanKindClient)
+	header := http.Header{}
+	Inject(ctx, HeaderCarrier(header))
+
+	remote := Extract(context.Background(), HeaderCarrier(header))
+	_, server := tracer.Start(remote, "server", SpanKindServer)
+	server.SetAttribute("http.status_code", 200)
+	server.End()
+	client.End()
+
+	spans := exp.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].TraceID != spans[1].TraceID {
+		t.Fatal("spans must share trace id")
+	}
+	if spans[0].ParentSpanID != spans[1].SpanID || spans[1].ParentSpanID != "" {
+		t.Fatalf("unexpected parent chain %+v", spans)
+	}
+	if spans[0].Attributes["http.status_code"] != 200 {
+		t.Fatalf("attribute missing %+v", spans[0].Attributes)
+	}
+}
+
+func TestUnsampledNotExported(t *testing.T) {
+	exp := NewInMemoryExporter()
+	tracer := NewTracer(exp)
+	ctx := Extract(context.Background(), MapCarrier{TraceparentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"})
+	ctx, span := tracer.Start(ctx, "op", SpanKindInternal)
+	span.End()
+	if len(exp.Spans()) != 0 {
+		t.Fatal("unsampled span must not be exported")
+	}
+	md := MapCarrier{}
+	Inject(ctx, md)
+	if md[TraceparentHeader][53:] != "00" {
+		t.Fatalf("sampled flag must propagate, got %s", md[TraceparentHeader])
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&buf))
+	_, span := tracer.Start(context.Background(), "op", SpanKindInternal)
+	span.End()
+	span.End()
+	var data SpanData
+	dec := json.NewDecoder(&buf)
+	if err := dec.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Name != "op" || dec.More() {
+		t.Fatalf("expected exactly one exported span, got %+v", data)
+	}
+}
@@ -0,0 +1,307 @@
+package msgo
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrUploadTooLarge   = errors.New("upload file too large")
+	ErrUploadNotAllowed = errors.New("upload file type not allowed")
+	ErrUploadPath       = errors.New("upload path is unsafe or outside of upload root")
+)
+
+// UploadConfig 上传限制, 可通过 Engine.Upload 全局配置, 或使用 UploadLimit 中间件按路由配置
+type UploadConfig struct {
+	//MaxMemory ParseMultipartForm 使用的内存上限, 超出部分写入临时文件
+	MaxMemory int64
+	//MaxBodySize 请求体总大小上限, 0 不限制
+	MaxBodySize int64
+	//MaxFileSize 单个文件大小上限, 0 不限制
+	MaxFileSize int64
+	//AllowedExts 允许的扩展名, 如 .png, 为空不限制
+	AllowedExts []string
+	//AllowedTypes 允许的 MIME 类型, 根据文件内容嗅探, 支持 image/* 前缀匹配, 为空不限制
+	AllowedTypes []string
+	//Root 上传根目录, 配置后只能保存到该目录下, 未配置时只能保存到相对于工作目录的路径
+	Root string
+}
+
+// UploadLimit 路由级别上传限制中间件
+func UploadLimit(conf UploadConfig) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			ctx.uploadConfig = &conf
+			next(ctx)
+		}
+	}
+}
+
+func (c *Context) uploadConf() *UploadConfig {
+	if c.uploadConfig != nil {
+		return c.uploadConfig
+	}
+	return &c.engine.Upload
+}
+
+func (u *UploadConfig) maxMemory() int64 {
+	if u.MaxMemory > 0 {
+		return u.MaxMemory
+	}
+	return defaultMultipartMemory
+}
+
+// limitBody 限制请求体大小
+func (c *Context) limitBody(u *UploadConfig) {
+	if u.MaxBodySize > 0 {
+		c.R.Body = http.MaxBytesReader(c.W, c.R.Body, u.MaxBodySize)
+	}
+}
+
+func (u *UploadConfig) allowExt(filename string) bool {
+	if len(u.AllowedExts) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, e := range u.AllowedExts {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *UploadConfig) allowType(contentType string) bool {
+	if len(u.AllowedTypes) == 0 {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for _, t := range u.AllowedTypes {
+		if strings.HasSuffix(t, "*") {
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+		} else if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// dstPath 计算保存路径, 配置 Root 后不允许跳出上传根目录, 未配置时只允许不包含 .. 的相对路径
+func (u *UploadConfig) dstPath(dstName string) (string, error) {
+	if u.Root == "" {
+		if filepath.IsAbs(dstName) || filepath.VolumeName(dstName) != "" ||
+			strings.IndexFunc(dstName, isPathSeparator) == 0 {
+			return "", ErrUploadPath
+		}
+		for _, elem := range strings.FieldsFunc(dstName, isPathSeparator) {
+			if elem == ".." {
+				return "", ErrUploadPath
+			}
+		}
+		return dstName, nil
+	}
+	root, err := filepath.Abs(u.Root)
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(root, filepath.Clean("/"+dstName))
+	if dst != root && !strings.HasPrefix(dst, root+string(filepath.Separator)) {
+		return "", ErrUploadPath
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// isPathSeparator 客户端文件名可能使用 windows 分隔符, 两种都按分隔符处理
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// SanitizeFilename 清理客户端提交的文件名, 去掉路径和特殊字符
+func SanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = filepath.Base("/" + name)
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '.', r == '-', r == '_':
+			sb.WriteRune(r)
+		case unicode.IsSpace(r):
+			sb.WriteRune('_')
+		}
+	}
+	name = strings.TrimLeft(sb.String(), ".")
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		//按字节截断时退到字符边界, 避免截出不完整的 utf-8 字符
+		n := 255 - len(ext)
+		for n > 0 && !utf8.RuneStart(name[n]) {
+			n--
+		}
+		name = name[:n] + ext
+	}
+	if name == "" {
+		name = "upload"
+	}
+	return name
+}
+
+// UploadedFile 保存后的文件信息
+type UploadedFile struct {
+	FieldName   string
+	Filename    string
+	Path        string
+	Size        int64
+	SHA256      string
+	ContentType string
+}
+
+// saveStream 嗅探类型后写入磁盘, 边写边计算 sha256, 超出大小或类型不允许时删除文件
+func (u *UploadConfig) saveStream(src io.Reader, filename, dstName string) (*UploadedFile, error) {
+	if !u.allowExt(filename) {
+		return nil, ErrUploadNotAllowed
+	}
+	br := bufio.NewReaderSize(src, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	contentType := http.DetectContentType(head)
+	if !u.allowType(contentType) {
+		return nil, ErrUploadNotAllowed
+	}
+	dst, err := u.dstPath(dstName)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	var reader io.Reader = br
+	if u.MaxFileSize > 0 {
+		reader = io.LimitReader(br, u.MaxFileSize+1)
+	}
+	size, err := io.Copy(io.MultiWriter(tmp, hash), reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.MaxFileSize > 0 && size > u.MaxFileSize {
+		return nil, ErrUploadTooLarge
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return nil, err
+	}
+	return &UploadedFile{
+		Filename:    filename,
+		Path:        dst,
+		Size:        size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		ContentType: contentType,
+	}, nil
+}
+
+// MultipartReader 流式读取 multipart 请求, 文件直接写入磁盘不经过内存缓冲
+type MultipartReader struct {
+	reader *multipart.Reader
+	conf   *UploadConfig
+	//memory 普通表单字段剩余可读取的字节数
+	memory int64
+	//Values 已读取的普通表单字段
+	Values url.Values
+}
+
+func (c *Context) MultipartReader() (*MultipartReader, error) {
+	conf := c.uploadConf()
+	c.limitBody(conf)
+	reader, err := c.R.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	return &MultipartReader{reader: reader, conf: conf, memory: conf.maxMemory(), Values: make(url.Values)}, nil
+}
+
+// NextPart 返回下一个文件, 普通表单字段读入 Values, 读取完毕返回 io.EOF,
+// 普通表单字段合计超过 MaxMemory 时返回 multipart.ErrMessageTooLarge
+func (m *MultipartReader) NextPart() (*multipart.Part, error) {
+	for {
+		part, err := m.reader.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() != "" {
+			return part, nil
+		}
+		value, err := io.ReadAll(io.LimitReader(part, m.memory+1))
+		_ = part.Close()
+		if err != nil {
+			return nil, err
+		}
+		m.memory -= int64(len(value))
+		if m.memory < 0 {
+			return nil, multipart.ErrMessageTooLarge
+		}
+		m.Values.Add(part.FormName(), string(value))
+	}
+}
+
+// Save 保存文件 part, dstName 为空时使用清理后的原始文件名
+func (m *MultipartReader) Save(part *multipart.Part, dstName string) (*UploadedFile, error) {
+	defer part.Close()
+	filename := SanitizeFilename(part.FileName())
+	if dstName == "" {
+		dstName = filename
+	}
+	file, err := m.conf.saveStream(part, filename, dstName)
+	if err != nil {
+		return nil, err
+	}
+	file.FieldName = part.FormName()
+	return file, nil
+}
+
+// SaveAll 保存请求中的全部文件到上传根目录
+func (m *MultipartReader) SaveAll() ([]*UploadedFile, error) {
+	var files []*UploadedFile
+	for {
+		part, err := m.NextPart()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		file, err := m.Save(part, "")
+		if err != nil {
+			return files, err
+		}
+		files = append(files, file)
+	}
+}
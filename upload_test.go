@@ -0,0 +1,120 @@
+package msgo
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := []struct{ name, want string }{
+		{"photo.png", "photo.png"},
+		{"../../etc/passwd", "passwd"},
+		{`..\..\windows\win.ini`, "win.ini"},
+		{"/abs/path/a b.txt", "a_b.txt"},
+		{".htaccess", "htaccess"},
+		{"..", "upload"},
+		{"", "upload"},
+		{"a<>:\"|?*.txt", "a.txt"},
+		{"照片.jpg", "照片.jpg"},
+		{strings.Repeat("a", 300) + ".png", strings.Repeat("a", 251) + ".png"},
+		//251 字节处落在 3 字节汉字中间, 退到字符边界
+		{"a" + strings.Repeat("照", 100) + ".png", "a" + strings.Repeat("照", 83) + ".png"},
+	}
+	for _, c := range cases {
+		if got := SanitizeFilename(c.name); got != c.want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestUploadDstPath(t *testing.T) {
+	root := t.TempDir()
+	withRoot := &UploadConfig{Root: root}
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"a.png", filepath.Join(root, "a.png")},
+		{"sub/a.png", filepath.Join(root, "sub", "a.png")},
+		//以根目录为起点解析, .. 无法跳出上传根目录
+		{"../../a.png", filepath.Join(root, "a.png")},
+		{"/etc/a.png", filepath.Join(root, "etc", "a.png")},
+	}
+	for _, c := range cases {
+		if got, err := withRoot.dstPath(c.name); err != nil || got != c.want {
+			t.Errorf("dstPath(%q) = %q, %v, want %q", c.name, got, err, c.want)
+		}
+	}
+
+	noRoot := &UploadConfig{}
+	for _, name := range []string{"../a.png", "upload/../../a.png", `upload\..\a.png`, "/etc/cron.d/x", `\windows\a.png`} {
+		if _, err := noRoot.dstPath(name); !errors.Is(err, ErrUploadPath) {
+			t.Errorf("dstPath(%q) without root = %v, want ErrUploadPath", name, err)
+		}
+	}
+	if got, err := noRoot.dstPath("upload/a.png"); err != nil || got != "upload/a.png" {
+		t.Errorf("dstPath(upload/a.png) without root = %q, %v", got, err)
+	}
+}
+
+func multipartBody(t *testing.T, fields map[string]string, files map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for k, v := range fields {
+		_ = mw.WriteField(k, v)
+	}
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = fw.Write([]byte(content))
+	}
+	_ = mw.Close()
+	return body, mw.FormDataContentType()
+}
+
+func TestMultipartReader(t *testing.T) {
+	root := t.TempDir()
+	e := newTestEngine()
+	e.Upload = UploadConfig{Root: root, MaxMemory: 16}
+	var saved []*UploadedFile
+	var values map[string][]string
+	e.Group("files").Post("/upload", func(ctx *Context) {
+		reader, err := ctx.MultipartReader()
+		if err == nil {
+			saved, err = reader.SaveAll()
+			values = reader.Values
+		}
+		if err != nil {
+			_ = ctx.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		_ = ctx.String(http.StatusOK, "ok")
+	})
+
+	body, contentType := multipartBody(t, map[string]string{"title": "hello"}, map[string]string{"../../evil.txt": "content"})
+	if w := serve(e, http.MethodPost, "/files/upload", body, "Content-Type", contentType); w.Code != http.StatusOK {
+		t.Fatalf("upload = %d %s", w.Code, w.Body)
+	}
+	if len(saved) != 1 || saved[0].Path != filepath.Join(root, "evil.txt") || values["title"][0] != "hello" {
+		t.Fatalf("unexpected upload result %+v, %v", saved, values)
+	}
+	if data, _ := os.ReadFile(saved[0].Path); string(data) != "content" {
+		t.Fatalf("saved file content %q", data)
+	}
+
+	//普通字段超出 MaxMemory 时返回错误, 不能截断后当作完整的值
+	body, contentType = multipartBody(t, map[string]string{"title": strings.Repeat("x", 17)}, nil)
+	w := serve(e, http.MethodPost, "/files/upload", body, "Content-Type", contentType)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), multipart.ErrMessageTooLarge.Error()) {
+		t.Fatalf("oversized form value = %d %s", w.Code, w.Body)
+	}
+}